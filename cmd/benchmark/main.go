@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -41,25 +42,27 @@ func runBenchmarksAndProfile(profilePath string) error {
 	}
 	defer f.Close()
 
+	ctx := context.Background()
+
 	// Run operations that we want to profile
-	memStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	memStorage := storage.NewMemoryStorage()
 
 	// Add 10,000 URLs
 	fmt.Println("Adding 10,000 URLs to memory storage...")
 	for i := 0; i < 10000; i++ {
 		url := fmt.Sprintf("https://example.com/page%d", i)
-		token, _ := urlutils.EncodeURL(url, memStorage, "user1")
+		token, _ := urlutils.EncodeURL(ctx, url, memStorage, "user1")
 
 		// Perform some lookups to simulate real usage
 		if i%100 == 0 {
-			_, _ = memStorage.GetURL(token)
-			_, _ = urlutils.DecodeURL(token, memStorage)
+			_, _ = memStorage.GetURL(ctx, token)
+			_, _ = urlutils.DecodeURL(ctx, token, memStorage)
 		}
 	}
 
 	// Get user URLs
 	fmt.Println("Getting user URLs...")
-	urls, _ := memStorage.GetUserURLs("user1")
+	urls, _ := memStorage.GetUserURLs(ctx, "user1")
 	fmt.Printf("Found %d URLs for user1\n", len(urls))
 
 	// Force GC again before writing profile
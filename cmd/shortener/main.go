@@ -1,21 +1,28 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	_ "net/http/pprof"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pcristin/urlshortener/internal/accesslog"
 	"github.com/pcristin/urlshortener/internal/app"
-	"github.com/pcristin/urlshortener/internal/config"
-	"github.com/pcristin/urlshortener/internal/database"
-	"github.com/pcristin/urlshortener/internal/gzip"
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	"github.com/pcristin/urlshortener/internal/certs"
+	cfg "github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/deletion"
 	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/pcristin/urlshortener/internal/metrics"
+	"github.com/pcristin/urlshortener/internal/rpcapi"
+	"github.com/pcristin/urlshortener/internal/service"
 	"github.com/pcristin/urlshortener/internal/storage"
 	"go.uber.org/zap"
 )
@@ -26,24 +33,27 @@ var (
 	buildCommit  = "N/A"
 )
 
+const (
+	// shutdownTimeout limits how long in-flight requests may take to complete on shutdown
+	shutdownTimeout = 15 * time.Second
+	// drainTimeout limits how long background jobs may take to complete once requests are done
+	drainTimeout = 15 * time.Second
+)
+
 func main() {
 	if err := run(); err != nil {
 		// Log the error before exiting
-		if logger, err := logger.Initialize(); err == nil {
+		if logger, logErr := logger.Initialize(); logErr == nil {
 			logger.Errorw("application error", "error", err)
 			logger.Sync()
 		} else {
-			fmt.Printf("logger error: %v, original error: %v\n", err, err)
+			fmt.Printf("logger error: %v, original error: %v\n", logErr, err)
 		}
 	}
 }
 
 // run encapsulates the main application logic and returns an error instead of exiting directly
 func run() error {
-	fmt.Printf("Build version: %s\n", buildVersion)
-	fmt.Printf("Build date: %s\n", buildDate)
-	fmt.Printf("Build commit: %s\n", buildCommit)
-
 	// Initialize logger
 	log, err := logger.Initialize()
 	if err != nil {
@@ -54,61 +64,306 @@ func run() error {
 	defer log.Sync()
 
 	// Initialize configuration and get server address from config
-	config := config.NewOptions()
-	config.ParseFlags()
+	config := cfg.NewOptions()
+	if err := config.ParseFlags(); err != nil {
+		return fmt.Errorf("configuration error | %w", err)
+	}
+
+	if config.GetPrintConfig() {
+		if err := config.PrintConfig(os.Stdout); err != nil {
+			return fmt.Errorf("configuration error | failed to print configuration: %w", err)
+		}
+		return config.Validate()
+	}
+
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("configuration error | invalid configuration:\n%w", err)
+	}
+
+	// Secrets are always masked in the logs, personal data is hashed on demand
+	logger.SetRedaction(logger.Redaction{HashUserIDs: config.GetLogHashPII(), HashURLs: config.GetLogHashPII()})
+
+	// Apply the log level now and on every configuration reload
+	if err := logger.SetLevel(config.GetLogLevel()); err != nil {
+		return fmt.Errorf("configuration error | %w", err)
+	}
+	config.Subscribe(func(d cfg.Dynamic) {
+		if err := logger.SetLevel(d.LogLevel); err != nil {
+			log.Errorw("Failed to apply log level", "level", d.LogLevel, "error", err)
+		}
+		log.Infow("Applied reloaded configuration", "log_level", d.LogLevel, "base_url", d.BaseURL)
+	})
+
+	fmt.Printf("Build version: %s\n", buildVersion)
+	fmt.Printf("Build date: %s\n", buildDate)
+	fmt.Printf("Build commit: %s\n", buildCommit)
 
 	serverURL := config.GetServerURL()
-	if serverURL == "" {
-		return errors.New("configuration error | server address can not be empty")
+
+	// Open the storage selected by the scheme of its URI. The service doesn't start if it can't be
+	// reached: falling back to memory would accept writes lost on restart.
+	rawStorage, err := storage.Open(context.Background(), config.GetStorageURI())
+	if err != nil {
+		return fmt.Errorf("storage error | failed to open storage: %w", err)
+	}
+	log.Infow("Storage opened", "backend", rawStorage.Backend())
+	migrator, needsMigration := rawStorage.(storage.Migrator)
+
+	// Measure the operations of the storage
+	urlStorage := storage.NewInstrumentedStorage(rawStorage)
+
+	// Deletions are queued and written in the background, resuming those left pending by the previous run
+	deletions, err := deletion.NewQueue(urlStorage, deletion.Config{
+		Size:          config.GetDeleteQueueSize(),
+		Workers:       config.GetDeleteWorkers(),
+		FlushInterval: config.GetDeleteFlushInterval(),
+		JournalPath:   config.GetDeleteJournalPath(),
+	})
+	if err != nil {
+		return fmt.Errorf("deletion error | %w", err)
+	}
+	metrics.NewGaugeFunc("shortener_deletion_queue_depth", "Number of deletion requests queued and not written yet.", func() float64 {
+		return float64(deletions.Depth())
+	})
+
+	// Initialize handler with storage and config
+	handler := app.NewHandler(urlStorage, config, deletions)
+	if needsMigration {
+		// The storage is migrated once the server is up, until then only health checks are served
+		handler.BeginStartup()
+	}
+
+	// Requests are written to the access log, apart from the application logs; it is closed once shut down
+	accessLogOutput, err := accesslog.Open(config.GetAccessLogOutput(), config.GetAccessLogRotation())
+	if err != nil {
+		err = fmt.Errorf("access log error | %w", err)
+		return errors.Join(err, shutdown(nil, nil, nil, nil, handler, urlStorage, log))
+	}
+	defer accessLogOutput.Close()
+	accessLog := accesslog.New(accessLogOutput, accesslog.Config{
+		Format:       config.GetAccessLogFormat(),
+		SampleRate:   config.GetAccessLogSampleRate(),
+		SampleRoutes: config.GetAccessLogSampleRoutes(),
+	})
+
+	r, err := newRouter(handler, config.GetDevMode(), accessLog, bodylimit.New(config.GetBodyLimits()))
+	if err != nil {
+		return errors.Join(err, shutdown(nil, nil, nil, nil, handler, urlStorage, log))
+	}
+	if config.GetDevMode() {
+		log.Warnw("Development mode: validating requests and responses against the OpenAPI specification")
 	}
 
-	// Determine storage type based on config
-	var storageType storage.StorageType
-	var dbPool *pgxpool.Pool
-	var filePath string
+	server := &http.Server{
+		Addr:    serverURL,
+		Handler: r,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	// SIGHUP is caught before the reload goroutine runs, so an early one doesn't kill the process
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go reloadOnSIGHUP(ctx, hup, config, log)
 
-	if databaseDSN := config.GetDatabaseDSN(); databaseDSN != "" {
-		zap.L().Sugar().Infow("Database config", "databaseDSN", databaseDSN)
-		dbManager, err := database.NewDatabaseManager(databaseDSN)
+	var redirectServer *http.Server
+	if config.GetEnableHTTPS() {
+		if redirectServer, err = setupTLS(ctx, server, config, log); err != nil {
+			return errors.Join(err, shutdown(nil, nil, nil, nil, handler, urlStorage, log))
+		}
+	}
+
+	// The RPC listener is opened before any server starts, so failing to open it only has
+	// the deletion queue and storage to release, as for the failures above
+	var rpcServer *rpcapi.Server
+	if rpcAddress := config.GetRPCAddress(); rpcAddress != "" {
+		rpcServer, err = rpcapi.NewServer(service.NewShortener(urlStorage, config), config.GetSecret(), serverURL)
 		if err != nil {
-			log.Warnf("database error | failed to connect to database: %v", err)
-		} else {
-			storageType = storage.DatabaseStorageType
-			dbPool = dbManager.GetPool()
+			err = fmt.Errorf("rpc error | failed to create server: %w", err)
+			return errors.Join(err, shutdown(nil, nil, nil, nil, handler, urlStorage, log))
 		}
-	} else if filePath = config.GetPathToSavedData(); filePath != "" {
-		storageType = storage.FileStorageType
-	} else {
-		storageType = storage.MemoryStorageType
+		listener, err := net.Listen("tcp", rpcAddress)
+		if err != nil {
+			err = fmt.Errorf("rpc error | failed to listen: %w", err)
+			return errors.Join(err, shutdown(nil, nil, nil, nil, handler, urlStorage, log))
+		}
+		go func() {
+			log.Infow("Running RPC server on", "address", rpcAddress)
+			if err := rpcServer.Serve(listener); err != nil {
+				log.Errorw("rpc server error | failed to serve", "error", err)
+			}
+		}()
 	}
 
-	// Initialize storage with determined type
-	urlStorage := storage.NewURLStorage(storageType, filePath, dbPool)
+	serverErr := make(chan error, 1)
+	go func() {
+		if server.TLSConfig == nil {
+			log.Infow(
+				"Running server on",
+				"address", serverURL,
+			)
+			serverErr <- server.ListenAndServe()
+			return
+		}
+
+		log.Infow(
+			"Running HTTPS server on",
+			"address", serverURL,
+		)
+		serverErr <- server.ListenAndServeTLS("", "")
+	}()
 
-	// Initialize handler with storage and config
-	handler := app.NewHandler(urlStorage, config)
+	migrateErr := make(chan error, 1)
+	if needsMigration {
+		go func() {
+			if err := migrator.Migrate(ctx); err != nil {
+				migrateErr <- err
+				return
+			}
+			handler.FinishStartup()
+			log.Infow("Storage migrated, ready to serve traffic")
+		}()
+	}
+
+	var metricsServer *http.Server
+	if metricsAddress := config.GetMetricsAddress(); metricsAddress != "" {
+		metricsServer = &http.Server{
+			Addr:    metricsAddress,
+			Handler: newAdminMux(),
+		}
+		go func() {
+			log.Infow("Running metrics server on", "address", metricsAddress)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorw("metrics server error | failed to listen and serve", "error", err)
+			}
+		}()
+	}
+
+	if redirectServer != nil {
+		go func() {
+			log.Infow("Running HTTP to HTTPS redirect on", "address", redirectServer.Addr)
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorw("redirect server error | failed to listen and serve", "error", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
+		// The server failed on its own, still release what it holds
+		err = fmt.Errorf("server error | failed to listen and serve: %w", err)
+		return errors.Join(err, shutdown(nil, redirectServer, metricsServer, rpcServer, handler, urlStorage, log))
+	case err := <-migrateErr:
+		err = fmt.Errorf("storage error | failed to migrate: %w", err)
+		return errors.Join(err, shutdown(server, redirectServer, metricsServer, rpcServer, handler, urlStorage, log))
+	case <-ctx.Done():
+		log.Infow("Shutdown signal received, stopping server")
+	}
+	stop()
+
+	return shutdown(server, redirectServer, metricsServer, rpcServer, handler, urlStorage, log)
+}
+
+// reloadOnSIGHUP reloads the configuration every time a SIGHUP is received on hup until the context is done
+func reloadOnSIGHUP(ctx context.Context, hup <-chan os.Signal, config *cfg.Options, log *zap.SugaredLogger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			warnings, err := config.Reload()
+			if err != nil {
+				log.Errorw("Configuration reload rejected, keeping the running configuration", "error", err)
+				continue
+			}
+			for _, warning := range warnings {
+				log.Warnw("Configuration change not applied", "warning", warning)
+			}
+			log.Infow("Configuration reloaded on SIGHUP")
+		}
+	}
+}
+
+// shutdown stops the service in order: readiness starts failing, the servers stop accepting
+// connections and wait for in-flight requests, background jobs are drained, the metrics server
+// stops, then storage is closed.
+// Each phase is bounded by its own timeout so a stuck phase doesn't prevent closing storage.
+func shutdown(
+	server, redirectServer, metricsServer *http.Server,
+	rpcServer *rpcapi.Server,
+	handler app.HandlerInterface,
+	urlStorage storage.URLStorager,
+	log *zap.SugaredLogger,
+) error {
+	var errs []error
+
+	handler.BeginShutdown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range []*http.Server{server, redirectServer} {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown error | failed to stop server %s: %w", srv.Addr, err))
+		}
+	}
+	if rpcServer != nil {
+		if err := rpcServer.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown error | failed to stop RPC server: %w", err))
+		}
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+	if err := handler.Drain(drainCtx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown error | background jobs did not finish: %w", err))
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(drainCtx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown error | failed to stop metrics server: %w", err))
+		}
+	}
 
-	r := chi.NewRouter()
+	if err := urlStorage.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown error | failed to close storage: %w", err))
+	}
 
-	// Set up the middlewares: 60s timeout
-	r.Use(middleware.Timeout(60 * time.Second))
+	log.Infow("Server stopped")
+	return errors.Join(errs...)
+}
 
-	r.Post("/", logger.WithLogging(gzip.GzipMiddleware(handler.AuthMiddleware(handler.EncodeURLHandler)), log))
-	r.Get("/{id}", logger.WithLogging(gzip.GzipMiddleware(handler.DecodeURLHandler), log))
-	r.Post("/api/shorten", logger.WithLogging(gzip.GzipMiddleware(handler.AuthMiddleware(handler.APIEncodeHandler)), log))
-	r.Post("/api/shorten/batch", logger.WithLogging(gzip.GzipMiddleware(handler.AuthMiddleware(handler.APIEncodeBatchHandler)), log))
-	r.Get("/ping", logger.WithLogging(handler.PingHandler, log))
-	r.Get("/api/user/urls", logger.WithLogging(gzip.GzipMiddleware(handler.AuthMiddleware(handler.GetUserURLsHandler)), log))
-	r.Delete("/api/user/urls", logger.WithLogging(gzip.GzipMiddleware(handler.AuthMiddleware(handler.DeleteUserURLsHandler)), log))
+// setupTLS configures the server to serve HTTPS with a hot reloaded certificate, generating a self-signed
+// one if none exists, and returns the plain HTTP server redirecting to HTTPS, or nil if none is configured.
+// The certificate is watched until the context is done.
+func setupTLS(ctx context.Context, server *http.Server, config *cfg.Options, log *zap.SugaredLogger) (*http.Server, error) {
+	certFile, keyFile := config.GetTLSCertFile(), config.GetTLSKeyFile()
 
-	log.Infow(
-		"Running server on",
-		"address", serverURL,
-	)
+	host, _, _ := net.SplitHostPort(server.Addr)
+	generated, err := certs.EnsureSelfSigned(certFile, keyFile, host)
+	if err != nil {
+		return nil, fmt.Errorf("tls error | failed to generate self-signed certificate: %w", err)
+	}
+	if generated {
+		log.Warnw("Generated self-signed certificate for development", "cert", certFile, "key", keyFile)
+	}
 
-	if err := http.ListenAndServe(serverURL, r); err != nil {
-		return fmt.Errorf("server error | failed to listen and serve: %w", err)
+	reloader, err := certs.NewReloader(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls error | failed to load certificate: %w", err)
 	}
+	go reloader.Watch(ctx, 10*time.Second)
+	server.TLSConfig = reloader.TLSConfig()
 
-	return nil
+	redirectAddr := config.GetHTTPRedirectAddress()
+	if redirectAddr == "" {
+		return nil, nil
+	}
+	return &http.Server{
+		Addr:    redirectAddr,
+		Handler: certs.RedirectHandler(server.Addr),
+	}, nil
 }
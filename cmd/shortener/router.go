@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/pcristin/urlshortener/internal/accesslog"
+	"github.com/pcristin/urlshortener/internal/app"
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	"github.com/pcristin/urlshortener/internal/compress"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/pcristin/urlshortener/internal/metrics"
+	"github.com/pcristin/urlshortener/internal/openapi"
+)
+
+// newRouter registers the routes of the HTTP API, all of which are documented in the OpenAPI specification.
+// Every request is written to the access log and request bodies are bounded by the limiter.
+// In development mode requests and responses are validated against the specification.
+func newRouter(handler app.HandlerInterface, devMode bool, accessLog *accesslog.Logger, bodyLimiter *bodylimit.Limiter) (*chi.Mux, error) {
+	spec, err := openapi.Load()
+	if err != nil {
+		return nil, fmt.Errorf("openapi error | %w", err)
+	}
+
+	// validate sits inside the compression middleware so it sees decompressed bodies
+	validate := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	if devMode {
+		validate = spec.ValidationMiddleware
+	}
+
+	r := chi.NewRouter()
+
+	// Set up the middlewares: request ID, access log, body limits, metrics by route pattern
+	r.Use(logger.RequestID)
+	r.Use(accessLog.Middleware)
+	r.Use(bodyLimiter.Middleware)
+	r.Use(metrics.Middleware)
+
+	// Health checks and the specification are served while starting up
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second))
+
+		r.Get("/api/openapi.json", logger.WithRoute(compress.Middleware(openapi.ServeSpec)))
+		r.Get("/ping", logger.WithRoute(validate(handler.PingHandler)))
+		r.Get("/healthz", logger.WithRoute(validate(handler.LivenessHandler)))
+		r.Get("/readyz", logger.WithRoute(validate(handler.ReadinessHandler)))
+	})
+
+	// The API answers 503 until startup tasks such as database migrations are done
+	r.Group(func(r chi.Router) {
+		r.Use(handler.StartupMiddleware)
+
+		// Streamed imports and exports last as long as the transfer, so they have no timeout
+		r.Group(func(r chi.Router) {
+			r.Post("/api/shorten/import", logger.WithRoute(compress.Middleware(validate(handler.AuthMiddleware(handler.APIImportHandler)))))
+			r.Get("/api/user/urls/export", logger.WithRoute(validate(handler.AuthMiddleware(handler.ExportUserURLsHandler))))
+		})
+
+		// Every other route is cancelled after 60s
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			r.Post("/", logger.WithRoute(compress.Middleware(validate(handler.AuthMiddleware(handler.EncodeURLHandler)))))
+			r.Get("/{id}", logger.WithRoute(compress.Middleware(validate(handler.DecodeURLHandler))))
+			r.Post("/api/shorten", logger.WithRoute(compress.Middleware(validate(handler.AuthMiddleware(handler.APIEncodeHandler)))))
+			r.Post("/api/shorten/batch", logger.WithRoute(compress.Middleware(validate(handler.AuthMiddleware(handler.APIEncodeBatchHandler)))))
+			r.Get("/api/user/urls", logger.WithRoute(compress.Middleware(validate(handler.AuthMiddleware(handler.GetUserURLsHandler)))))
+			r.Delete("/api/user/urls", logger.WithRoute(compress.Middleware(validate(handler.AuthMiddleware(handler.DeleteUserURLsHandler)))))
+			r.Post("/api/user/register", logger.WithRoute(compress.Middleware(validate(handler.RegisterHandler))))
+			r.Post("/api/user/login", logger.WithRoute(compress.Middleware(validate(handler.LoginHandler))))
+			r.Get("/api/user/oidc/login", logger.WithRoute(validate(handler.OIDCLoginHandler)))
+			r.Get("/api/user/oidc/callback", logger.WithRoute(validate(handler.OIDCCallbackHandler)))
+			r.Get("/api/internal/stats", logger.WithRoute(validate(handler.TrustedSubnetMiddleware(handler.StatsHandler))))
+
+			r.Route("/api/admin", func(r chi.Router) {
+				r.Get("/urls", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminListURLsHandler))))
+				r.Post("/urls/{token}/disable", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminDisableURLHandler))))
+				r.Post("/urls/{token}/enable", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminEnableURLHandler))))
+				r.Put("/urls/{token}/owner", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminSetURLOwnerHandler))))
+				r.Delete("/urls/{token}", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminDeleteURLHandler))))
+				r.Get("/users/{userID}/urls", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminUserURLsHandler))))
+				r.Post("/config/reload", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminReloadConfigHandler))))
+				r.Get("/log-level", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminGetLogLevelHandler))))
+				r.Put("/log-level", logger.WithRoute(validate(handler.AdminMiddleware(handler.AdminSetLogLevelHandler))))
+			})
+		})
+	})
+
+	return r, nil
+}
+
+// newAdminMux returns the handler of the admin listener, kept apart from the public API:
+// it serves the metrics in the Prometheus text format at /metrics
+func newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default.Handler())
+	return mux
+}
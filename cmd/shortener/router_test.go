@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pcristin/urlshortener/internal/accesslog"
+	"github.com/pcristin/urlshortener/internal/app"
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	cfg "github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/deletion"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/openapi"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(t *testing.T, devMode bool) *chi.Mux {
+	t.Helper()
+	urlStorage := storage.NewMemoryStorage()
+	deletions, err := deletion.NewQueue(urlStorage, deletion.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = deletions.Close(context.Background()) })
+	handler := app.NewHandler(urlStorage, cfg.NewOptions(), deletions)
+	r, err := newRouter(handler, devMode, accesslog.New(io.Discard, accesslog.Config{}), bodylimit.New(cfg.NewOptions().GetBodyLimits()))
+	require.NoError(t, err)
+	return r
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+
+	routed := make(map[string]bool)
+	err = chi.Walk(newTestRouter(t, false), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed[method+" "+route] = true
+		assert.NotNil(t, doc.Operation(method, route), "route %s %s is not documented in the OpenAPI specification", method, route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	for pattern, operations := range doc.Paths {
+		for method := range operations {
+			key := strings.ToUpper(method) + " " + pattern
+			assert.True(t, routed[key], "documented operation %s is not routed", key)
+		}
+	}
+}
+
+func TestDevModeValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		devMode    bool
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid request",
+			devMode:    true,
+			body:       `{"url": "https://practicum.yandex.ru"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "invalid request rejected in dev mode",
+			devMode:    true,
+			body:       `{"url": "https://practicum.yandex.ru", "alias": "yp"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid request passed to the handler otherwise",
+			devMode:    false,
+			body:       `{"url": "https://practicum.yandex.ru", "alias": "yp"}`,
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			newTestRouter(t, tt.devMode).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestBodyLimits(t *testing.T) {
+	gzipped := func(content []byte) io.Reader {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(content)
+		zw.Close()
+		return &buf
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		contentType     string
+		contentEncoding string
+		body            io.Reader
+		wantStatus      int
+		wantBody        string
+	}{
+		{
+			name:       "body above the limit",
+			path:       "/",
+			body:       strings.NewReader("https://practicum.yandex.ru/" + strings.Repeat("a", 1<<20)),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   "request entity too large: request body larger than 1048576 bytes",
+		},
+		{
+			name:            "decompression bomb",
+			path:            "/api/shorten",
+			contentType:     "application/json",
+			contentEncoding: "gzip",
+			body:            gzipped(bytes.Repeat([]byte(" "), 8<<20)),
+			wantStatus:      http.StatusRequestEntityTooLarge,
+			wantBody:        "request entity too large: request body decompresses to more than 100 times its size",
+		},
+		{
+			name:        "batch above the limit",
+			path:        "/api/shorten/batch",
+			contentType: "application/json",
+			body:        strings.NewReader("[" + strings.Repeat(`{"correlation_id":"1","original_url":"https://practicum.yandex.ru"},`, 1000) + "{}]"),
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantBody:    "request entity too large: batch too large: 1001 items, at most 1000 allowed",
+		},
+		{
+			name:       "URL above the limit",
+			path:       "/",
+			body:       strings.NewReader("https://practicum.yandex.ru/" + strings.Repeat("a", 8192)),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "bad request: URL longer than 8192 characters",
+		},
+	}
+
+	router := newTestRouter(t, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, tt.body)
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Content-Encoding", tt.contentEncoding)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.True(t, strings.HasPrefix(w.Body.String(), tt.wantBody), w.Body.String())
+		})
+	}
+}
+
+func TestImportOverServer(t *testing.T) {
+	// Results are streamed while the upload is read: a recorder can't show an HTTP/1.1 server
+	// discarding the rest of the body once the response is flushed
+	for _, devMode := range []bool{false, true} {
+		for _, rows := range []int{400, 3000, 8000} {
+			t.Run(fmt.Sprintf("dev mode %t, %d rows", devMode, rows), func(t *testing.T) {
+				server := httptest.NewServer(newTestRouter(t, devMode))
+				defer server.Close()
+
+				var body strings.Builder
+				for i := range rows {
+					fmt.Fprintf(&body, "%d,https://example.com/%d\n", i, i)
+				}
+				resp, err := http.Post(server.URL+"/api/shorten/import", "text/csv", strings.NewReader(body.String()))
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+
+				results := 0
+				scanner := bufio.NewScanner(resp.Body)
+				for scanner.Scan() {
+					var result models.ImportResult
+					require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+					require.Empty(t, result.Error, "line %d", result.Line)
+					require.NotEmpty(t, result.ShortURL, "line %d", result.Line)
+					results++
+				}
+				require.NoError(t, scanner.Err())
+				assert.Equal(t, rows, results)
+			})
+		}
+	}
+}
+
+func TestServeSpec(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	newTestRouter(t, true).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"openapi": "3.0.3"`)
+}
+
+func TestRequestIDEchoed(t *testing.T) {
+	router := newTestRouter(t, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/unknown1", nil)
+	req.Header.Set(logger.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get(logger.RequestIDHeader))
+	assert.Equal(t, "bad request: unable to decode provided token (request ID req-1)\n", w.Body.String())
+}
+
+func TestAdminMetrics(t *testing.T) {
+	// Shorten a URL, then follow it, so the router records both routes
+	router := newTestRouter(t, false)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://practicum.yandex.ru")))
+	require.Equal(t, http.StatusCreated, w.Code)
+	token := w.Body.String()[strings.LastIndex(w.Body.String(), "/")+1:]
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+token, nil))
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	w = httptest.NewRecorder()
+	newAdminMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `shortener_http_requests_total{method="POST",route="/",status="2xx"}`)
+	assert.Contains(t, body, `shortener_http_request_duration_seconds_bucket{method="GET",route="/{id}",status="3xx",le="+Inf"}`)
+	assert.Contains(t, body, `shortener_redirects_total{result="redirected"}`)
+	assert.Contains(t, body, "# TYPE go_goroutines gauge")
+}
@@ -0,0 +1,367 @@
+// Package accesslog writes a line for every HTTP request served, in JSON, Apache combined or logfmt format.
+//
+// The access log is kept apart from the application logs: it goes to its own output, usually
+// a file rotated by size and age (see OpenFile), and high-volume routes such as redirects can be
+// sampled. Middleware is installed on the router and records the request as the handlers see it.
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pcristin/urlshortener/internal/logger"
+)
+
+// Format is the format of the lines of the access log
+type Format string
+
+// Formats of the access log
+const (
+	// FormatJSON writes a JSON object per request
+	FormatJSON Format = "json"
+	// FormatCombined writes the Apache combined log format understood by most log analyzers
+	FormatCombined Format = "combined"
+	// FormatLogfmt writes key=value pairs
+	FormatLogfmt Format = "logfmt"
+)
+
+// ParseFormat returns the format of the given name
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatJSON, FormatCombined, FormatLogfmt:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown access log format %q: expected json, combined or logfmt", name)
+}
+
+// Entry describes a served request
+type Entry struct {
+	Time      time.Time     `json:"time"`
+	RemoteIP  string        `json:"remote_ip"`
+	Method    string        `json:"method"`
+	URI       string        `json:"uri"`
+	Proto     string        `json:"proto"`
+	Route     string        `json:"route,omitempty"`
+	Status    int           `json:"status"`
+	BytesIn   int64         `json:"bytes_in"`
+	BytesOut  int64         `json:"bytes_out"`
+	Duration  time.Duration `json:"-"`
+	UserAgent string        `json:"user_agent,omitempty"`
+	Referrer  string        `json:"referrer,omitempty"`
+	UserID    string        `json:"user_id,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// Config sets the behaviour of a Logger
+type Config struct {
+	// Format of the lines, FormatJSON if empty
+	Format Format
+	// SampleRate logs one in SampleRate successful requests of the sampled routes, all of them if below 2.
+	// Requests answered with an error status are always logged.
+	SampleRate int
+	// SampleRoutes are the chi route patterns sampled, such as "/{id}"
+	SampleRoutes []string
+}
+
+// Logger writes the access log
+type Logger struct {
+	format     Format
+	sampleRate uint64
+	// sampled counts the requests of the sampled routes, to log one in sampleRate
+	sampled map[string]*atomic.Uint64
+
+	mu  sync.Mutex
+	out io.Writer
+	buf []byte
+}
+
+// New creates a logger writing the access log to out
+func New(out io.Writer, config Config) *Logger {
+	l := &Logger{
+		format:  config.Format,
+		out:     out,
+		sampled: make(map[string]*atomic.Uint64),
+	}
+	if l.format == "" {
+		l.format = FormatJSON
+	}
+	if config.SampleRate > 1 {
+		l.sampleRate = uint64(config.SampleRate)
+		for _, route := range config.SampleRoutes {
+			l.sampled[route] = new(atomic.Uint64)
+		}
+	}
+	return l
+}
+
+// Log writes the line of an entry, with its secrets and personal data redacted like the application logs
+func (l *Logger) Log(e Entry) error {
+	e.URI = logger.RedactString(e.URI)
+	e.Referrer = logger.RedactURL(e.Referrer)
+	e.UserID = logger.RedactUserID(e.UserID)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = l.buf[:0]
+	switch l.format {
+	case FormatCombined:
+		l.buf = appendCombined(l.buf, e)
+	case FormatLogfmt:
+		l.buf = appendLogfmt(l.buf, e)
+	default:
+		l.buf = appendJSON(l.buf, e)
+	}
+	l.buf = append(l.buf, '\n')
+	_, err := l.out.Write(l.buf)
+	return err
+}
+
+// sample reports whether the request of a route answered with the status is logged
+func (l *Logger) sample(route string, status int) bool {
+	counter, ok := l.sampled[route]
+	if !ok || status >= http.StatusBadRequest {
+		return true
+	}
+	return (counter.Add(1)-1)%l.sampleRate == 0
+}
+
+// entryContextKey is the context key of the entry of the request being served
+type entryContextKey struct{}
+
+// SetUserID records the user the request is served for in its access log entry.
+// It does nothing outside a request served through Middleware.
+func SetUserID(ctx context.Context, userID string) {
+	if e, ok := ctx.Value(entryContextKey{}).(*Entry); ok {
+		e.UserID = userID
+	}
+}
+
+// Middleware writes an access log line for every request. It must be installed on the chi router,
+// whose routing sets the route pattern, after the logger.RequestID middleware.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &Entry{
+			Time:      time.Now(),
+			RemoteIP:  remoteIP(r),
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Proto:     r.Proto,
+			UserAgent: r.UserAgent(),
+			Referrer:  r.Referer(),
+			RequestID: logger.RequestIDFromContext(r.Context()),
+		}
+
+		var body *countingBody
+		if r.Body != nil && r.Body != http.NoBody {
+			body = &countingBody{ReadCloser: r.Body}
+			r.Body = body
+		}
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), entryContextKey{}, entry)))
+
+		entry.Duration = time.Since(entry.Time)
+		entry.Status = rec.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.BytesOut = rec.size
+		if body != nil {
+			entry.BytesIn = body.size
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			entry.Route = rctx.RoutePattern()
+		}
+
+		if l.sample(entry.Route, entry.Status) {
+			if err := l.Log(*entry); err != nil {
+				logger.FromContext(r.Context()).Sugar().Errorw("Failed to write access log", "error", err)
+			}
+		}
+	})
+}
+
+// remoteIP returns the IP address of the client connection
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// countingBody counts the bytes of the request body read by the handler
+type countingBody struct {
+	io.ReadCloser
+	size int64
+}
+
+// Read implements io.Reader
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+// responseRecorder captures the status code and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// WriteHeader captures the first status code written
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write counts the bytes of the response body
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// jsonEntry is the JSON representation of an entry, with the duration in milliseconds
+type jsonEntry struct {
+	Entry
+	DurationMillis float64 `json:"duration_ms"`
+}
+
+// appendJSON appends the JSON line of an entry
+func appendJSON(buf []byte, e Entry) []byte {
+	e.Time = e.Time.UTC()
+	data, err := json.Marshal(jsonEntry{Entry: e, DurationMillis: float64(e.Duration.Microseconds()) / 1000})
+	if err != nil {
+		// Entries hold only strings and numbers, this can't happen
+		return append(buf, `{"error":"access log entry not encodable"}`...)
+	}
+	return append(buf, data...)
+}
+
+// combinedTimeLayout is the time layout of the Apache logs
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// appendCombined appends the Apache combined log line of an entry:
+// host ident user [time] "request" status bytes "referrer" "user agent"
+func appendCombined(buf []byte, e Entry) []byte {
+	buf = append(buf, orDash(e.RemoteIP)...)
+	buf = append(buf, " - "...)
+	buf = append(buf, orDash(e.UserID)...)
+	buf = append(buf, " ["...)
+	buf = e.Time.AppendFormat(buf, combinedTimeLayout)
+	buf = append(buf, "] "...)
+	buf = appendQuoted(buf, e.Method+" "+e.URI+" "+e.Proto)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(e.Status), 10)
+	buf = append(buf, ' ')
+	if e.BytesOut == 0 {
+		buf = append(buf, '-')
+	} else {
+		buf = strconv.AppendInt(buf, e.BytesOut, 10)
+	}
+	buf = append(buf, ' ')
+	buf = appendQuoted(buf, orDash(e.Referrer))
+	buf = append(buf, ' ')
+	return appendQuoted(buf, orDash(e.UserAgent))
+}
+
+// orDash returns the value, or the dash standing for a missing value in the combined format
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// appendQuoted appends a double-quoted value, escaping quotes, backslashes and control characters
+// so a client can't forge log lines
+func appendQuoted(buf []byte, value string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c < 0x20 || c == 0x7f:
+			buf = append(buf, `\x`...)
+			buf = append(buf, "0123456789abcdef"[c>>4], "0123456789abcdef"[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '"')
+}
+
+// appendLogfmt appends the logfmt line of an entry, leaving out the empty optional values
+func appendLogfmt(buf []byte, e Entry) []byte {
+	pairs := [][2]string{
+		{"time", e.Time.UTC().Format(time.RFC3339Nano)},
+		{"remote_ip", e.RemoteIP},
+		{"method", e.Method},
+		{"uri", e.URI},
+		{"proto", e.Proto},
+		{"route", e.Route},
+		{"status", strconv.Itoa(e.Status)},
+		{"bytes_in", strconv.FormatInt(e.BytesIn, 10)},
+		{"bytes_out", strconv.FormatInt(e.BytesOut, 10)},
+		{"duration_ms", strconv.FormatFloat(float64(e.Duration.Microseconds())/1000, 'f', -1, 64)},
+		{"user_agent", e.UserAgent},
+		{"referrer", e.Referrer},
+		{"user_id", e.UserID},
+		{"request_id", e.RequestID},
+	}
+	first := true
+	for _, pair := range pairs {
+		if pair[1] == "" {
+			continue
+		}
+		if !first {
+			buf = append(buf, ' ')
+		}
+		first = false
+		buf = append(buf, pair[0]...)
+		buf = append(buf, '=')
+		if needsQuoting(pair[1]) {
+			buf = appendQuoted(buf, pair[1])
+		} else {
+			buf = append(buf, pair[1]...)
+		}
+	}
+	return buf
+}
+
+// needsQuoting reports whether a logfmt value must be quoted
+func needsQuoting(value string) bool {
+	return slices.ContainsFunc([]byte(value), func(c byte) bool {
+		return c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f
+	})
+}
@@ -0,0 +1,225 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEntry is a request as logged by every format
+var testEntry = Entry{
+	Time:      time.Date(2024, 3, 9, 14, 5, 7, 0, time.FixedZone("MSK", 3*60*60)),
+	RemoteIP:  "192.0.2.10",
+	Method:    http.MethodPost,
+	URI:       "/api/shorten",
+	Proto:     "HTTP/1.1",
+	Route:     "/api/shorten",
+	Status:    http.StatusCreated,
+	BytesIn:   42,
+	BytesOut:  38,
+	Duration:  1500 * time.Microsecond,
+	UserAgent: `curl/8.5 "quoted"`,
+	UserID:    "user1",
+	RequestID: "req-1",
+}
+
+func TestLogFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		want   string
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			want: `{"time":"2024-03-09T11:05:07Z","remote_ip":"192.0.2.10","method":"POST","uri":"/api/shorten","proto":"HTTP/1.1",` +
+				`"route":"/api/shorten","status":201,"bytes_in":42,"bytes_out":38,"user_agent":"curl/8.5 \"quoted\"",` +
+				`"user_id":"user1","request_id":"req-1","duration_ms":1.5}` + "\n",
+		},
+		{
+			name:   "combined",
+			format: FormatCombined,
+			want:   `192.0.2.10 - user1 [09/Mar/2024:14:05:07 +0300] "POST /api/shorten HTTP/1.1" 201 38 "-" "curl/8.5 \"quoted\""` + "\n",
+		},
+		{
+			name:   "logfmt",
+			format: FormatLogfmt,
+			want: `time=2024-03-09T11:05:07Z remote_ip=192.0.2.10 method=POST uri=/api/shorten proto=HTTP/1.1 route=/api/shorten ` +
+				`status=201 bytes_in=42 bytes_out=38 duration_ms=1.5 user_agent="curl/8.5 \"quoted\"" user_id=user1 request_id=req-1` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, New(&out, Config{Format: tt.format}).Log(testEntry))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestCombinedEscapesControlCharacters(t *testing.T) {
+	var out bytes.Buffer
+	entry := testEntry
+	entry.UserAgent = "evil\n127.0.0.1 - - [forged]"
+	require.NoError(t, New(&out, Config{Format: FormatCombined}).Log(entry))
+
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"), "a client can't start a new line")
+	assert.Contains(t, out.String(), `"evil\x0a127.0.0.1 - - [forged]"`)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("Combined")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCombined, format)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(logger.RequestID)
+	r.Use(New(&out, Config{}).Middleware)
+	r.Post("/api/shorten", func(w http.ResponseWriter, r *http.Request) {
+		SetUserID(r.Context(), "user1")
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"result":"http://localhost:8080/abc123"}`))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://practicum.yandex.ru"}`))
+	req.RemoteAddr = "192.0.2.10:54321"
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set(logger.RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "192.0.2.10", entry["remote_ip"])
+	assert.Equal(t, "/api/shorten", entry["route"])
+	assert.Equal(t, float64(http.StatusCreated), entry["status"])
+	assert.Equal(t, float64(37), entry["bytes_in"])
+	assert.Equal(t, float64(41), entry["bytes_out"])
+	assert.Equal(t, "test-agent", entry["user_agent"])
+	assert.Equal(t, "https://example.com/", entry["referrer"])
+	assert.Equal(t, "user1", entry["user_id"])
+	assert.Equal(t, "req-1", entry["request_id"])
+}
+
+func TestMiddlewareSampling(t *testing.T) {
+	var out bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(New(&out, Config{SampleRate: 3, SampleRoutes: []string{"/{id}"}}).Middleware)
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {})
+
+	for range 6 {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc123", nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 4, "2 of 6 redirects, the error and the unsampled route")
+	assert.Contains(t, lines[2], `"status":404`)
+	assert.Contains(t, lines[3], `"route":"/ping"`)
+}
+
+func TestFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	now := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+
+	f, err := OpenFile(path, Rotation{MaxSize: 10, Interval: time.Hour, MaxBackups: 2})
+	require.NoError(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+
+	write := func(line string) {
+		t.Helper()
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	write("0123456789abc\n") // longer than the maximal size, still written to the empty file
+	now = now.Add(time.Second)
+	write("second\n") // rotated by size
+	write("third\n")  // rotated by size within the same millisecond
+	now = now.Add(time.Second)
+	write("4\n") // fits
+	now = now.Add(time.Hour)
+	write("5\n") // rotated by age, the oldest backup is removed
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "5\n", string(current))
+
+	backups, err := f.Backups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{path + ".20240309T130002.000", path + ".20240309T120001.000-1"}, backups)
+	for i, want := range []string{"third\n4\n", "second\n"} {
+		content, err := os.ReadFile(backups[i])
+		require.NoError(t, err)
+		assert.Equal(t, want, string(content))
+	}
+}
+
+func TestOpenStdout(t *testing.T) {
+	out, err := Open(Stdout, Rotation{})
+	require.NoError(t, err)
+	assert.NoError(t, out.Close())
+}
+
+func TestFileRotationFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	now := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+
+	f, err := OpenFile(path, Rotation{MaxSize: 10})
+	require.NoError(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+	renameErr := errors.New("rename failed")
+	f.rename = func(string, string) error { return renameErr }
+
+	_, err = f.Write([]byte("0123456789\n"))
+	require.NoError(t, err)
+	n, err := f.Write([]byte("second\n")) // the rotation fails, the line is still written
+	assert.ErrorIs(t, err, renameErr)
+	assert.Equal(t, len("second\n"), n)
+
+	f.rename = os.Rename
+	now = now.Add(time.Second)
+	_, err = f.Write([]byte("third\n")) // the rotation is retried
+	require.NoError(t, err)
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(current))
+	backups, err := f.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	content, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789\nsecond\n", string(content))
+}
@@ -0,0 +1,229 @@
+package accesslog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stdout is the output name writing the access log to the standard output
+const Stdout = "stdout"
+
+// Rotation sets when the file of the access log is rotated and how many rotated files are kept.
+// Zero values disable the matching rule.
+type Rotation struct {
+	// MaxSize is the size in bytes above which the file is rotated
+	MaxSize int64
+	// Interval is the age of the file after which it is rotated
+	Interval time.Duration
+	// MaxBackups is the number of rotated files kept
+	MaxBackups int
+	// MaxAge is the age after which rotated files are removed
+	MaxAge time.Duration
+}
+
+// backupTimeLayout names the rotated files after the time of their rotation, so they sort by age
+const backupTimeLayout = "20060102T150405.000"
+
+// File is a log file rotated by size and age. Rotated files are renamed after the time
+// of their rotation, as path.20060102T150405.000, and removed past the retention limits.
+type File struct {
+	path     string
+	rotation Rotation
+	now      func() time.Time
+	rename   func(oldpath, newpath string) error
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// OpenFile opens the log file at path for appending, creating it if needed
+func OpenFile(path string, rotation Rotation) (*File, error) {
+	f := &File{path: path, rotation: rotation, now: time.Now, rename: os.Rename}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Open returns the output of the access log: the standard output for Stdout or an empty name,
+// otherwise the file at path rotated as configured
+func Open(path string, rotation Rotation) (io.WriteCloser, error) {
+	if path == "" || path == Stdout {
+		return nopCloser{os.Stdout}, nil
+	}
+	return OpenFile(path, rotation)
+}
+
+// nopCloser leaves the standard output open when the access log is closed
+type nopCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer
+func (nopCloser) Close() error {
+	return nil
+}
+
+// open opens the file at its path, appending to it
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("create access log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("open access log: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// Write appends to the file, rotating it first if the write would exceed the maximal size
+// or the file is older than the rotation interval. A failed rotation is reported, but
+// the line is still written to whichever file is open.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if f.needsRotation(int64(len(p))) {
+		rotateErr = f.rotate()
+		if f.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// needsRotation reports whether the file must be rotated before writing size bytes.
+// A file is never rotated empty, so lines longer than the maximal size are still written.
+func (f *File) needsRotation(size int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.rotation.MaxSize > 0 && f.size+size > f.rotation.MaxSize {
+		return true
+	}
+	return f.rotation.Interval > 0 && f.now().Sub(f.opened) >= f.rotation.Interval
+}
+
+// rotate renames the file after the current time, opens a new one and removes old rotated files.
+// When the file cannot be renamed, it is reopened at its path and the rotation is skipped.
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("close access log: %w", err)
+	}
+	f.file = nil
+
+	backup := f.path + "." + f.now().UTC().Format(backupTimeLayout)
+	for i := 1; fileExists(backup); i++ {
+		backup = fmt.Sprintf("%s.%s-%d", f.path, f.now().UTC().Format(backupTimeLayout), i)
+	}
+	if err := f.rename(f.path, backup); err != nil {
+		err = fmt.Errorf("rotate access log: %w", err)
+		// The age of the file is kept, so the rotation is retried on a later write
+		opened := f.opened
+		if openErr := f.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		f.opened = opened
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.removeOldBackups()
+}
+
+// removeOldBackups removes the rotated files beyond the number kept or older than the maximal age
+func (f *File) removeOldBackups() error {
+	if f.rotation.MaxBackups <= 0 && f.rotation.MaxAge <= 0 {
+		return nil
+	}
+
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+
+	var remove []string
+	for i, backup := range backups {
+		// Backups are sorted from the newest
+		if f.rotation.MaxBackups > 0 && i >= f.rotation.MaxBackups {
+			remove = append(remove, backup)
+			continue
+		}
+		if f.rotation.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && f.now().Sub(info.ModTime()) > f.rotation.MaxAge {
+				remove = append(remove, backup)
+			}
+		}
+	}
+	for _, backup := range remove {
+		if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove rotated access log: %w", err)
+		}
+	}
+	return nil
+}
+
+// Backups returns the paths of the rotated files, from the newest
+func (f *File) Backups() ([]string, error) {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	prefix := f.path + "."
+	backups := matches[:0]
+	for _, match := range matches {
+		if _, err := time.Parse(backupTimeLayout, strings.SplitN(strings.TrimPrefix(match, prefix), "-", 2)[0]); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// Close closes the file, writes fail afterwards
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// fileExists reports whether a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,267 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mailru/easyjson"
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultAdminPageSize is the page size used when the limit parameter is not set
+	defaultAdminPageSize = 100
+	// maxAdminPageSize is the largest page size accepted by the admin API
+	maxAdminPageSize = 1000
+)
+
+// AdminMiddleware restricts access to the admin API to requests carrying
+// the configured admin token as a bearer token.
+// If no admin token is configured the admin API is disabled and returns 403 Forbidden.
+func (h *Handler) AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.adminToken == "" {
+			logger.HTTPError(w, r, "admin API disabled", http.StatusForbidden)
+			return
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			logger.HTTPError(w, r, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// AdminListURLsHandler handles GET /api/admin/urls requests.
+// It lists the URLs of all users ordered by token, optionally filtered by
+// the search and user_id query parameters and paginated with offset and limit.
+func (h *Handler) AdminListURLsHandler(w http.ResponseWriter, r *http.Request) {
+	h.adminListURLs(w, r, r.URL.Query().Get("user_id"))
+}
+
+// AdminUserURLsHandler handles GET /api/admin/users/{userID}/urls requests.
+// It lists all URLs of a user, including deleted and disabled ones.
+func (h *Handler) AdminUserURLsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		logger.HTTPError(w, r, "bad request: incorrect user ID", http.StatusBadRequest)
+		return
+	}
+	h.adminListURLs(w, r, userID)
+}
+
+// adminListURLs writes a page of URLs matching the query parameters and the user ID
+func (h *Handler) adminListURLs(w http.ResponseWriter, r *http.Request, userID string) {
+	if r.Method != http.MethodGet {
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		logger.HTTPError(w, r, "bad request: incorrect offset", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(query.Get("limit"), defaultAdminPageSize)
+	if err != nil || limit <= 0 || limit > maxAdminPageSize {
+		logger.HTTPError(w, r, "bad request: incorrect limit", http.StatusBadRequest)
+		return
+	}
+
+	nodes, total, err := h.storage.ListURLs(r.Context(), storage.ListFilter{
+		UserID: userID,
+		Search: query.Get("search"),
+		Offset: offset,
+		Limit:  limit,
+	})
+	if err != nil {
+		h.requestLogger(r).Error("Error listing URLs", zap.Error(err))
+		logger.HTTPError(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := mod.AdminURLList{
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+		Items:  make([]mod.AdminURL, len(nodes)),
+	}
+	for i, node := range nodes {
+		response.Items[i] = mod.AdminURL{
+			Token:       node.ShortURL,
+			ShortURL:    h.constructURL(node.ShortURL, r),
+			OriginalURL: node.OriginalURL,
+			UserID:      node.UserID,
+			IsDeleted:   node.IsDeleted,
+			IsDisabled:  node.IsDisabled,
+		}
+	}
+
+	responseBytes, err := easyjson.Marshal(response)
+	if err != nil {
+		logger.HTTPError(w, r, "internal server error: unable to marshal response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBytes)
+}
+
+// AdminDisableURLHandler handles POST /api/admin/urls/{token}/disable requests.
+// A disabled URL no longer redirects until it is enabled again.
+func (h *Handler) AdminDisableURLHandler(w http.ResponseWriter, r *http.Request) {
+	h.adminUpdateURL(w, r, func(ctx context.Context, token string) error {
+		return h.storage.SetURLDisabled(ctx, token, true)
+	})
+}
+
+// AdminEnableURLHandler handles POST /api/admin/urls/{token}/enable requests
+func (h *Handler) AdminEnableURLHandler(w http.ResponseWriter, r *http.Request) {
+	h.adminUpdateURL(w, r, func(ctx context.Context, token string) error {
+		return h.storage.SetURLDisabled(ctx, token, false)
+	})
+}
+
+// AdminSetURLOwnerHandler handles PUT /api/admin/urls/{token}/owner requests.
+// It transfers the URL to the user given in the JSON body.
+func (h *Handler) AdminSetURLOwnerHandler(w http.ResponseWriter, r *http.Request) {
+	var body mod.OwnerRequest
+	err := easyjson.UnmarshalFromReader(r.Body, &body)
+	defer r.Body.Close()
+
+	if err != nil || body.UserID == "" {
+		bodyError(w, r, err, "bad request: incorrect user ID")
+		return
+	}
+
+	h.adminUpdateURL(w, r, func(ctx context.Context, token string) error {
+		return h.storage.SetURLOwner(ctx, token, body.UserID)
+	})
+}
+
+// AdminDeleteURLHandler handles DELETE /api/admin/urls/{token} requests.
+// Unlike user deletion the URL is removed permanently.
+func (h *Handler) AdminDeleteURLHandler(w http.ResponseWriter, r *http.Request) {
+	h.adminUpdateURL(w, r, h.storage.RemoveURL)
+}
+
+// adminUpdateURL applies an update to the URL identified by the token path parameter
+func (h *Handler) adminUpdateURL(w http.ResponseWriter, r *http.Request, update func(ctx context.Context, token string) error) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		logger.HTTPError(w, r, "bad request: incorrect token", http.StatusBadRequest)
+		return
+	}
+
+	if err := update(r.Context(), token); err != nil {
+		if errors.Is(err, storage.ErrURLNotFound) {
+			logger.HTTPError(w, r, "URL not found", http.StatusNotFound)
+			return
+		}
+		h.requestLogger(r).Error("Error updating URL", zap.String("token", token), zap.Error(err))
+		logger.HTTPError(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.requestLogger(r).Info("Admin updated URL", zap.String("token", token), zap.String("path", r.URL.Path))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminReloadConfigHandler handles POST /api/admin/config/reload requests.
+// It reloads the configuration like SIGHUP does and returns the changes needing a restart as warnings.
+// An invalid configuration is rejected with 422 Unprocessable Entity and the running one is kept.
+func (h *Handler) AdminReloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	warnings, err := h.config.Reload()
+	if err != nil {
+		h.requestLogger(r).Warn("Configuration reload rejected", zap.Error(err))
+		logger.HTTPError(w, r, "invalid configuration: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	for _, warning := range warnings {
+		h.requestLogger(r).Warn("Configuration change not applied", zap.String("warning", warning))
+	}
+	h.requestLogger(r).Info("Configuration reloaded by admin API")
+
+	response := mod.ConfigReloadResponse{Warnings: warnings}
+	if response.Warnings == nil {
+		response.Warnings = []string{}
+	}
+	responseBytes, err := easyjson.Marshal(response)
+	if err != nil {
+		logger.HTTPError(w, r, "internal server error: unable to marshal response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBytes)
+}
+
+// queryInt parses an integer query parameter, returning the default value if it is empty
+func queryInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// AdminGetLogLevelHandler handles GET /api/admin/log-level requests.
+// It returns the current minimal level of logged messages.
+func (h *Handler) AdminGetLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeLogLevel(w, r)
+}
+
+// AdminSetLogLevelHandler handles PUT /api/admin/log-level requests.
+// It changes the minimal level of logged messages at once, until the next configuration reload,
+// and returns 400 Bad Request for an unknown level.
+func (h *Handler) AdminSetLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var body mod.LogLevel
+	err := easyjson.UnmarshalFromReader(r.Body, &body)
+	defer r.Body.Close()
+
+	if err != nil || body.Level == "" {
+		bodyError(w, r, err, "bad request: incorrect log level")
+		return
+	}
+
+	previous := logger.Level()
+	if err := logger.SetLevel(body.Level); err != nil {
+		logger.HTTPError(w, r, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.requestLogger(r).Warn("Log level changed by admin API", zap.String("from", previous), zap.String("to", logger.Level()))
+	writeLogLevel(w, r)
+}
+
+// writeLogLevel responds with the current log level
+func writeLogLevel(w http.ResponseWriter, r *http.Request) {
+	responseBytes, err := easyjson.Marshal(mod.LogLevel{Level: logger.Level()})
+	if err != nil {
+		logger.HTTPError(w, r, "internal server error: unable to marshal response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBytes)
+}
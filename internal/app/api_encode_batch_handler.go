@@ -5,16 +5,18 @@ import (
 	"net/http"
 
 	"github.com/mailru/easyjson"
+	"github.com/pcristin/urlshortener/internal/logger"
 	mod "github.com/pcristin/urlshortener/internal/models"
-	"github.com/pcristin/urlshortener/internal/storage"
-	uu "github.com/pcristin/urlshortener/internal/urlutils"
-	"go.uber.org/zap"
+	"github.com/pcristin/urlshortener/internal/service"
 )
 
-// APIEncodeBatchHandler encodes a batch of sent urls
+// APIEncodeBatchHandler encodes a batch of sent urls.
+// Every item gets a status: "created", "exists", "invalid" or "error". The response is
+// 201 Created if every item has a short URL, 207 Multi-Status otherwise and 500 Internal Server Error
+// if the storage failed to write the batch.
 func (h *Handler) APIEncodeBatchHandler(res http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
-		http.Error(res, "bad request", http.StatusBadRequest)
+		logger.HTTPError(res, req, "bad request", http.StatusBadRequest)
 		return
 	}
 
@@ -23,42 +25,61 @@ func (h *Handler) APIEncodeBatchHandler(res http.ResponseWriter, req *http.Reque
 	defer req.Body.Close()
 
 	if err != nil {
-		http.Error(res, "bad request: invalid JSON", http.StatusBadRequest)
+		bodyError(res, req, err, "bad request: invalid JSON")
 		return
 	}
 
-	if len(batchRequests) == 0 {
-		http.Error(res, "bad request: empty batch", http.StatusBadRequest)
-		return
+	longURLs := make([]string, len(batchRequests))
+	for i, item := range batchRequests {
+		longURLs[i] = item.OriginalURL
 	}
 
-	// Get user ID from context
-	userID := getUserIDFromContext(req.Context())
+	results, err := h.shortener.ShortenBatch(h.creatorContext(req, mod.SourceBatch), getUserIDFromContext(req.Context()), longURLs)
+	switch {
+	case errors.Is(err, service.ErrEmptyBatch):
+		logger.HTTPError(res, req, "bad request: empty batch", http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrBatchTooLarge):
+		logger.HTTPError(res, req, "request entity too large: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		h.requestLogger(req).Sugar().Errorw("Error encoding batch", "error", err)
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	// Process URLs and collect responses
-	responses := make(mod.BatchResponse, 0, len(batchRequests))
+	// Collect responses in the order of the request, the batch is a partial success
+	// as soon as one item has no short URL
+	status := http.StatusCreated
+	responses := make(mod.BatchResponse, len(batchRequests))
+	for i, item := range batchRequests {
+		result := results[i]
+		responses[i] = mod.BatchResponseItem{
+			CorrelationID: item.CorrelationID,
+			Status:        string(result.Status),
+		}
 
-	for _, item := range batchRequests {
-		token, err := uu.EncodeURL(item.OriginalURL, h.storage, userID)
-		if err != nil && !errors.Is(err, storage.ErrURLExists) {
-			zap.L().Sugar().Errorw("Error encoding URL", "error", err, "url", item.OriginalURL)
-			http.Error(res, "internal server error", http.StatusInternalServerError)
-			return
+		switch result.Status {
+		case service.BatchCreated, service.BatchExists:
+			responses[i].ShortURL = h.constructURL(result.Token, req)
+			continue
+		case service.BatchInvalid:
+			responses[i].Error = result.Err.Error()
+		default:
+			h.requestLogger(req).Sugar().Errorw("Error encoding batch item", "error", result.Err, "url", item.OriginalURL)
+			responses[i].Error = "internal server error"
 		}
-		responses = append(responses, mod.BatchResponseItem{
-			CorrelationID: item.CorrelationID,
-			ShortURL:      h.constructURL(token, req),
-		})
+		status = http.StatusMultiStatus
 	}
 
-	// Send response
-	res.Header().Set("Content-Type", "application/json")
-	res.WriteHeader(http.StatusCreated)
-
 	responseBytes, err := easyjson.Marshal(responses)
 	if err != nil {
-		http.Error(res, "internal server error: unable to marshal response", http.StatusInternalServerError)
+		logger.HTTPError(res, req, "internal server error: unable to marshal response", http.StatusInternalServerError)
 		return
 	}
+
+	// Send response
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
 	res.Write(responseBytes)
 }
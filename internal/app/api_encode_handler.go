@@ -1,19 +1,17 @@
 package app
 
 import (
-	"errors"
+	"github.com/pcristin/urlshortener/internal/logger"
 	"net/http"
 
 	"github.com/mailru/easyjson"
 	mod "github.com/pcristin/urlshortener/internal/models"
-	"github.com/pcristin/urlshortener/internal/storage"
-	uu "github.com/pcristin/urlshortener/internal/urlutils"
 )
 
 // Handler to encode the url with compressed data
 func (h *Handler) APIEncodeHandler(res http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
-		http.Error(res, "bad request", http.StatusBadRequest)
+		logger.HTTPError(res, req, "bad request", http.StatusBadRequest)
 		return
 	}
 
@@ -22,46 +20,26 @@ func (h *Handler) APIEncodeHandler(res http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
 	if err != nil || len(body.URL) == 0 {
-		http.Error(res, "bad request: incorrect url", http.StatusBadRequest)
+		bodyError(res, req, err, "bad request: incorrect url")
 		return
 	}
 
-	// Get user ID from context
-	userID := getUserIDFromContext(req.Context())
-
-	// Encode the long URL to a short URL
-	shortURL, err := uu.EncodeURL(body.URL, h.storage, userID)
-	if err != nil {
-		if errors.Is(err, storage.ErrURLExists) {
-			response := mod.Response{
-				Result: h.constructURL(shortURL, req),
-			}
-			res.Header().Set("Content-Type", "application/json")
-			res.WriteHeader(http.StatusConflict)
-			responseBytes, err := easyjson.Marshal(response)
-			if err != nil {
-				http.Error(res, "internal server error: unable to marshal response", http.StatusInternalServerError)
-				return
-			}
-			res.Write(responseBytes)
-			return
-		}
-		http.Error(res, "bad request: unable to shorten provided url", http.StatusBadRequest)
+	status, token, ok := h.shorten(res, req, mod.SourceJSON, body.URL)
+	if !ok {
 		return
 	}
 
 	// Prepare the response payload
 	response := mod.Response{
-		Result: h.constructURL(shortURL, req),
+		Result: h.constructURL(token, req),
 	}
 
-	res.Header().Set("Content-Type", "application/json")
-	res.WriteHeader(http.StatusCreated)
-
 	responseBytes, err := easyjson.Marshal(response)
 	if err != nil {
-		http.Error(res, "internal server error: unable to marshal response", http.StatusInternalServerError)
+		logger.HTTPError(res, req, "internal server error: unable to marshal response", http.StatusInternalServerError)
 		return
 	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
 	res.Write(responseBytes)
 }
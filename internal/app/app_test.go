@@ -6,56 +6,79 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mailru/easyjson"
 	"github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/deletion"
 	"github.com/pcristin/urlshortener/internal/logger"
 	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/oidc"
+	"github.com/pcristin/urlshortener/internal/oidc/oidctest"
 	"github.com/pcristin/urlshortener/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 const (
-	MemoryStorage   = storage.StorageType(0)
-	FileStorage     = storage.StorageType(1)
-	DatabaseStorage = storage.StorageType(2)
-	testUserID      = "test-user-id"
-	testSecret      = "test-secret-key"
+	testUserID     = "test-user-id"
+	testSecret     = "test-secret-key"
+	testAdminToken = "test-admin-token"
 )
 
 func setupTestConfig() *config.Options {
 	cfg := config.NewOptions()
 	os.Setenv("SECRET_URL_SERVICE", testSecret)
+	os.Setenv("ADMIN_TOKEN", testAdminToken)
 	// Don't parse flags in tests to avoid flag redefinition
 	cfg.LoadEnvVariables()
 	return cfg
 }
 
+// newTestHandler creates a handler with a deletion queue configured like the server's,
+// closed when the test ends
+func newTestHandler(tb testing.TB, urlStorage storage.URLStorager, cfg *config.Options) HandlerInterface {
+	tb.Helper()
+	queue, err := deletion.NewQueue(urlStorage, deletion.Config{
+		Size:          cfg.GetDeleteQueueSize(),
+		Workers:       cfg.GetDeleteWorkers(),
+		FlushInterval: cfg.GetDeleteFlushInterval(),
+	})
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _ = queue.Close(context.Background()) })
+	return NewHandler(urlStorage, cfg, queue)
+}
+
 // MockStorage implements URLStorager interface
 type MockStorage struct {
-	urls        map[string]mod.URLStorageNode
-	filepath    string
-	storageType storage.StorageType
-	dbPool      *pgxpool.Pool
+	urls      map[string]mod.URLStorageNode
+	users     map[string]mod.User
+	filepath  string
+	healthErr error
+	batchErr  error
 }
 
-func NewMockStorage(storageType storage.StorageType) *MockStorage {
+func NewMockStorage() *MockStorage {
 	return &MockStorage{
-		urls:        make(map[string]mod.URLStorageNode),
-		storageType: storageType,
+		urls:  make(map[string]mod.URLStorageNode),
+		users: make(map[string]mod.User),
 	}
 }
 
-func (m *MockStorage) AddURL(token, longURL string, userID string) error {
+func (m *MockStorage) AddURL(ctx context.Context, token, longURL string, userID string) error {
 	if token == "" || longURL == "" {
 		return errors.New("token and URL cannot be empty")
 	}
@@ -65,35 +88,41 @@ func (m *MockStorage) AddURL(token, longURL string, userID string) error {
 			return storage.ErrURLExists
 		}
 	}
+	creator := storage.CreatorFromContext(ctx)
 	m.urls[token] = mod.URLStorageNode{
-		UUID:        uuid.New(),
-		ShortURL:    token,
-		OriginalURL: longURL,
-		UserID:      userID,
+		UUID:         uuid.New(),
+		ShortURL:     token,
+		OriginalURL:  longURL,
+		UserID:       userID,
+		Source:       creator.Source,
+		ClientIPHash: creator.ClientIPHash,
 	}
 	return nil
 }
 
-func (m *MockStorage) GetURL(token string) (string, error) {
+func (m *MockStorage) GetURL(ctx context.Context, token string) (string, error) {
 	if node, ok := m.urls[token]; ok {
 		if node.IsDeleted {
 			return "", storage.ErrURLDeleted
 		}
+		if node.IsDisabled {
+			return "", storage.ErrURLDisabled
+		}
 		return node.OriginalURL, nil
 	}
-	return "", errors.New("URL not found")
+	return "", storage.ErrURLNotFound
 }
 
-func (m *MockStorage) GetTokenByURL(longURL string) (string, error) {
+func (m *MockStorage) GetTokenByURL(ctx context.Context, longURL string) (string, error) {
 	for _, node := range m.urls {
 		if node.OriginalURL == longURL {
 			return node.ShortURL, nil
 		}
 	}
-	return "", errors.New("url not found")
+	return "", storage.ErrURLNotFound
 }
 
-func (m *MockStorage) GetUserURLs(userID string) ([]mod.URLStorageNode, error) {
+func (m *MockStorage) GetUserURLs(ctx context.Context, userID string) ([]mod.URLStorageNode, error) {
 	var result []mod.URLStorageNode
 	for _, node := range m.urls {
 		if node.UserID == userID {
@@ -103,6 +132,22 @@ func (m *MockStorage) GetUserURLs(userID string) ([]mod.URLStorageNode, error) {
 	return result, nil
 }
 
+func (m *MockStorage) QueryUserURLs(ctx context.Context, query storage.UserURLQuery) ([]mod.URLStorageNode, error) {
+	result := make([]mod.URLStorageNode, 0)
+	for _, node := range m.urls {
+		if query.Matches(node) {
+			result = append(result, node)
+		}
+	}
+	slices.SortFunc(result, func(a, b mod.URLStorageNode) int {
+		return query.Compare(storage.PositionOf(a), storage.PositionOf(b))
+	})
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+	return result, nil
+}
+
 func (m *MockStorage) SaveToFile() error {
 	if m.filepath == "" {
 		return nil
@@ -152,39 +197,56 @@ func (m *MockStorage) LoadFromFile(filepath string) error {
 	return scanner.Err()
 }
 
-func (m *MockStorage) SetDBPool(pool *pgxpool.Pool) {
-	m.dbPool = pool
+func (m *MockStorage) Backend() string {
+	return "mock"
 }
 
-func (m *MockStorage) GetStorageType() storage.StorageType {
-	return m.storageType
+func (m *MockStorage) Close() error {
+	return nil
 }
 
-func (m *MockStorage) GetDBPool() *pgxpool.Pool {
-	if m.storageType == DatabaseStorage {
-		if m.dbPool == nil {
-			config, _ := pgxpool.ParseConfig("")
-			pool, _ := pgxpool.NewWithConfig(context.Background(), config)
-			m.dbPool = pool
-		}
-		return m.dbPool
-	}
-	return nil
+func (m *MockStorage) CheckHealth(ctx context.Context) error {
+	return m.healthErr
 }
 
-func (m *MockStorage) AddURLBatch(urls map[string]string) error {
+func (m *MockStorage) AddURLBatch(ctx context.Context, userID string, urls map[string]string) (map[string]string, error) {
 	if len(urls) == 0 {
-		return errors.New("batch cannot be empty")
+		return nil, errors.New("batch cannot be empty")
+	}
+	if m.batchErr != nil {
+		return nil, m.batchErr
 	}
+	existing := make(map[string]string)
 	for token, longURL := range urls {
-		if err := m.AddURL(token, longURL, testUserID); err != nil {
-			return err
+		err := m.AddURL(ctx, token, longURL, userID)
+		if errors.Is(err, storage.ErrURLExists) {
+			existing[longURL], _ = m.GetTokenByURL(context.Background(), longURL)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+func (m *MockStorage) IterUserURLs(ctx context.Context, userID string) iter.Seq2[mod.URLStorageNode, error] {
+	return func(yield func(mod.URLStorageNode, error) bool) {
+		urls, err := m.GetUserURLs(context.Background(), userID)
+		if err != nil {
+			yield(mod.URLStorageNode{}, err)
+			return
+		}
+		sort.Slice(urls, func(i, j int) bool { return urls[i].ShortURL < urls[j].ShortURL })
+		for _, node := range urls {
+			if !yield(node, nil) {
+				return
+			}
 		}
 	}
-	return nil
 }
 
-func (m *MockStorage) DeleteURLs(userID string, tokens []string) error {
+func (m *MockStorage) DeleteURLs(ctx context.Context, userID string, tokens []string) error {
 	if len(tokens) == 0 {
 		return nil
 	}
@@ -198,6 +260,113 @@ func (m *MockStorage) DeleteURLs(userID string, tokens []string) error {
 	return nil
 }
 
+func (m *MockStorage) DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error {
+	for userID, tokens := range tokensByUser {
+		if err := m.DeleteURLs(context.Background(), userID, tokens); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) AddUser(ctx context.Context, user mod.User) error {
+	for _, existing := range m.users {
+		if existing.Login == user.Login {
+			return storage.ErrUserExists
+		}
+	}
+	m.users[user.ID] = user
+	return nil
+}
+
+func (m *MockStorage) GetUserByLogin(ctx context.Context, login string) (mod.User, error) {
+	for _, user := range m.users {
+		if user.Login == login {
+			return user, nil
+		}
+	}
+	return mod.User{}, storage.ErrUserNotFound
+}
+
+func (m *MockStorage) GetUserByID(ctx context.Context, userID string) (mod.User, error) {
+	if user, ok := m.users[userID]; ok {
+		return user, nil
+	}
+	return mod.User{}, storage.ErrUserNotFound
+}
+
+func (m *MockStorage) ReassignUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	for token, node := range m.urls {
+		if node.UserID == fromUserID {
+			node.UserID = toUserID
+			m.urls[token] = node
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) ListURLs(ctx context.Context, filter storage.ListFilter) ([]mod.URLStorageNode, int, error) {
+	var result []mod.URLStorageNode
+	for _, node := range m.urls {
+		if filter.UserID != "" && node.UserID != filter.UserID {
+			continue
+		}
+		if filter.Search != "" && !strings.Contains(node.ShortURL, filter.Search) && !strings.Contains(node.OriginalURL, filter.Search) {
+			continue
+		}
+		result = append(result, node)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ShortURL < result[j].ShortURL
+	})
+	total := len(result)
+	start := min(filter.Offset, total)
+	end := min(start+filter.Limit, total)
+	return result[start:end], total, nil
+}
+
+func (m *MockStorage) SetURLDisabled(ctx context.Context, token string, disabled bool) error {
+	node, ok := m.urls[token]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+	node.IsDisabled = disabled
+	m.urls[token] = node
+	return nil
+}
+
+func (m *MockStorage) SetURLOwner(ctx context.Context, token, userID string) error {
+	node, ok := m.urls[token]
+	if !ok {
+		return storage.ErrURLNotFound
+	}
+	node.UserID = userID
+	m.urls[token] = node
+	return nil
+}
+
+func (m *MockStorage) RemoveURL(ctx context.Context, token string) error {
+	if _, ok := m.urls[token]; !ok {
+		return storage.ErrURLNotFound
+	}
+	delete(m.urls, token)
+	return nil
+}
+
+func (m *MockStorage) GetStats(ctx context.Context) (mod.Stats, error) {
+	var stats mod.Stats
+	owners := make(map[string]bool)
+	for _, node := range m.urls {
+		stats.URLs++
+		if node.IsDeleted {
+			stats.DeletedURLs++
+		}
+		owners[node.UserID] = true
+	}
+	stats.Users = len(owners)
+	return stats, nil
+}
+
 func TestEncodeURLHandler(t *testing.T) {
 	log, err := logger.Initialize()
 	require.NoError(t, err)
@@ -229,7 +398,7 @@ func TestEncodeURLHandler(t *testing.T) {
 			body:        "https://google.com",
 			contentType: "text/plain; charset=utf-8",
 			setupFunc: func(s *MockStorage) {
-				_ = s.AddURL("abc123", "https://google.com", testUserID)
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", testUserID)
 			},
 			wantStatus: http.StatusConflict,
 		},
@@ -261,13 +430,13 @@ func TestEncodeURLHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewMockStorage(storage.MemoryStorageType)
+			storage := NewMockStorage()
 			if tt.setupFunc != nil {
 				tt.setupFunc(storage)
 			}
 
-			handler := NewHandler(storage, cfg)
-			loggedHandler := logger.WithLogging(handler.EncodeURLHandler, log)
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.EncodeURLHandler)
 
 			req := httptest.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
 			req.Header.Set("Content-Type", tt.contentType)
@@ -339,15 +508,15 @@ func TestDecodeURLHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewMockStorage(storage.MemoryStorageType)
+			storage := NewMockStorage()
 
 			if tt.storedURL != "" {
-				err := storage.AddURL(tt.token, tt.storedURL, testUserID)
+				err := storage.AddURL(context.Background(), tt.token, tt.storedURL, testUserID)
 				require.NoError(t, err, "Failed to populate storage")
 			}
 
-			handler := NewHandler(storage, cfg)
-			loggedHandler := logger.WithLogging(handler.DecodeURLHandler, log)
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.DecodeURLHandler)
 
 			r := chi.NewRouter()
 			r.Get("/{id}", loggedHandler)
@@ -403,7 +572,7 @@ func TestApiEncodeHandler(t *testing.T) {
 				URL: "https://google.com",
 			},
 			setupFunc: func(s *MockStorage) {
-				_ = s.AddURL("abc123", "https://google.com", testUserID)
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", testUserID)
 			},
 			wantStatus: http.StatusConflict,
 			wantInBody: "abc123",
@@ -428,13 +597,13 @@ func TestApiEncodeHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewMockStorage(storage.MemoryStorageType)
+			storage := NewMockStorage()
 			if tt.setupFunc != nil {
 				tt.setupFunc(storage)
 			}
 
-			handler := NewHandler(storage, cfg)
-			loggedHandler := logger.WithLogging(handler.APIEncodeHandler, log)
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.APIEncodeHandler)
 
 			bodyBytes, err := easyjson.Marshal(&tt.body)
 			require.NoError(t, err)
@@ -498,10 +667,34 @@ func TestAPIEncodeBatchHandler(t *testing.T) {
 				{CorrelationID: "2", OriginalURL: "https://yandex.ru"},
 			},
 			setupFunc: func(s *MockStorage) {
-				_ = s.AddURL("abc123", "https://google.com", testUserID)
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", testUserID)
 			},
 			wantStatus: http.StatusCreated,
-			wantInBody: "abc123",
+			wantInBody: `"short_url":"http://example.com/abc123","status":"exists"`,
+		},
+		{
+			name:   "partial success",
+			method: http.MethodPost,
+			url:    "/api/shorten/batch",
+			body: mod.BatchRequest{
+				{CorrelationID: "1", OriginalURL: "https://google.com"},
+				{CorrelationID: "2", OriginalURL: "not a url"},
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantInBody: `{"correlation_id":"2","status":"invalid","error":"invalid URL"}`,
+		},
+		{
+			name:   "storage failing",
+			method: http.MethodPost,
+			url:    "/api/shorten/batch",
+			body: mod.BatchRequest{
+				{CorrelationID: "1", OriginalURL: "https://google.com"},
+				{CorrelationID: "2", OriginalURL: "not a url"},
+			},
+			setupFunc: func(s *MockStorage) {
+				s.batchErr = errors.New("connection refused")
+			},
+			wantStatus: http.StatusInternalServerError,
 		},
 		{
 			name:       "empty batch",
@@ -523,13 +716,13 @@ func TestAPIEncodeBatchHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewMockStorage(storage.MemoryStorageType)
+			storage := NewMockStorage()
 			if tt.setupFunc != nil {
 				tt.setupFunc(storage)
 			}
 
-			handler := NewHandler(storage, cfg)
-			loggedHandler := logger.WithLogging(handler.APIEncodeBatchHandler, log)
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.APIEncodeBatchHandler)
 
 			bodyBytes, err := easyjson.Marshal(&tt.body)
 			require.NoError(t, err)
@@ -546,7 +739,7 @@ func TestAPIEncodeBatchHandler(t *testing.T) {
 			defer resp.Body.Close()
 
 			assert.Equal(t, tt.wantStatus, resp.StatusCode)
-			if tt.wantStatus == http.StatusCreated {
+			if tt.wantStatus == http.StatusCreated || tt.wantStatus == http.StatusMultiStatus {
 				assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
 				if tt.wantInBody != "" {
 					body, err := io.ReadAll(resp.Body)
@@ -558,6 +751,109 @@ func TestAPIEncodeBatchHandler(t *testing.T) {
 	}
 }
 
+func TestAPIImportHandler(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+
+	t.Setenv("IMPORT_MAX_ROWS", "3")
+	cfg := setupTestConfig()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		setupFunc   func(*MockStorage)
+		wantStatus  int
+		wantResults []mod.ImportResult
+	}{
+		{
+			name:        "csv",
+			contentType: "text/csv",
+			body:        "correlation_id,original_url\n1,https://google.com\n2,https://yandex.ru\n",
+			setupFunc: func(s *MockStorage) {
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", testUserID)
+			},
+			wantStatus: http.StatusOK,
+			wantResults: []mod.ImportResult{
+				{Line: 2, CorrelationID: "1", ShortURL: "http://example.com/abc123", Existing: true},
+				{Line: 3, CorrelationID: "2"},
+			},
+		},
+		{
+			name:        "ndjson with malformed rows",
+			contentType: "application/x-ndjson",
+			body:        "{\"correlation_id\":\"1\",\"original_url\":\"https://google.com\"}\nnot json\n{\"correlation_id\":\"3\"}\n",
+			wantStatus:  http.StatusOK,
+			wantResults: []mod.ImportResult{
+				{Line: 1, CorrelationID: "1"},
+				{Line: 2, Error: "invalid JSON"},
+				{Line: 3, CorrelationID: "3", Error: "invalid URL"},
+			},
+		},
+		{
+			name:        "row limit",
+			contentType: "text/csv",
+			body:        "https://a.example\nhttps://b.example\nhttps://c.example\nhttps://d.example\n",
+			wantStatus:  http.StatusOK,
+			wantResults: []mod.ImportResult{
+				{Line: 1},
+				{Line: 2},
+				{Line: 3},
+				{Error: "row limit of 3 exceeded, the remaining rows were not imported"},
+			},
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "application/json",
+			body:        `[{"correlation_id":"1","original_url":"https://google.com"}]`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			if tt.setupFunc != nil {
+				tt.setupFunc(storage)
+			}
+
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.APIImportHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/import", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = req.WithContext(setUserIDToContext(req.Context(), testUserID))
+			w := httptest.NewRecorder()
+
+			loggedHandler(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+			var results []mod.ImportResult
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				var result mod.ImportResult
+				require.NoError(t, easyjson.Unmarshal(scanner.Bytes(), &result))
+				// New short URLs are random, only check that they were returned
+				if !result.Existing && result.Error == "" {
+					assert.True(t, strings.HasPrefix(result.ShortURL, "http://example.com/"), result.ShortURL)
+					result.ShortURL = ""
+				}
+				results = append(results, result)
+			}
+			assert.Equal(t, tt.wantResults, results)
+		})
+	}
+}
+
 func TestGetUserURLsHandler(t *testing.T) {
 	log, err := logger.Initialize()
 	require.NoError(t, err)
@@ -585,8 +881,8 @@ func TestGetUserURLsHandler(t *testing.T) {
 			method: http.MethodGet,
 			userID: "user1",
 			setupFunc: func(s *MockStorage) {
-				_ = s.AddURL("abc123", "https://google.com", "user1")
-				_ = s.AddURL("def456", "https://yandex.ru", "user1")
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", "user1")
+				_ = s.AddURL(context.Background(), "def456", "https://yandex.ru", "user1")
 			},
 			wantStatus: http.StatusOK,
 			wantURLs:   2,
@@ -596,7 +892,7 @@ func TestGetUserURLsHandler(t *testing.T) {
 			method: http.MethodGet,
 			userID: "user1",
 			setupFunc: func(s *MockStorage) {
-				_ = s.AddURL("abc123", "https://google.com", "user2")
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", "user2")
 			},
 			wantStatus: http.StatusNoContent,
 			wantURLs:   0,
@@ -618,13 +914,13 @@ func TestGetUserURLsHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewMockStorage(storage.MemoryStorageType)
+			storage := NewMockStorage()
 			if tt.setupFunc != nil {
 				tt.setupFunc(storage)
 			}
 
-			handler := NewHandler(storage, cfg)
-			loggedHandler := logger.WithLogging(handler.GetUserURLsHandler, log)
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.GetUserURLsHandler)
 
 			req := httptest.NewRequest(tt.method, "/api/user/urls", nil)
 			if tt.userID != "" {
@@ -650,6 +946,102 @@ func TestGetUserURLsHandler(t *testing.T) {
 	}
 }
 
+func TestGetUserURLsPagination(t *testing.T) {
+	storage := NewMockStorage()
+	for i, token := range []string{"aaa", "bbb", "ccc"} {
+		require.NoError(t, storage.AddURL(context.Background(), token, fmt.Sprintf("https://example.com/%d", i), "user1"))
+	}
+	deleted := storage.urls["ccc"]
+	deleted.IsDeleted = true
+	storage.urls["ccc"] = deleted
+
+	handler := newTestHandler(t, storage, setupTestConfig())
+	get := func(target string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(setUserIDToContext(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		logger.WithRoute(handler.GetUserURLsHandler)(w, req)
+		return w.Result()
+	}
+	read := func(resp *http.Response) []string {
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var urls []UserURL
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&urls))
+		originals := make([]string, len(urls))
+		for i, url := range urls {
+			originals[i] = url.OriginalURL
+		}
+		return originals
+	}
+
+	// The first page links the second one
+	resp := get("/api/user/urls?limit=1&sort=-original_url&include_deleted=true")
+	link := resp.Header.Get("Link")
+	assert.Equal(t, []string{"https://example.com/2"}, read(resp))
+	require.Regexp(t, `^</api/user/urls\?.*cursor=.*>; rel="next"$`, link)
+	next := strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+
+	resp = get(next)
+	assert.NotEmpty(t, resp.Header.Get("Link"))
+	assert.Equal(t, []string{"https://example.com/1"}, read(resp))
+
+	// The last page has no link, deleted URLs are left out by default
+	resp = get("/api/user/urls?limit=2")
+	assert.Empty(t, resp.Header.Get("Link"))
+	assert.Equal(t, []string{"https://example.com/0", "https://example.com/1"}, read(resp))
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=ten",
+		"limit=1001",
+		"sort=token",
+		"include_deleted=maybe",
+		"created_after=yesterday",
+		"cursor=invalid",
+	} {
+		resp := get("/api/user/urls?" + query)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestURLCreatorMetadata(t *testing.T) {
+	storage := NewMockStorage()
+	handler := newTestHandler(t, storage, setupTestConfig())
+
+	shorten := func(h http.HandlerFunc, contentType, body, realIP string) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Real-IP", realIP)
+		req = req.WithContext(setUserIDToContext(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		logger.WithRoute(h)(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+	shorten(handler.EncodeURLHandler, "text/plain", "https://google.com", "192.0.2.1")
+	shorten(handler.APIEncodeHandler, "application/json", `{"url":"https://yandex.ru"}`, "192.0.2.1")
+	shorten(handler.APIEncodeBatchHandler, "application/json", `[{"correlation_id":"1","original_url":"https://ya.ru"}]`, "192.0.2.2")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls?sort=original_url", nil)
+	req = req.WithContext(setUserIDToContext(req.Context(), "user1"))
+	w := httptest.NewRecorder()
+	logger.WithRoute(handler.GetUserURLsHandler)(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var urls []UserURL
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&urls))
+	require.Len(t, urls, 3)
+
+	assert.Equal(t, mod.SourceText, urls[0].Source)
+	assert.Equal(t, mod.SourceBatch, urls[1].Source)
+	assert.Equal(t, mod.SourceJSON, urls[2].Source)
+	// The hash identifies the client without revealing its address
+	assert.NotEmpty(t, urls[0].ClientIPHash)
+	assert.NotContains(t, urls[0].ClientIPHash, "192.0.2.1")
+	assert.Equal(t, urls[0].ClientIPHash, urls[2].ClientIPHash)
+	assert.NotEqual(t, urls[0].ClientIPHash, urls[1].ClientIPHash)
+}
+
 func TestAuthMiddleware(t *testing.T) {
 	log, err := logger.Initialize()
 	require.NoError(t, err)
@@ -680,8 +1072,8 @@ func TestAuthMiddleware(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewMockStorage(storage.MemoryStorageType)
-			handler := NewHandler(storage, cfg)
+			storage := NewMockStorage()
+			handler := newTestHandler(t, storage, cfg)
 
 			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				userID := getUserIDFromContext(r.Context())
@@ -731,6 +1123,109 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestExportUserURLsHandler(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+
+	cfg := setupTestConfig()
+	createdAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		query           string
+		accept          string
+		userID          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "json by default",
+			userID:          testUserID,
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody: `[{"short_url":"http://example.com/abc123","original_url":"https://google.com","created_at":"2025-03-01T12:00:00Z","is_deleted":false,"is_disabled":false},` +
+				`{"short_url":"http://example.com/def456","original_url":"https://yandex.ru","is_deleted":true,"is_disabled":false}]`,
+		},
+		{
+			name:            "csv by format",
+			query:           "?format=csv",
+			accept:          "application/json",
+			userID:          testUserID,
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv",
+			wantBody: "short_url,original_url,created_at,is_deleted,is_disabled,updated_at,deleted_at,source,client_ip_hash\n" +
+				"http://example.com/abc123,https://google.com,2025-03-01T12:00:00Z,false,false,,,,\n" +
+				"http://example.com/def456,https://yandex.ru,,true,false,,,,\n",
+		},
+		{
+			name:            "ndjson by accept",
+			accept:          "application/x-ndjson",
+			userID:          testUserID,
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantBody: `{"short_url":"http://example.com/abc123","original_url":"https://google.com","created_at":"2025-03-01T12:00:00Z","is_deleted":false,"is_disabled":false}` + "\n" +
+				`{"short_url":"http://example.com/def456","original_url":"https://yandex.ru","is_deleted":true,"is_disabled":false}` + "\n",
+		},
+		{
+			name:            "empty account",
+			userID:          "user-without-urls",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        "[]",
+		},
+		{
+			name:       "unknown format",
+			query:      "?format=xml",
+			userID:     testUserID,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not acceptable",
+			accept:     "application/xml",
+			userID:     testUserID,
+			wantStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:       "no user",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			storage.urls["abc123"] = mod.URLStorageNode{ShortURL: "abc123", OriginalURL: "https://google.com", UserID: testUserID, CreatedAt: createdAt}
+			storage.urls["def456"] = mod.URLStorageNode{ShortURL: "def456", OriginalURL: "https://yandex.ru", UserID: testUserID, IsDeleted: true}
+
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.ExportUserURLsHandler)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			req = req.WithContext(setUserIDToContext(req.Context(), tt.userID))
+			w := httptest.NewRecorder()
+
+			loggedHandler(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantContentType, resp.Header.Get("Content-Type"))
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
+
 func TestDeleteUserURLsHandler(t *testing.T) {
 	log, err := logger.Initialize()
 	require.NoError(t, err)
@@ -752,8 +1247,8 @@ func TestDeleteUserURLsHandler(t *testing.T) {
 			userID: "user1",
 			body:   []string{"abc123", "def456"},
 			setupFunc: func(s *MockStorage) {
-				_ = s.AddURL("abc123", "https://google.com", "user1")
-				_ = s.AddURL("def456", "https://yandex.ru", "user1")
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", "user1")
+				_ = s.AddURL(context.Background(), "def456", "https://yandex.ru", "user1")
 			},
 			wantStatus: http.StatusAccepted,
 		},
@@ -781,13 +1276,13 @@ func TestDeleteUserURLsHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewMockStorage(storage.MemoryStorageType)
+			storage := NewMockStorage()
 			if tt.setupFunc != nil {
 				tt.setupFunc(storage)
 			}
 
-			handler := NewHandler(storage, cfg)
-			loggedHandler := logger.WithLogging(handler.DeleteUserURLsHandler, log)
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.DeleteUserURLsHandler)
 
 			body, err := json.Marshal(tt.body)
 			require.NoError(t, err)
@@ -806,11 +1301,14 @@ func TestDeleteUserURLsHandler(t *testing.T) {
 
 			assert.Equal(t, tt.wantStatus, resp.StatusCode)
 
+			// Wait for the asynchronous deletion to complete
+			require.NoError(t, handler.Drain(context.Background()))
+
 			// If deletion was successful, verify URLs are marked as deleted
 			if tt.wantStatus == http.StatusAccepted && len(tt.body) > 0 {
 				// Try to get the URLs - they should return ErrURLDeleted
 				for _, token := range tt.body {
-					_, err := storage.GetURL(token)
+					_, err := storage.GetURL(context.Background(), token)
 					if err != nil {
 						assert.Equal(t, "url was deleted", err.Error())
 					}
@@ -819,3 +1317,923 @@ func TestDeleteUserURLsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestGracefulShutdown(t *testing.T) {
+	cfg := setupTestConfig()
+	urlStorage := NewMockStorage()
+	require.NoError(t, urlStorage.AddURL(context.Background(), "abc123", "https://google.com", "user1"))
+	require.NoError(t, urlStorage.AddURL(context.Background(), "def456", "https://yandex.ru", "user1"))
+
+	handler := newTestHandler(t, urlStorage, cfg)
+
+	deleteURLs := func(tokens ...string) int {
+		body, err := json.Marshal(tokens)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewReader(body))
+		req = req.WithContext(setUserIDToContext(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler.DeleteUserURLsHandler(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, deleteURLs("abc123"))
+
+	// Readiness fails as soon as shutdown begins
+	handler.BeginShutdown()
+	w := httptest.NewRecorder()
+	handler.PingHandler(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// Draining waits for the deletion started before shutdown
+	require.NoError(t, handler.Drain(context.Background()))
+	_, err := urlStorage.GetURL(context.Background(), "abc123")
+	assert.ErrorIs(t, err, storage.ErrURLDeleted)
+
+	// Deletions requested while draining are completed within the request
+	assert.Equal(t, http.StatusAccepted, deleteURLs("def456"))
+	_, err = urlStorage.GetURL(context.Background(), "def456")
+	assert.ErrorIs(t, err, storage.ErrURLDeleted)
+}
+
+// blockingDeleter blocks deletions until released
+type blockingDeleter struct {
+	release chan struct{}
+}
+
+func (d *blockingDeleter) DeleteURLsByUser(context.Context, map[string][]string) error {
+	<-d.release
+	return nil
+}
+
+func TestDrainTimeout(t *testing.T) {
+	deleter := &blockingDeleter{release: make(chan struct{})}
+	defer close(deleter.release)
+
+	queue, err := deletion.NewQueue(deleter, deletion.Config{FlushInterval: time.Millisecond})
+	require.NoError(t, err)
+	handler := NewHandler(NewMockStorage(), setupTestConfig(), queue).(*Handler)
+	require.NoError(t, queue.Enqueue(context.Background(), "user1", []string{"abc123"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, handler.Drain(ctx), context.DeadlineExceeded)
+}
+
+func TestDeleteUserURLsQueueFull(t *testing.T) {
+	deleter := &blockingDeleter{release: make(chan struct{})}
+	defer close(deleter.release)
+
+	// The worker holds the first request while the queue holds the second one
+	queue, err := deletion.NewQueue(deleter, deletion.Config{Size: 1, MaxBatch: 1})
+	require.NoError(t, err)
+	handler := NewHandler(NewMockStorage(), setupTestConfig(), queue)
+
+	deleteURLs := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["`+token+`"]`))
+		req = req.WithContext(setUserIDToContext(req.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler.DeleteUserURLsHandler(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusAccepted, deleteURLs("abc123").Code)
+	require.Eventually(t, func() bool { return queue.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, http.StatusAccepted, deleteURLs("def456").Code)
+
+	w := deleteURLs("ghi789")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, 2, queue.Depth())
+}
+
+func TestRegisterHandler(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+
+	cfg := setupTestConfig()
+
+	tests := []struct {
+		name        string
+		method      string
+		body        mod.Credentials
+		anonymousID string
+		setupFunc   func(*MockStorage)
+		wantStatus  int
+		wantClaimed int
+	}{
+		{
+			name:       "successful registration",
+			method:     http.MethodPost,
+			body:       mod.Credentials{Login: "alice", Password: "correct-horse"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "registration claims anonymous urls",
+			method:      http.MethodPost,
+			body:        mod.Credentials{Login: "alice", Password: "correct-horse"},
+			anonymousID: "anonymous-user",
+			setupFunc: func(s *MockStorage) {
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", "anonymous-user")
+				_ = s.AddURL(context.Background(), "def456", "https://yandex.ru", "anonymous-user")
+			},
+			wantStatus:  http.StatusOK,
+			wantClaimed: 2,
+		},
+		{
+			name:   "login taken",
+			method: http.MethodPost,
+			body:   mod.Credentials{Login: "alice", Password: "correct-horse"},
+			setupFunc: func(s *MockStorage) {
+				_ = s.AddUser(context.Background(), mod.User{ID: "existing", Login: "alice"})
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "short password",
+			method:     http.MethodPost,
+			body:       mod.Credentials{Login: "alice", Password: "short"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "short multibyte password",
+			method:     http.MethodPost,
+			body:       mod.Credentials{Login: "alice", Password: "пароль"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multibyte password",
+			method:     http.MethodPost,
+			body:       mod.Credentials{Login: "alice", Password: "парольпароль"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "password longer than bcrypt accepts",
+			method:     http.MethodPost,
+			body:       mod.Credentials{Login: "alice", Password: strings.Repeat("a", 73)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty login",
+			method:     http.MethodPost,
+			body:       mod.Credentials{Password: "correct-horse"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			body:       mod.Credentials{Login: "alice", Password: "correct-horse"},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			if tt.setupFunc != nil {
+				tt.setupFunc(storage)
+			}
+
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.RegisterHandler)
+
+			bodyBytes, err := easyjson.Marshal(&tt.body)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(tt.method, "/api/user/register", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.anonymousID != "" {
+				req.AddCookie(&http.Cookie{Name: userIDCookieName, Value: tt.anonymousID})
+				req.AddCookie(&http.Cookie{Name: signatureCookieName, Value: generateSignature(tt.anonymousID, []byte(testSecret))})
+			}
+			w := httptest.NewRecorder()
+
+			loggedHandler(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantStatus == http.StatusOK {
+				user, err := storage.GetUserByLogin(context.Background(), tt.body.Login)
+				require.NoError(t, err)
+				assert.NotEqual(t, tt.body.Password, user.PasswordHash)
+
+				var cookieUserID string
+				for _, cookie := range resp.Cookies() {
+					if cookie.Name == userIDCookieName {
+						cookieUserID = cookie.Value
+					}
+				}
+				assert.Equal(t, user.ID, cookieUserID)
+
+				urls, err := storage.GetUserURLs(context.Background(), user.ID)
+				require.NoError(t, err)
+				assert.Len(t, urls, tt.wantClaimed)
+			}
+		})
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+
+	cfg := setupTestConfig()
+
+	passwordHash, err := hashPassword("correct-horse")
+	require.NoError(t, err)
+	account := mod.User{ID: "account-id", Login: "alice", PasswordHash: passwordHash}
+
+	tests := []struct {
+		name        string
+		body        mod.Credentials
+		anonymousID string
+		setupFunc   func(*MockStorage)
+		wantStatus  int
+		wantClaimed int
+	}{
+		{
+			name:       "successful login",
+			body:       mod.Credentials{Login: "alice", Password: "correct-horse"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "login claims anonymous urls",
+			body:        mod.Credentials{Login: "alice", Password: "correct-horse"},
+			anonymousID: "anonymous-user",
+			setupFunc: func(s *MockStorage) {
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", "anonymous-user")
+			},
+			wantStatus:  http.StatusOK,
+			wantClaimed: 1,
+		},
+		{
+			name:        "login does not claim another account",
+			body:        mod.Credentials{Login: "alice", Password: "correct-horse"},
+			anonymousID: "other-account",
+			setupFunc: func(s *MockStorage) {
+				_ = s.AddUser(context.Background(), mod.User{ID: "other-account", Login: "bob"})
+				_ = s.AddURL(context.Background(), "abc123", "https://google.com", "other-account")
+			},
+			wantStatus:  http.StatusOK,
+			wantClaimed: 0,
+		},
+		{
+			name:       "wrong password",
+			body:       mod.Credentials{Login: "alice", Password: "wrong-password"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown login",
+			body:       mod.Credentials{Login: "bob", Password: "correct-horse"},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			require.NoError(t, storage.AddUser(context.Background(), account))
+			if tt.setupFunc != nil {
+				tt.setupFunc(storage)
+			}
+
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.LoginHandler)
+
+			bodyBytes, err := easyjson.Marshal(&tt.body)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/user/login", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.anonymousID != "" {
+				req.AddCookie(&http.Cookie{Name: userIDCookieName, Value: tt.anonymousID})
+				req.AddCookie(&http.Cookie{Name: signatureCookieName, Value: generateSignature(tt.anonymousID, []byte(testSecret))})
+			}
+			w := httptest.NewRecorder()
+
+			loggedHandler(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantStatus == http.StatusOK {
+				assert.Len(t, resp.Cookies(), 2)
+
+				urls, err := storage.GetUserURLs(context.Background(), account.ID)
+				require.NoError(t, err)
+				assert.Len(t, urls, tt.wantClaimed)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+
+	cfg := setupTestConfig()
+
+	provider := oidctest.NewServer("sso-subject")
+	defer provider.Close()
+
+	storage := NewMockStorage()
+	_ = storage.AddURL(context.Background(), "abc123", "https://google.com", "anonymous-user")
+
+	handler := newTestHandler(t, storage, cfg).(*Handler)
+	handler.identityProvider = oidc.NewProvider(oidc.Config{
+		Issuer:      provider.Issuer(),
+		ClientID:    "shortener",
+		RedirectURL: "http://localhost:8080/api/user/oidc/callback",
+	})
+
+	anonymousCookies := []*http.Cookie{
+		{Name: userIDCookieName, Value: "anonymous-user"},
+		{Name: signatureCookieName, Value: generateSignature("anonymous-user", []byte(testSecret))},
+	}
+
+	// login runs the whole flow and returns the account user ID set in the cookies
+	login := func(tamperState bool) (*http.Response, string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/oidc/login", nil)
+		w := httptest.NewRecorder()
+		handler.OIDCLoginHandler(w, req)
+		loginResp := w.Result()
+		defer loginResp.Body.Close()
+		require.Equal(t, http.StatusFound, loginResp.StatusCode)
+
+		callback, err := provider.Authorize(loginResp.Header.Get("Location"))
+		require.NoError(t, err)
+		if tamperState {
+			callback = strings.Replace(callback, "state=", "state=x", 1)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, callback, nil)
+		for _, cookie := range append(loginResp.Cookies(), anonymousCookies...) {
+			req.AddCookie(cookie)
+		}
+		w = httptest.NewRecorder()
+		handler.OIDCCallbackHandler(w, req)
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == userIDCookieName {
+				return resp, cookie.Value
+			}
+		}
+		return resp, ""
+	}
+
+	resp, userID := login(false)
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	require.NotEmpty(t, userID)
+
+	urls, err := storage.GetUserURLs(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	// The same subject maps to the same account
+	_, secondUserID := login(false)
+	assert.Equal(t, userID, secondUserID)
+
+	// A forged state is rejected
+	resp, _ = login(true)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// SSO accounts can't log in with a password
+	user, err := storage.GetUserByID(context.Background(), userID)
+	require.NoError(t, err)
+	assert.False(t, checkPassword(user.PasswordHash, ""))
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+	handler := newTestHandler(t, NewMockStorage(), setupTestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/oidc/login", nil)
+	w := httptest.NewRecorder()
+	handler.OIDCLoginHandler(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAdminAPI(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+
+	cfg := setupTestConfig()
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		token      string
+		wantStatus int
+		wantTotal  int
+		check      func(*testing.T, *MockStorage)
+	}{
+		{
+			name:       "no token",
+			method:     http.MethodGet,
+			url:        "/api/admin/urls",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong token",
+			method:     http.MethodGet,
+			url:        "/api/admin/urls",
+			token:      "wrong-token",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "list all urls",
+			method:     http.MethodGet,
+			url:        "/api/admin/urls",
+			token:      testAdminToken,
+			wantStatus: http.StatusOK,
+			wantTotal:  3,
+		},
+		{
+			name:       "search urls",
+			method:     http.MethodGet,
+			url:        "/api/admin/urls?search=yandex&limit=1",
+			token:      testAdminToken,
+			wantStatus: http.StatusOK,
+			wantTotal:  1,
+		},
+		{
+			name:       "incorrect limit",
+			method:     http.MethodGet,
+			url:        "/api/admin/urls?limit=0",
+			token:      testAdminToken,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "user urls",
+			method:     http.MethodGet,
+			url:        "/api/admin/users/user1/urls",
+			token:      testAdminToken,
+			wantStatus: http.StatusOK,
+			wantTotal:  2,
+		},
+		{
+			name:       "disable url",
+			method:     http.MethodPost,
+			url:        "/api/admin/urls/abc123/disable",
+			token:      testAdminToken,
+			wantStatus: http.StatusNoContent,
+			check: func(t *testing.T, s *MockStorage) {
+				_, err := s.GetURL(context.Background(), "abc123")
+				assert.ErrorIs(t, err, storage.ErrURLDisabled)
+			},
+		},
+		{
+			name:       "enable url",
+			method:     http.MethodPost,
+			url:        "/api/admin/urls/def456/enable",
+			token:      testAdminToken,
+			wantStatus: http.StatusNoContent,
+			check: func(t *testing.T, s *MockStorage) {
+				_, err := s.GetURL(context.Background(), "def456")
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:       "reassign owner",
+			method:     http.MethodPut,
+			url:        "/api/admin/urls/abc123/owner",
+			body:       `{"user_id":"user3"}`,
+			token:      testAdminToken,
+			wantStatus: http.StatusNoContent,
+			check: func(t *testing.T, s *MockStorage) {
+				urls, _ := s.GetUserURLs(context.Background(), "user3")
+				assert.Len(t, urls, 1)
+			},
+		},
+		{
+			name:       "force delete",
+			method:     http.MethodDelete,
+			url:        "/api/admin/urls/abc123",
+			token:      testAdminToken,
+			wantStatus: http.StatusNoContent,
+			check: func(t *testing.T, s *MockStorage) {
+				_, err := s.GetURL(context.Background(), "abc123")
+				assert.ErrorIs(t, err, storage.ErrURLNotFound)
+			},
+		},
+		{
+			name:       "unknown token",
+			method:     http.MethodDelete,
+			url:        "/api/admin/urls/missing",
+			token:      testAdminToken,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			_ = storage.AddURL(context.Background(), "abc123", "https://google.com", "user1")
+			_ = storage.AddURL(context.Background(), "def456", "https://yandex.ru", "user1")
+			_ = storage.AddURL(context.Background(), "ghi789", "https://github.com", "user2")
+			_ = storage.SetURLDisabled(context.Background(), "def456", true)
+
+			handler := newTestHandler(t, storage, cfg)
+
+			r := chi.NewRouter()
+			r.Get("/api/admin/urls", logger.WithRoute(handler.AdminMiddleware(handler.AdminListURLsHandler)))
+			r.Post("/api/admin/urls/{token}/disable", handler.AdminMiddleware(handler.AdminDisableURLHandler))
+			r.Post("/api/admin/urls/{token}/enable", handler.AdminMiddleware(handler.AdminEnableURLHandler))
+			r.Put("/api/admin/urls/{token}/owner", handler.AdminMiddleware(handler.AdminSetURLOwnerHandler))
+			r.Delete("/api/admin/urls/{token}", handler.AdminMiddleware(handler.AdminDeleteURLHandler))
+			r.Get("/api/admin/users/{userID}/urls", handler.AdminMiddleware(handler.AdminUserURLsHandler))
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				var list mod.AdminURLList
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+				assert.Equal(t, tt.wantTotal, list.Total)
+			}
+			if tt.check != nil {
+				tt.check(t, storage)
+			}
+		})
+	}
+}
+
+func TestAdminReloadConfig(t *testing.T) {
+	cfg := setupTestConfig()
+	handler := newTestHandler(t, NewMockStorage(), cfg)
+	reload := handler.AdminMiddleware(handler.AdminReloadConfigHandler)
+
+	shorten := func() string {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://reload.example"))
+		req = req.WithContext(setUserIDToContext(req.Context(), testUserID))
+		w := httptest.NewRecorder()
+		handler.EncodeURLHandler(w, req)
+		return w.Body.String()
+	}
+	assert.True(t, strings.HasPrefix(shorten(), "http://example.com/"))
+
+	// The new base URL is pushed to the handler, the new server address is only reported
+	t.Setenv("BASE_URL", "http://reloaded.example")
+	t.Setenv("SERVER_ADDRESS", "localhost:9999")
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/config/reload", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	reload(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response mod.ConfigReloadResponse
+	require.NoError(t, easyjson.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"server_address changed, restart required to apply it"}, response.Warnings)
+	assert.True(t, strings.HasPrefix(shorten(), "http://reloaded.example/"))
+
+	// An invalid configuration is rejected and the running one is kept
+	t.Setenv("BASE_URL", "not-a-url")
+	w = httptest.NewRecorder()
+	reload(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "http://reloaded.example", cfg.GetBaseURL())
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+	defer logger.SetLevel("info")
+
+	handler := newTestHandler(t, NewMockStorage(), setupTestConfig())
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantLevel  string
+	}{
+		{
+			name:       "get level",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantLevel:  "info",
+		},
+		{
+			name:       "set level",
+			method:     http.MethodPut,
+			body:       `{"level":"debug"}`,
+			wantStatus: http.StatusOK,
+			wantLevel:  "debug",
+		},
+		{
+			name:       "unknown level",
+			method:     http.MethodPut,
+			body:       `{"level":"loud"}`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  "debug",
+		},
+		{
+			name:       "missing level",
+			method:     http.MethodPut,
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  "debug",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.AdminGetLogLevelHandler
+			if tt.method == http.MethodPut {
+				h = handler.AdminSetLogLevelHandler
+			}
+			req := httptest.NewRequest(tt.method, "/api/admin/log-level", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+testAdminToken)
+			w := httptest.NewRecorder()
+			handler.AdminMiddleware(h)(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				var response mod.LogLevel
+				require.NoError(t, easyjson.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.wantLevel, response.Level)
+			}
+			assert.Equal(t, tt.wantLevel, logger.Level())
+		})
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	log, err := logger.Initialize()
+	require.NoError(t, err)
+	defer log.Sync()
+
+	cfg := setupTestConfig()
+	os.Setenv("TRUSTED_SUBNET", "192.168.1.0/24")
+	defer os.Unsetenv("TRUSTED_SUBNET")
+	cfg.LoadEnvVariables()
+
+	tests := []struct {
+		name       string
+		realIP     string
+		remoteAddr string
+		wantStatus int
+	}{
+		{
+			name:       "trusted real ip",
+			realIP:     "192.168.1.15",
+			remoteAddr: "10.0.0.1:5555",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "untrusted real ip",
+			realIP:     "10.0.0.1",
+			remoteAddr: "192.168.1.15:5555",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "trusted remote address",
+			remoteAddr: "192.168.1.200:5555",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid real ip",
+			realIP:     "not-an-ip",
+			remoteAddr: "192.168.1.15:5555",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			_ = storage.AddURL(context.Background(), "abc123", "https://google.com", "user1")
+			_ = storage.AddURL(context.Background(), "def456", "https://yandex.ru", "user1")
+			_ = storage.AddURL(context.Background(), "ghi789", "https://github.com", "user2")
+			_ = storage.DeleteURLs(context.Background(), "user2", []string{"ghi789"})
+
+			handler := newTestHandler(t, storage, cfg)
+			loggedHandler := logger.WithRoute(handler.TrustedSubnetMiddleware(handler.StatsHandler))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			w := httptest.NewRecorder()
+
+			loggedHandler(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				var stats mod.Stats
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+				assert.Equal(t, mod.Stats{URLs: 3, Users: 2, DeletedURLs: 1}, stats)
+			}
+		})
+	}
+}
+
+func TestStatsHandlerWithoutTrustedSubnet(t *testing.T) {
+	handler := newTestHandler(t, NewMockStorage(), setupTestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+	req.Header.Set("X-Real-IP", "127.0.0.1")
+	w := httptest.NewRecorder()
+
+	handler.TrustedSubnetMiddleware(handler.StatsHandler)(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestConstructURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		enableHTTPS bool
+		tls         bool
+		wantURL     string
+	}{
+		{
+			name:    "plain http",
+			wantURL: "http://example.com/abc123",
+		},
+		{
+			name:        "https enabled",
+			enableHTTPS: true,
+			wantURL:     "https://example.com/abc123",
+		},
+		{
+			name:    "tls connection",
+			tls:     true,
+			wantURL: "https://example.com/abc123",
+		},
+		{
+			name:        "base url takes precedence",
+			baseURL:     "http://short.example.com",
+			enableHTTPS: true,
+			wantURL:     "http://short.example.com/abc123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BASE_URL", tt.baseURL)
+			t.Setenv("ENABLE_HTTPS", strconv.FormatBool(tt.enableHTTPS))
+			cfg := config.NewOptions()
+			cfg.LoadEnvVariables()
+			handler := newTestHandler(t, NewMockStorage(), cfg).(*Handler)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if tt.tls {
+				req = httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+			}
+			assert.Equal(t, tt.wantURL, handler.constructURL("abc123", req))
+		})
+	}
+}
+
+func TestHealthChecks(t *testing.T) {
+	tests := []struct {
+		name            string
+		healthErr       error
+		lifecycle       func(HandlerInterface)
+		wantPing        int
+		wantReadiness   int
+		wantReadyStatus string
+		wantLiveStatus  string
+		wantComponent   mod.HealthComponent
+	}{
+		{
+			name:            "healthy",
+			wantPing:        http.StatusOK,
+			wantReadiness:   http.StatusOK,
+			wantReadyStatus: mod.HealthStatusOK,
+			wantLiveStatus:  mod.HealthStatusOK,
+			wantComponent:   mod.HealthComponent{Name: "storage", Status: mod.HealthStatusOK},
+		},
+		{
+			name:            "storage failing",
+			healthErr:       errors.New("connection refused"),
+			wantPing:        http.StatusInternalServerError,
+			wantReadiness:   http.StatusServiceUnavailable,
+			wantReadyStatus: mod.HealthStatusFailing,
+			wantLiveStatus:  mod.HealthStatusDegraded,
+			wantComponent:   mod.HealthComponent{Name: "storage", Status: mod.HealthStatusFailing, Error: "connection refused"},
+		},
+		{
+			name:            "starting",
+			lifecycle:       func(h HandlerInterface) { h.BeginStartup() },
+			wantPing:        http.StatusOK,
+			wantReadiness:   http.StatusServiceUnavailable,
+			wantReadyStatus: mod.HealthStatusStarting,
+			wantLiveStatus:  mod.HealthStatusOK,
+			wantComponent:   mod.HealthComponent{Name: "storage", Status: mod.HealthStatusOK},
+		},
+		{
+			name:            "started",
+			lifecycle:       func(h HandlerInterface) { h.BeginStartup(); h.FinishStartup() },
+			wantPing:        http.StatusOK,
+			wantReadiness:   http.StatusOK,
+			wantReadyStatus: mod.HealthStatusOK,
+			wantLiveStatus:  mod.HealthStatusOK,
+			wantComponent:   mod.HealthComponent{Name: "storage", Status: mod.HealthStatusOK},
+		},
+		{
+			name:            "shutting down",
+			lifecycle:       func(h HandlerInterface) { h.BeginShutdown() },
+			wantPing:        http.StatusServiceUnavailable,
+			wantReadiness:   http.StatusServiceUnavailable,
+			wantReadyStatus: mod.HealthStatusStopping,
+			wantLiveStatus:  mod.HealthStatusOK,
+			wantComponent:   mod.HealthComponent{Name: "storage", Status: mod.HealthStatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := NewMockStorage()
+			urlStorage.healthErr = tt.healthErr
+			handler := newTestHandler(t, urlStorage, setupTestConfig())
+			if tt.lifecycle != nil {
+				tt.lifecycle(handler)
+			}
+
+			check := func(h http.HandlerFunc, path string) (int, mod.HealthResponse) {
+				w := httptest.NewRecorder()
+				h(w, httptest.NewRequest(http.MethodGet, path, nil))
+				var response mod.HealthResponse
+				if path != "/ping" {
+					assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+					require.NoError(t, easyjson.Unmarshal(w.Body.Bytes(), &response))
+				}
+				return w.Code, response
+			}
+
+			code, _ := check(handler.PingHandler, "/ping")
+			assert.Equal(t, tt.wantPing, code)
+
+			code, readiness := check(handler.ReadinessHandler, "/readyz")
+			assert.Equal(t, tt.wantReadiness, code)
+			assert.Equal(t, tt.wantReadyStatus, readiness.Status)
+
+			code, liveness := check(handler.LivenessHandler, "/healthz")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, tt.wantLiveStatus, liveness.Status)
+
+			// Components are ordered by name, the deletion queue is healthy until drained
+			require.Len(t, liveness.Components, 2)
+			assert.Equal(t, "deletion_queue", liveness.Components[0].Name)
+			assert.Equal(t, mod.HealthStatusOK, liveness.Components[0].Status)
+			component := liveness.Components[1]
+			assert.GreaterOrEqual(t, component.LatencyMillis, 0.0)
+			component.LatencyMillis = 0
+			assert.Equal(t, tt.wantComponent, component)
+		})
+	}
+}
+
+func TestStartupMiddleware(t *testing.T) {
+	handler := newTestHandler(t, NewMockStorage(), setupTestConfig())
+	api := handler.StartupMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/urls", nil))
+		return w
+	}
+
+	handler.BeginStartup()
+	w := serve()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	handler.FinishStartup()
+	assert.Equal(t, http.StatusNoContent, serve().Code)
+}
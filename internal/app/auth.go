@@ -5,9 +5,14 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"net"
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/pcristin/urlshortener/internal/accesslog"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -27,8 +32,11 @@ func getUserIDFromContext(ctx context.Context) string {
 	return ""
 }
 
-// setUserIDToContext adds user ID to context
+// setUserIDToContext adds user ID to context and to the fields of its logger,
+// and records it in the access log entry of the request
 func setUserIDToContext(ctx context.Context, userID string) context.Context {
+	accesslog.SetUserID(ctx, userID)
+	ctx = logger.WithFields(ctx, zap.String("user_id", userID))
 	return context.WithValue(ctx, userIDContextKey, userID)
 }
 
@@ -39,44 +47,83 @@ func generateSignature(userID string, secret []byte) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// hashClientIP returns a keyed hash of the IP address of a client, recorded with the URLs it shortens.
+// Hashes of the same address match, so URLs of a client can be correlated without storing its address.
+// The hash is domain separated from signatures, so it can't be used as the signature of a user ID.
+func hashClientIP(ip net.IP, secret []byte) string {
+	if ip == nil {
+		return ""
+	}
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("client_ip:" + ip.String()))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
 // validateSignature validates the HMAC signature for the given user ID
 func validateSignature(userID, signature string, secret []byte) bool {
 	expectedSignature := generateSignature(userID, secret)
 	return hmac.Equal([]byte(signature), []byte(expectedSignature))
 }
 
+// hashPassword creates a salted bcrypt hash of the password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether the password matches the bcrypt hash
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// userIDFromCookies returns the user ID from the request cookies if the signature is valid
+func (h *Handler) userIDFromCookies(r *http.Request) (string, bool) {
+	userIDCookie, err := r.Cookie(userIDCookieName)
+	if err != nil {
+		return "", false
+	}
+	signatureCookie, err := r.Cookie(signatureCookieName)
+	if err != nil {
+		return "", false
+	}
+	if !validateSignature(userIDCookie.Value, signatureCookie.Value, []byte(h.secret)) {
+		return "", false
+	}
+	return userIDCookie.Value, true
+}
+
+// setAuthCookies sets the user ID cookie and its signature on the response
+func (h *Handler) setAuthCookies(w http.ResponseWriter, userID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:  userIDCookieName,
+		Value: userID,
+		Path:  "/",
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:  signatureCookieName,
+		Value: generateSignature(userID, []byte(h.secret)),
+		Path:  "/",
+	})
+}
+
 // AuthMiddleware handles user authentication via cookies
 func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Try to get existing cookies
-		userIDCookie, err := r.Cookie(userIDCookieName)
-		signatureCookie, signErr := r.Cookie(signatureCookieName)
+		// Try to get the user ID from existing cookies
+		userID, ok := h.userIDFromCookies(r)
 
 		// If either cookie is missing or invalid, create new ones
-		if err != nil || signErr != nil || !validateSignature(userIDCookie.Value, signatureCookie.Value, []byte(h.secret)) {
-			// Generate new user ID and signature
-			userID := uuid.New().String()
-			signature := generateSignature(userID, []byte(h.secret))
-
-			// Set new cookies
-			http.SetCookie(w, &http.Cookie{
-				Name:  userIDCookieName,
-				Value: userID,
-				Path:  "/",
-			})
-			http.SetCookie(w, &http.Cookie{
-				Name:  signatureCookieName,
-				Value: signature,
-				Path:  "/",
-			})
-
-			// Update request with new user ID
-			r = r.WithContext(setUserIDToContext(r.Context(), userID))
-		} else {
-			// Update request with existing user ID
-			r = r.WithContext(setUserIDToContext(r.Context(), userIDCookie.Value))
+		if !ok {
+			userID = uuid.New().String()
+			h.setAuthCookies(w, userID)
 		}
 
+		// Update request with the user ID
+		r = r.WithContext(setUserIDToContext(r.Context(), userID))
+
 		next.ServeHTTP(w, r)
 	}
 }
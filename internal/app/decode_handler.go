@@ -5,8 +5,16 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/pcristin/urlshortener/internal/storage"
-	uu "github.com/pcristin/urlshortener/internal/urlutils"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/pcristin/urlshortener/internal/metrics"
+	"github.com/pcristin/urlshortener/internal/service"
+)
+
+// redirects counts the outcomes of short URL lookups
+var redirects = metrics.NewCounterVec(
+	"shortener_redirects_total",
+	"Number of short URL lookups by result: redirected, gone or not_found.",
+	"result",
 )
 
 // DecodeURLHandler handles requests to redirect from a shortened URL to the original URL.
@@ -15,29 +23,37 @@ import (
 //
 // This handler only supports HTTP GET requests.
 //
-// If the URL is found but has been marked as deleted, it returns a 410 Gone status.
+// If the URL is found but has been marked as deleted or disabled by an administrator,
+// it returns a 410 Gone status.
 // If the token is not found or invalid, it returns a 400 Bad Request status.
 func (h *Handler) DecodeURLHandler(res http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(res, "Method not allowed", http.StatusMethodNotAllowed)
+		logger.HTTPError(res, req, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	token := chi.URLParam(req, "id")
 	if token == "" {
-		http.Error(res, "bad request: incorrect token", http.StatusBadRequest)
+		logger.HTTPError(res, req, "bad request: incorrect token", http.StatusBadRequest)
 		return
 	}
 
-	longURL, err := uu.DecodeURL(token, h.storage)
-	if err != nil {
-		if errors.Is(err, storage.ErrURLDeleted) {
-			http.Error(res, "URL was deleted", http.StatusGone)
-			return
-		}
-		http.Error(res, "bad request: unable to decode provided token", http.StatusBadRequest)
+	longURL, err := h.shortener.Expand(req.Context(), token)
+	switch {
+	case errors.Is(err, service.ErrDeleted):
+		redirects.Inc("gone")
+		logger.HTTPError(res, req, "URL was deleted", http.StatusGone)
+		return
+	case errors.Is(err, service.ErrDisabled):
+		redirects.Inc("gone")
+		logger.HTTPError(res, req, "URL was disabled", http.StatusGone)
+		return
+	case err != nil:
+		redirects.Inc("not_found")
+		logger.HTTPError(res, req, "bad request: unable to decode provided token", http.StatusBadRequest)
 		return
 	}
+	redirects.Inc("redirected")
 
 	res.Header().Set("Location", longURL)
 	res.WriteHeader(http.StatusTemporaryRedirect)
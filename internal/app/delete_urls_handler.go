@@ -2,23 +2,28 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 
+	"github.com/pcristin/urlshortener/internal/deletion"
+	"github.com/pcristin/urlshortener/internal/logger"
 	"go.uber.org/zap"
 )
 
-// DeleteUserURLsHandler handles DELETE /api/user/urls requests
+// DeleteUserURLsHandler handles DELETE /api/user/urls requests.
+// The deletion is queued and 202 Accepted returned at once; if the queue is full it returns
+// 503 Service Unavailable with a Retry-After header.
 func (h *Handler) DeleteUserURLsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Get user ID from context
 	userID := getUserIDFromContext(r.Context())
 	if userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.HTTPError(w, r, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -26,23 +31,38 @@ func (h *Handler) DeleteUserURLsHandler(w http.ResponseWriter, r *http.Request)
 	body, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		bodyError(w, r, err, "Error reading request body")
 		return
 	}
 
 	var tokens []string
 	if err := json.Unmarshal(body, &tokens); err != nil {
-		http.Error(w, "Invalid request body format", http.StatusBadRequest)
+		logger.HTTPError(w, r, "Invalid request body format", http.StatusBadRequest)
+		return
+	}
+	if err := h.shortener.CheckBatchSize(len(tokens)); err != nil {
+		logger.HTTPError(w, r, "request entity too large: "+err.Error(), http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	// Asynchronously delete URLs
-	go func() {
-		if err := h.storage.DeleteURLs(userID, tokens); err != nil {
+	// Queue the deletion, it is written in the background together with other requests
+	err = h.deletions.Enqueue(r.Context(), userID, tokens)
+	switch {
+	case errors.Is(err, deletion.ErrClosed):
+		// Shutting down: delete within the request so the deletion is not lost
+		if err := h.shortener.DeleteURLs(r.Context(), userID, tokens); err != nil {
 			// Log error but don't return it to client as per requirements
-			h.logger.Error("Error deleting URLs", zap.Error(err))
+			h.requestLogger(r).Error("Error deleting URLs", zap.Error(err))
 		}
-	}()
+	case errors.Is(err, deletion.ErrQueueFull):
+		w.Header().Set("Retry-After", "1")
+		logger.HTTPError(w, r, "Too many pending deletions, retry later", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		h.requestLogger(r).Error("Error queueing URL deletion", zap.Error(err))
+		logger.HTTPError(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
 	// Return 202 Accepted immediately
 	w.WriteHeader(http.StatusAccepted)
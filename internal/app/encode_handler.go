@@ -1,12 +1,16 @@
 package app
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/service"
 	"github.com/pcristin/urlshortener/internal/storage"
-	uu "github.com/pcristin/urlshortener/internal/urlutils"
 )
 
 // EncodeURLHandler handles requests to shorten a URL.
@@ -19,36 +23,54 @@ import (
 // The response is plain text containing the fully qualified shortened URL.
 func (h *Handler) EncodeURLHandler(res http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(res, "bad request", http.StatusBadRequest)
+		logger.HTTPError(res, req, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	longURL, err := io.ReadAll(req.Body)
 	defer req.Body.Close()
 
-	if err != nil || len(string(longURL)) == 0 {
-		http.Error(res, "bad request: incorrect long URL", http.StatusBadRequest)
+	if err != nil {
+		bodyError(res, req, err, "bad request: incorrect long URL")
 		return
 	}
 
-	// Get user ID from context
-	userID := getUserIDFromContext(req.Context())
-
-	token, err := uu.EncodeURL(string(longURL), h.storage, userID)
-	if err != nil {
-		if errors.Is(err, storage.ErrURLExists) {
-			res.Header().Set("Content-Type", "text/plain")
-			res.WriteHeader(http.StatusConflict)
-			resBody := h.constructURL(token, req)
-			res.Write([]byte(resBody))
-			return
-		}
-		http.Error(res, "bad request: unable to shorten provided url", http.StatusBadRequest)
+	status, token, ok := h.shorten(res, req, mod.SourceText, string(longURL))
+	if !ok {
 		return
 	}
 
 	res.Header().Set("Content-Type", "text/plain")
-	res.WriteHeader(http.StatusCreated)
-	resBody := h.constructURL(token, req)
-	res.Write([]byte(resBody))
+	res.WriteHeader(status)
+	res.Write([]byte(h.constructURL(token, req)))
+}
+
+// shorten shortens the URL on behalf of the request user through the source API and returns the response status:
+// 201 Created for a new short URL and 409 Conflict for an existing one.
+// On failure it writes the error response and returns false.
+func (h *Handler) shorten(res http.ResponseWriter, req *http.Request, source, longURL string) (int, string, bool) {
+	token, err := h.shortener.Shorten(h.creatorContext(req, source), getUserIDFromContext(req.Context()), longURL)
+	switch {
+	case err == nil:
+		return http.StatusCreated, token, true
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusConflict, token, true
+	case errors.Is(err, service.ErrInvalidURL):
+		logger.HTTPError(res, req, "bad request: incorrect long URL", http.StatusBadRequest)
+	case errors.Is(err, service.ErrURLTooLong):
+		logger.HTTPError(res, req, fmt.Sprintf("bad request: URL longer than %d characters", h.config.GetMaxURLLength()), http.StatusBadRequest)
+	default:
+		h.requestLogger(req).Sugar().Errorw("Error shortening URL", "error", err, "url", longURL)
+		logger.HTTPError(res, req, "bad request: unable to shorten provided url", http.StatusBadRequest)
+	}
+	return 0, "", false
+}
+
+// creatorContext returns the context of the request recording the source API and the hashed client IP
+// in the URLs shortened with it
+func (h *Handler) creatorContext(req *http.Request, source string) context.Context {
+	return storage.WithCreator(req.Context(), storage.Creator{
+		Source:       source,
+		ClientIPHash: hashClientIP(clientIP(req), []byte(h.secret)),
+	})
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/deletion"
 	"github.com/pcristin/urlshortener/internal/models"
 	"github.com/pcristin/urlshortener/internal/storage"
 )
@@ -22,13 +23,16 @@ func Example_setupTestServer() {
 	r := chi.NewRouter()
 
 	// Initialize storage
-	urlStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	urlStorage := storage.NewMemoryStorage()
 
 	// Initialize config
 	cfg := config.NewOptions()
 
 	// Initialize handler
-	handler := NewHandler(urlStorage, cfg)
+	// Deletions are written in the background by a queue, closed once done
+	deletions, _ := deletion.NewQueue(urlStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(urlStorage, cfg, deletions)
 
 	// Set up routes
 	r.Post("/", handler.EncodeURLHandler)
@@ -53,9 +57,11 @@ func ExampleHandlerInterface_EncodeURLHandler() {
 	// This example demonstrates how to shorten a URL using the plain text endpoint
 
 	// Set up a test server with a mock storage
-	mockStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	mockStorage := storage.NewMemoryStorage()
 	cfg := config.NewOptions()
-	handler := NewHandler(mockStorage, cfg)
+	deletions, _ := deletion.NewQueue(mockStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(mockStorage, cfg, deletions)
 
 	// Create a test request
 	longURL := "https://github.com/pcristin/urlshortener"
@@ -92,9 +98,11 @@ func ExampleHandlerInterface_APIEncodeHandler() {
 	// This example demonstrates how to shorten a URL using the JSON API
 
 	// Set up a test server with a mock storage
-	mockStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	mockStorage := storage.NewMemoryStorage()
 	cfg := config.NewOptions()
-	handler := NewHandler(mockStorage, cfg)
+	deletions, _ := deletion.NewQueue(mockStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(mockStorage, cfg, deletions)
 
 	// Create the request body
 	reqBody := models.Request{
@@ -139,9 +147,11 @@ func ExampleHandlerInterface_APIEncodeBatchHandler() {
 	// This example demonstrates how to shorten multiple URLs in a batch
 
 	// Set up a test server with a mock storage
-	mockStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	mockStorage := storage.NewMemoryStorage()
 	cfg := config.NewOptions()
-	handler := NewHandler(mockStorage, cfg)
+	deletions, _ := deletion.NewQueue(mockStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(mockStorage, cfg, deletions)
 
 	// Create the batch request body
 	batchReq := models.BatchRequest{
@@ -187,14 +197,16 @@ func ExampleHandlerInterface_DecodeURLHandler() {
 	// This example demonstrates how to use the redirect endpoint
 
 	// Set up a mock storage and add a URL
-	mockStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	mockStorage := storage.NewMemoryStorage()
 	token := "abc123"
 	longURL := "https://github.com/pcristin/urlshortener"
-	_ = mockStorage.AddURL(token, longURL, "test-user")
+	_ = mockStorage.AddURL(context.Background(), token, longURL, "test-user")
 
 	// Set up the handler
 	cfg := config.NewOptions()
-	handler := NewHandler(mockStorage, cfg)
+	deletions, _ := deletion.NewQueue(mockStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(mockStorage, cfg, deletions)
 
 	// Set up the router to handle URL parameters
 	r := chi.NewRouter()
@@ -229,14 +241,16 @@ func ExampleHandlerInterface_GetUserURLsHandler() {
 	// This example demonstrates how to get all URLs shortened by a user
 
 	// Set up a mock storage and add some URLs
-	mockStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	mockStorage := storage.NewMemoryStorage()
 	userID := "test-user"
-	_ = mockStorage.AddURL("abc123", "https://github.com/pcristin/urlshortener", userID)
-	_ = mockStorage.AddURL("def456", "https://golang.org", userID)
+	_ = mockStorage.AddURL(context.Background(), "abc123", "https://github.com/pcristin/urlshortener", userID)
+	_ = mockStorage.AddURL(context.Background(), "def456", "https://golang.org", userID)
 
 	// Set up the handler
 	cfg := config.NewOptions()
-	handler := NewHandler(mockStorage, cfg)
+	deletions, _ := deletion.NewQueue(mockStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(mockStorage, cfg, deletions)
 
 	// Create a test request
 	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
@@ -272,14 +286,16 @@ func ExampleHandlerInterface_DeleteUserURLsHandler() {
 	// This example demonstrates how to delete URLs
 
 	// Set up a mock storage and add some URLs
-	mockStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	mockStorage := storage.NewMemoryStorage()
 	userID := "test-user"
-	_ = mockStorage.AddURL("abc123", "https://github.com/pcristin/urlshortener", userID)
-	_ = mockStorage.AddURL("def456", "https://golang.org", userID)
+	_ = mockStorage.AddURL(context.Background(), "abc123", "https://github.com/pcristin/urlshortener", userID)
+	_ = mockStorage.AddURL(context.Background(), "def456", "https://golang.org", userID)
 
 	// Set up the handler
 	cfg := config.NewOptions()
-	handler := NewHandler(mockStorage, cfg)
+	deletions, _ := deletion.NewQueue(mockStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(mockStorage, cfg, deletions)
 
 	// Create the request body (list of tokens to delete)
 	tokens := []string{"abc123", "def456"}
@@ -306,8 +322,8 @@ func ExampleHandlerInterface_DeleteUserURLsHandler() {
 	fmt.Printf("Status: %d\n", resp.StatusCode)
 
 	// Verify the URLs are marked as deleted
-	_, err1 := mockStorage.GetURL("abc123")
-	_, err2 := mockStorage.GetURL("def456")
+	_, err1 := mockStorage.GetURL(context.Background(), "abc123")
+	_, err2 := mockStorage.GetURL(context.Background(), "def456")
 	fmt.Printf("First URL deleted: %t\n", err1 != nil)
 	fmt.Printf("Second URL deleted: %t\n", err2 != nil)
 
@@ -318,14 +334,16 @@ func ExampleHandlerInterface_DeleteUserURLsHandler() {
 }
 
 func ExampleHandlerInterface_PingHandler() {
-	// This example demonstrates how to check database connectivity
+	// This example demonstrates how to check the storage is usable
 
 	// Set up a mock storage
-	mockStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	mockStorage := storage.NewMemoryStorage()
 
 	// Set up the handler
 	cfg := config.NewOptions()
-	handler := NewHandler(mockStorage, cfg)
+	deletions, _ := deletion.NewQueue(mockStorage, deletion.Config{})
+	defer deletions.Close(context.Background())
+	handler := NewHandler(mockStorage, cfg, deletions)
 
 	// Create a test request
 	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
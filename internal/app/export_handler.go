@@ -0,0 +1,238 @@
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mailru/easyjson"
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/service"
+	"go.uber.org/zap"
+)
+
+// Export formats, as accepted by the format query parameter
+const (
+	exportCSV    = "csv"
+	exportNDJSON = "ndjson"
+	exportJSON   = "json"
+)
+
+// exportContentTypes maps the export formats to their media type
+var exportContentTypes = map[string]string{
+	exportCSV:    "text/csv",
+	exportNDJSON: ndjsonContentType,
+	exportJSON:   "application/json",
+}
+
+// csvExportHeader names the columns of a CSV export
+var csvExportHeader = []string{
+	"short_url", "original_url", "created_at", "is_deleted", "is_disabled",
+	"updated_at", "deleted_at", "source", "client_ip_hash",
+}
+
+// ExportUserURLsHandler handles GET /api/user/urls/export requests.
+// It streams all the URLs of the user with their metadata, deleted ones included, as CSV, NDJSON or JSON.
+// The format is given by the format query parameter, or else negotiated from the Accept header,
+// JSON by default. URLs are read from storage as they are written, so large accounts are never
+// held in memory.
+//
+// It returns 400 Bad Request for an unknown format, 406 Not Acceptable if the Accept header
+// matches no format and 401 Unauthorized without user.
+func (h *Handler) ExportUserURLsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		var ok bool
+		if format, ok = negotiateExportFormat(r.Header.Get("Accept")); !ok {
+			logger.HTTPError(w, r, "not acceptable: supported media types are text/csv, "+ndjsonContentType+" and application/json", http.StatusNotAcceptable)
+			return
+		}
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		logger.HTTPError(w, r, "bad request: format must be csv, ndjson or json", http.StatusBadRequest)
+		return
+	}
+
+	urls, err := h.shortener.ExportURLs(r.Context(), getUserIDFromContext(r.Context()))
+	switch {
+	case errors.Is(err, service.ErrUnauthenticated):
+		logger.HTTPError(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	case err != nil:
+		logger.HTTPError(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="urls.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	exporter := newURLExporter(format, w)
+	for node, err := range urls {
+		if err == nil {
+			err = exporter.Write(h.exportedURL(node, r))
+		}
+		if err != nil {
+			// The status is already sent: the truncated body tells the client the export failed
+			h.requestLogger(r).Error("Error exporting URLs", zap.Error(err))
+			return
+		}
+	}
+	if err := exporter.Close(); err != nil {
+		h.requestLogger(r).Error("Error exporting URLs", zap.Error(err))
+	}
+}
+
+// exportedURL returns the exported representation of a stored URL
+func (h *Handler) exportedURL(node mod.URLStorageNode, r *http.Request) mod.ExportedURL {
+	return mod.ExportedURL{
+		ShortURL:     h.constructURL(node.ShortURL, r),
+		OriginalURL:  node.OriginalURL,
+		CreatedAt:    exportedTime(node.CreatedAt),
+		UpdatedAt:    exportedTime(node.UpdatedAt),
+		DeletedAt:    exportedTime(node.DeletedAt),
+		IsDeleted:    node.IsDeleted,
+		IsDisabled:   node.IsDisabled,
+		Source:       node.Source,
+		ClientIPHash: node.ClientIPHash,
+	}
+}
+
+// exportedTime formats a time of a URL in RFC 3339, empty if it is unknown
+func exportedTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// negotiateExportFormat returns the first export format matching the Accept header, JSON if any is accepted
+func negotiateExportFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return exportJSON, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "*/*", "application/*", "application/json":
+			return exportJSON, true
+		case "text/csv", "text/*":
+			return exportCSV, true
+		case ndjsonContentType, "application/ndjson":
+			return exportNDJSON, true
+		}
+	}
+	return "", false
+}
+
+// urlExporter writes exported URLs in a format
+type urlExporter interface {
+	Write(mod.ExportedURL) error
+	// Close completes the export
+	Close() error
+}
+
+// newURLExporter returns the exporter of the format writing to w
+func newURLExporter(format string, w io.Writer) urlExporter {
+	switch format {
+	case exportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(csvExportHeader)
+		return &csvExporter{w: cw}
+	case exportNDJSON:
+		return &ndjsonExporter{w: w}
+	default:
+		return &jsonArrayExporter{w: w}
+	}
+}
+
+// csvExporter writes URLs as CSV rows, after the header written on creation
+type csvExporter struct {
+	w *csv.Writer
+}
+
+// Write implements urlExporter
+func (e *csvExporter) Write(u mod.ExportedURL) error {
+	e.w.Write([]string{
+		u.ShortURL, u.OriginalURL, u.CreatedAt, strconv.FormatBool(u.IsDeleted), strconv.FormatBool(u.IsDisabled),
+		u.UpdatedAt, u.DeletedAt, u.Source, u.ClientIPHash,
+	})
+	return e.w.Error()
+}
+
+// Close implements urlExporter
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonExporter writes URLs as lines of JSON objects
+type ndjsonExporter struct {
+	w io.Writer
+}
+
+// Write implements urlExporter
+func (e *ndjsonExporter) Write(u mod.ExportedURL) error {
+	data, err := easyjson.Marshal(u)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+// Close implements urlExporter
+func (e *ndjsonExporter) Close() error {
+	return nil
+}
+
+// jsonArrayExporter writes URLs as the items of a JSON array
+type jsonArrayExporter struct {
+	w     io.Writer
+	count int
+}
+
+// Write implements urlExporter
+func (e *jsonArrayExporter) Write(u mod.ExportedURL) error {
+	data, err := easyjson.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if e.count == 0 {
+		separator = "["
+	}
+	e.count++
+
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// Close implements urlExporter
+func (e *jsonArrayExporter) Close() error {
+	end := "]"
+	if e.count == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
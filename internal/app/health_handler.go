@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mailru/easyjson"
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// healthCheckTimeout bounds the health check of each component, so a hanging dependency
+// is reported as failing rather than blocking the probe
+const healthCheckTimeout = 2 * time.Second
+
+// healthComponents returns the components the service depends on, by name
+func (h *Handler) healthComponents() map[string]storage.HealthChecker {
+	return map[string]storage.HealthChecker{
+		"storage":        h.storage,
+		"deletion_queue": h.deletions,
+	}
+}
+
+// checkHealth checks the components concurrently and returns their outcome ordered by name,
+// with the overall status: ok if every component is ok, failing otherwise
+func (h *Handler) checkHealth(ctx context.Context) (string, []mod.HealthComponent) {
+	components := h.healthComponents()
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	results := make([]mod.HealthComponent, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = checkComponent(ctx, name, components[name])
+		}()
+	}
+	wg.Wait()
+
+	status := mod.HealthStatusOK
+	for _, result := range results {
+		if result.Status != mod.HealthStatusOK {
+			status = mod.HealthStatusFailing
+		}
+	}
+	return status, results
+}
+
+// checkComponent runs the health check of a component within healthCheckTimeout and measures its latency
+func checkComponent(ctx context.Context, name string, checker storage.HealthChecker) mod.HealthComponent {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.CheckHealth(ctx)
+	result := mod.HealthComponent{
+		Name:          name,
+		Status:        mod.HealthStatusOK,
+		LatencyMillis: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = mod.HealthStatusFailing
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "health check timed out"
+		}
+		logger.FromContext(ctx).Warn("Health check failed", zap.String("component", name), zap.Error(err))
+	}
+	return result
+}
+
+// LivenessHandler handles GET /healthz requests.
+// It responds with 200 OK as long as the process serves requests, so it is only restarted when stuck,
+// and reports the health of the components with the status degraded if any of them fails.
+func (h *Handler) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, components := h.checkHealth(r.Context())
+	if status != mod.HealthStatusOK {
+		status = mod.HealthStatusDegraded
+	}
+	writeHealth(w, r, http.StatusOK, mod.HealthResponse{Status: status, Components: components})
+}
+
+// ReadinessHandler handles GET /readyz requests.
+// It responds with 200 OK if the service can serve traffic, and 503 Service Unavailable while starting up,
+// such as during database migrations, once shutdown has begun or if any component fails.
+func (h *Handler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, components := h.checkHealth(r.Context())
+	switch {
+	case h.shuttingDown.Load():
+		status = mod.HealthStatusStopping
+	case h.starting.Load():
+		status = mod.HealthStatusStarting
+	}
+
+	code := http.StatusOK
+	if status != mod.HealthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, r, code, mod.HealthResponse{Status: status, Components: components})
+}
+
+// writeHealth responds with the health of the service; probes must never be served from a cache
+func writeHealth(w http.ResponseWriter, r *http.Request, code int, response mod.HealthResponse) {
+	responseBytes, err := easyjson.Marshal(response)
+	if err != nil {
+		logger.HTTPError(w, r, "internal server error: unable to marshal response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write(responseBytes)
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/mailru/easyjson"
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/service"
+	"go.uber.org/zap"
+)
+
+// ndjsonContentType is the media type of newline delimited JSON
+const ndjsonContentType = "application/x-ndjson"
+
+// APIImportHandler handles POST /api/shorten/import requests.
+// It reads a CSV (text/csv) or NDJSON (application/x-ndjson) upload row by row, shortens the URLs
+// in chunks and streams back the result of every row as NDJSON, so uploads are never held in memory.
+//
+// Once the upload is accepted the status is 200 OK: errors of rows are reported in their result,
+// and a final line with only an error reports an import stopped early, for instance by the row limit
+// or the body size limit. It returns 415 Unsupported Media Type for other content types and
+// 413 Request Entity Too Large for a declared body length above the limit.
+func (h *Handler) APIImportHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		logger.HTTPError(res, req, "bad request", http.StatusBadRequest)
+		return
+	}
+	defer req.Body.Close()
+
+	userID := getUserIDFromContext(req.Context())
+	if userID == "" {
+		logger.HTTPError(res, req, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var rows service.RowReader
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		rows = service.NewCSVRows(req.Body)
+	case ndjsonContentType, "application/ndjson":
+		rows = service.NewNDJSONRows(req.Body)
+	default:
+		logger.HTTPError(res, req, "unsupported media type: expected text/csv or "+ndjsonContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err := bodylimit.CheckContentLength(req); err != nil {
+		bodyError(res, req, err, "bad request")
+		return
+	}
+
+	// Results are written while the upload is still read: without full duplex, an HTTP/1.1 server
+	// discards the rest of the body when the response is first flushed. HTTP/2 is always full duplex
+	// and doesn't support the call, so its error is ignored.
+	_ = http.NewResponseController(res).EnableFullDuplex()
+
+	res.Header().Set("Content-Type", ndjsonContentType)
+	res.WriteHeader(http.StatusOK)
+
+	emit := func(r service.ImportResult) error {
+		result := mod.ImportResult{
+			Line:          r.Line,
+			CorrelationID: r.CorrelationID,
+			Existing:      r.Existing,
+		}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+		} else {
+			result.ShortURL = h.constructURL(r.Token, req)
+		}
+		return writeNDJSON(res, result)
+	}
+
+	maxRows := h.config.GetImportMaxRows()
+	err := h.shortener.Import(h.creatorContext(req, mod.SourceImport), userID, rows, maxRows, emit)
+	var limitErr *bodylimit.Error
+	switch {
+	case err == nil:
+		return
+	case errors.Is(err, service.ErrRowLimit):
+		err = fmt.Errorf("row limit of %d exceeded, the remaining rows were not imported", maxRows)
+	case errors.As(err, &limitErr):
+		err = fmt.Errorf("%w, the remaining rows were not imported", limitErr)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		err = errors.New("import interrupted, the remaining rows were not imported")
+	default:
+		h.requestLogger(req).Error("Error importing URLs", zap.Error(err))
+		err = errors.New("internal server error, the remaining rows were not imported")
+	}
+	writeNDJSON(res, mod.ImportResult{Error: err.Error()})
+}
+
+// writeNDJSON writes a result as a line of NDJSON
+func writeNDJSON(res http.ResponseWriter, result mod.ImportResult) error {
+	data, err := easyjson.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = res.Write(append(data, '\n'))
+	return err
+}
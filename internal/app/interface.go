@@ -1,6 +1,9 @@
 package app
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // HandlerInterface defines the contract for all HTTP handlers in the URL shortener service.
 // It provides methods for encoding and decoding URLs, handling API requests, and middleware functionality.
@@ -17,15 +20,90 @@ type HandlerInterface interface {
 	// APIEncodeBatchHandler handles requests to shorten multiple URLs in a single batch operation
 	APIEncodeBatchHandler(http.ResponseWriter, *http.Request)
 
-	// PingHandler checks the database connection health
+	// APIImportHandler handles streaming bulk imports of URLs from CSV or NDJSON uploads
+	APIImportHandler(http.ResponseWriter, *http.Request)
+
+	// PingHandler checks the storage health
 	PingHandler(http.ResponseWriter, *http.Request)
 
+	// LivenessHandler reports the health of the service and its components, failing only if the process is stuck
+	LivenessHandler(http.ResponseWriter, *http.Request)
+
+	// ReadinessHandler reports whether the service can serve traffic
+	ReadinessHandler(http.ResponseWriter, *http.Request)
+
 	// GetUserURLsHandler returns all URLs shortened by a specific user
 	GetUserURLsHandler(http.ResponseWriter, *http.Request)
 
+	// ExportUserURLsHandler streams all the URLs of the user with their metadata as CSV, NDJSON or JSON
+	ExportUserURLsHandler(http.ResponseWriter, *http.Request)
+
 	// DeleteUserURLsHandler marks user's URLs as deleted
 	DeleteUserURLsHandler(http.ResponseWriter, *http.Request)
 
+	// RegisterHandler creates a user account and logs the user in
+	RegisterHandler(http.ResponseWriter, *http.Request)
+
+	// LoginHandler logs a registered user in
+	LoginHandler(http.ResponseWriter, *http.Request)
+
+	// OIDCLoginHandler starts the OpenID Connect login flow
+	OIDCLoginHandler(http.ResponseWriter, *http.Request)
+
+	// OIDCCallbackHandler completes the OpenID Connect login flow
+	OIDCCallbackHandler(http.ResponseWriter, *http.Request)
+
+	// AdminListURLsHandler lists and searches the URLs of all users
+	AdminListURLsHandler(http.ResponseWriter, *http.Request)
+
+	// AdminUserURLsHandler lists all URLs of a user
+	AdminUserURLsHandler(http.ResponseWriter, *http.Request)
+
+	// AdminDisableURLHandler disables a URL
+	AdminDisableURLHandler(http.ResponseWriter, *http.Request)
+
+	// AdminEnableURLHandler enables a previously disabled URL
+	AdminEnableURLHandler(http.ResponseWriter, *http.Request)
+
+	// AdminSetURLOwnerHandler transfers ownership of a URL
+	AdminSetURLOwnerHandler(http.ResponseWriter, *http.Request)
+
+	// AdminDeleteURLHandler permanently removes a URL
+	AdminDeleteURLHandler(http.ResponseWriter, *http.Request)
+
+	// AdminReloadConfigHandler reloads the runtime configuration
+	AdminReloadConfigHandler(http.ResponseWriter, *http.Request)
+
+	// AdminGetLogLevelHandler returns the current log level
+	AdminGetLogLevelHandler(http.ResponseWriter, *http.Request)
+
+	// AdminSetLogLevelHandler changes the log level at runtime
+	AdminSetLogLevelHandler(http.ResponseWriter, *http.Request)
+
+	// StatsHandler returns service-wide statistics
+	StatsHandler(http.ResponseWriter, *http.Request)
+
 	// AuthMiddleware provides authentication and user identification functionality
 	AuthMiddleware(http.HandlerFunc) http.HandlerFunc
+
+	// AdminMiddleware restricts access to the admin API
+	AdminMiddleware(http.HandlerFunc) http.HandlerFunc
+
+	// TrustedSubnetMiddleware restricts access to clients from the trusted subnet
+	TrustedSubnetMiddleware(http.HandlerFunc) http.HandlerFunc
+
+	// StartupMiddleware answers 503 Service Unavailable until startup tasks are done
+	StartupMiddleware(http.Handler) http.Handler
+
+	// BeginStartup makes readiness checks fail until FinishStartup is called
+	BeginStartup()
+
+	// FinishStartup makes readiness checks succeed once startup tasks are done
+	FinishStartup()
+
+	// BeginShutdown makes readiness checks fail once shutdown has started
+	BeginShutdown()
+
+	// Drain waits for background jobs to finish or the context to be done
+	Drain(context.Context) error
 }
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pcristin/urlshortener/internal/logger"
+)
+
+// BeginStartup marks the handler as starting up: readiness checks fail and StartupMiddleware rejects
+// requests until FinishStartup is called, while the tasks the service needs before serving traffic,
+// such as database migrations, run.
+func (h *Handler) BeginStartup() {
+	h.starting.Store(true)
+}
+
+// FinishStartup marks the handler as started, readiness checks succeed once its components are healthy
+func (h *Handler) FinishStartup() {
+	h.starting.Store(false)
+}
+
+// StartupMiddleware rejects requests with 503 Service Unavailable while the handler is starting up,
+// so the API isn't served before startup tasks such as database migrations are done.
+// Clients are asked to retry after a second.
+func (h *Handler) StartupMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.starting.Load() {
+			w.Header().Set("Retry-After", "1")
+			logger.HTTPError(w, r, "service unavailable: starting up", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BeginShutdown marks the handler as shutting down.
+// Readiness checks fail from this point on so load balancers stop routing new traffic to the instance,
+// while requests already accepted keep being served.
+func (h *Handler) BeginShutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Drain waits for the background jobs started by handlers, such as queued deletions, to finish.
+// Deletions requested after Drain has been called run synchronously within their request instead.
+// It returns the context error if the jobs don't finish before the context is done.
+func (h *Handler) Drain(ctx context.Context) error {
+	h.BeginShutdown()
+	return h.deletions.Close(ctx)
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/oidc"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// oidcFlowCookieName holds the state, nonce and PKCE verifier of a login in progress
+	oidcFlowCookieName = "oidc_flow"
+	// oidcFlowTTL limits how long a user may take to authenticate at the identity provider
+	oidcFlowTTL = 10 * time.Minute
+	// oidcLoginPrefix marks the logins of accounts created by SSO, which can't be registered with a password
+	oidcLoginPrefix = "oidc|"
+)
+
+// oidcFlow is the state of an authorization code flow kept in a signed cookie between redirects
+type oidcFlow struct {
+	state    string
+	nonce    string
+	verifier string
+}
+
+// encode serializes the flow and signs it with the handler secret
+func (f oidcFlow) encode(secret []byte) string {
+	payload := f.state + "." + f.nonce + "." + f.verifier
+	return payload + "." + generateSignature(payload, secret)
+}
+
+// decodeOIDCFlow parses and validates a signed flow cookie value
+func decodeOIDCFlow(value string, secret []byte) (oidcFlow, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 4 {
+		return oidcFlow{}, false
+	}
+	if !validateSignature(strings.Join(parts[:3], "."), parts[3], secret) {
+		return oidcFlow{}, false
+	}
+	return oidcFlow{state: parts[0], nonce: parts[1], verifier: parts[2]}, true
+}
+
+// OIDCLoginHandler handles GET /api/user/oidc/login requests.
+// It starts the OpenID Connect authorization code flow with PKCE by redirecting
+// the user to the identity provider.
+//
+// It returns 404 Not Found if no identity provider is configured.
+func (h *Handler) OIDCLoginHandler(res http.ResponseWriter, req *http.Request) {
+	if h.identityProvider == nil {
+		logger.HTTPError(res, req, oidc.ErrNotConfigured.Error(), http.StatusNotFound)
+		return
+	}
+
+	var flow oidcFlow
+	for _, value := range []*string{&flow.state, &flow.nonce, &flow.verifier} {
+		random, err := oidc.GenerateVerifier()
+		if err != nil {
+			logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		*value = random
+	}
+
+	authURL, err := h.identityProvider.AuthCodeURL(req.Context(), flow.state, flow.nonce, flow.verifier)
+	if err != nil {
+		h.requestLogger(req).Error("Error building authorization URL", zap.Error(err))
+		logger.HTTPError(res, req, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(res, &http.Cookie{
+		Name:     oidcFlowCookieName,
+		Value:    flow.encode([]byte(h.secret)),
+		Path:     "/api/user/oidc",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(res, req, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler handles GET /api/user/oidc/callback requests.
+// It exchanges the authorization code for a verified identity, maps the identity provider
+// subject to a shortener account, creating one on first login, and logs the user in.
+//
+// If the request carries a valid anonymous identity, the URLs shortened under it
+// are transferred to the account. On success the user is redirected to their URLs.
+func (h *Handler) OIDCCallbackHandler(res http.ResponseWriter, req *http.Request) {
+	if h.identityProvider == nil {
+		logger.HTTPError(res, req, oidc.ErrNotConfigured.Error(), http.StatusNotFound)
+		return
+	}
+
+	flowCookie, err := req.Cookie(oidcFlowCookieName)
+	if err != nil {
+		logger.HTTPError(res, req, "bad request: no login in progress", http.StatusBadRequest)
+		return
+	}
+	flow, ok := decodeOIDCFlow(flowCookie.Value, []byte(h.secret))
+	if !ok || req.URL.Query().Get("state") != flow.state {
+		logger.HTTPError(res, req, "bad request: invalid state", http.StatusBadRequest)
+		return
+	}
+
+	// The flow cookie is single use
+	http.SetCookie(res, &http.Cookie{
+		Name:   oidcFlowCookieName,
+		Path:   "/api/user/oidc",
+		MaxAge: -1,
+	})
+
+	if errParam := req.URL.Query().Get("error"); errParam != "" {
+		logger.HTTPError(res, req, "login failed: "+errParam, http.StatusUnauthorized)
+		return
+	}
+
+	code := req.URL.Query().Get("code")
+	if code == "" {
+		logger.HTTPError(res, req, "bad request: missing code", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := h.identityProvider.Exchange(req.Context(), code, flow.verifier, flow.nonce)
+	if err != nil {
+		h.requestLogger(req).Warn("OpenID Connect login failed", zap.Error(err))
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			logger.HTTPError(res, req, "login failed", http.StatusUnauthorized)
+			return
+		}
+		logger.HTTPError(res, req, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	user, err := h.userForIdentity(req.Context(), identity)
+	if err != nil {
+		h.requestLogger(req).Error("Error mapping identity to user", zap.Error(err))
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.claimAnonymousURLs(req, user); err != nil {
+		h.requestLogger(req).Error("Error claiming anonymous URLs", zap.Error(err))
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.setAuthCookies(res, user.ID)
+	http.Redirect(res, req, "/api/user/urls", http.StatusSeeOther)
+}
+
+// userForIdentity returns the account linked to the identity provider subject, creating it on first login
+func (h *Handler) userForIdentity(ctx context.Context, identity oidc.Identity) (mod.User, error) {
+	login := oidcLoginPrefix + identity.Issuer + "|" + identity.Subject
+
+	user, err := h.storage.GetUserByLogin(ctx, login)
+	if err == nil || !errors.Is(err, storage.ErrUserNotFound) {
+		return user, err
+	}
+
+	// SSO accounts have no password, so they can't log in through LoginHandler
+	user = mod.User{
+		ID:        uuid.New().String(),
+		Login:     login,
+		CreatedAt: time.Now(),
+	}
+	if err := h.storage.AddUser(ctx, user); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			// Created concurrently by another login of the same user
+			return h.storage.GetUserByLogin(ctx, login)
+		}
+		return mod.User{}, err
+	}
+	return user, nil
+}
@@ -3,25 +3,28 @@ package app
 import (
 	"net/http"
 
-	"github.com/pcristin/urlshortener/internal/storage"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"go.uber.org/zap"
 )
 
-// Handler to check the connectivity to the database
+// PingHandler checks the storage is usable: it pings the database, checks the data file can be
+// written and always succeeds for memory storage. It fails as soon as shutdown begins.
+// ReadinessHandler reports the same with the details of every component.
 func (h *Handler) PingHandler(res http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(res, "bad request", http.StatusBadRequest)
+		logger.HTTPError(res, req, "bad request", http.StatusBadRequest)
 		return
 	}
 
-	// Get the database storage (this handler only applicable for DB storage)
-	storage, ok := h.storage.(*storage.DatabaseStorage)
-	if !ok || storage.GetDBPool() == nil {
-		http.Error(res, "database not configured", http.StatusInternalServerError)
+	// Fail readiness as soon as shutdown begins
+	if h.shuttingDown.Load() {
+		logger.HTTPError(res, req, "shutting down", http.StatusServiceUnavailable)
 		return
 	}
 
-	if err := storage.GetDBPool().Ping(req.Context()); err != nil {
-		http.Error(res, "internal server error", http.StatusInternalServerError)
+	if err := h.storage.CheckHealth(req.Context()); err != nil {
+		h.requestLogger(req).Error("Storage health check failed", zap.Error(err))
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/mailru/easyjson"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// TrustedSubnetMiddleware restricts access to internal endpoints to clients from the trusted subnet.
+// The client IP is taken from the X-Real-IP header set by the reverse proxy, falling back to
+// the remote address of the connection. If no trusted subnet is configured, access is denied.
+func (h *Handler) TrustedSubnetMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.trustedSubnet == nil {
+			logger.HTTPError(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ip := clientIP(r)
+		if ip == nil || !h.trustedSubnet.Contains(ip) {
+			logger.HTTPError(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// clientIP returns the IP address of the client from X-Real-IP or the remote address
+func clientIP(r *http.Request) net.IP {
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		return net.ParseIP(realIP)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// StatsHandler handles GET /api/internal/stats requests.
+// It returns the total number of URLs and users, the number of deleted URLs,
+// the number of URLs shortened today and the depth of the deletion queue.
+func (h *Handler) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := h.storage.GetStats(r.Context())
+	if err != nil {
+		h.requestLogger(r).Error("Error getting stats", zap.Error(err))
+		logger.HTTPError(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	stats.PendingDeletions = h.deletions.Depth()
+
+	responseBytes, err := easyjson.Marshal(stats)
+	if err != nil {
+		logger.HTTPError(w, r, "internal server error: unable to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBytes)
+}
@@ -1,9 +1,17 @@
 package app
 
 import (
+	"errors"
+	"net"
 	"net/http"
+	"sync/atomic"
 
-	"github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	cfg "github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/deletion"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/pcristin/urlshortener/internal/oidc"
+	"github.com/pcristin/urlshortener/internal/service"
 	"github.com/pcristin/urlshortener/internal/storage"
 	"go.uber.org/zap"
 )
@@ -11,33 +19,88 @@ import (
 // Handler implements the HandlerInterface and provides HTTP request handling functionality
 // for the URL shortener service. It manages URL storage, authentication, and URL construction.
 type Handler struct {
-	storage storage.URLStorager
-	secret  string
-	baseURL string
-	logger  *zap.Logger
+	storage    storage.URLStorager
+	shortener  *service.Shortener
+	config     *cfg.Options
+	secret     string
+	adminToken string
+	logger     *zap.Logger
+
+	// trustedSubnet is allowed to read internal statistics, nil if access is disabled
+	trustedSubnet *net.IPNet
+
+	identityProvider oidc.IdentityProvider
+
+	// deletions deletes URLs in the background, it is drained before shutdown
+	deletions *deletion.Queue
+
+	// starting is set until startup tasks such as database migrations are done, failing readiness checks
+	starting atomic.Bool
+	// shuttingDown is set as soon as shutdown begins so readiness checks start failing
+	shuttingDown atomic.Bool
 }
 
 // NewHandler creates a new Handler instance with the provided storage and configuration.
 // It initializes the handler with storage, secret key for authentication, base URL for shortened links,
-// and a logger instance.
-func NewHandler(storage storage.URLStorager, config *config.Options) HandlerInterface {
+// a logger instance and, if an issuer is configured, the OpenID Connect identity provider.
+// The URL shortening use cases are delegated to a service.Shortener sharing the storage.
+// URLs are deleted through the deletions queue, which the handler closes on Drain.
+func NewHandler(storage storage.URLStorager, config *cfg.Options, deletions *deletion.Queue) HandlerInterface {
 	secret := config.GetSecret()
 	if secret == "" {
 		secret = "your-secret-key" // fallback for tests and development
 	}
 
-	return &Handler{
-		storage: storage,
-		secret:  secret,
-		baseURL: config.GetBaseURL(),
-		logger:  zap.L(),
+	handler := &Handler{
+		storage:    storage,
+		shortener:  service.NewShortener(storage, config),
+		config:     config,
+		secret:     secret,
+		adminToken: config.GetAdminToken(),
+		logger:     zap.L(),
+		deletions:  deletions,
+	}
+
+	if cidr := config.GetTrustedSubnet(); cidr != "" {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			handler.logger.Error("Invalid trusted subnet, internal statistics disabled", zap.String("cidr", cidr), zap.Error(err))
+		} else {
+			handler.trustedSubnet = subnet
+		}
+	}
+
+	if issuer := config.GetOIDCIssuer(); issuer != "" {
+		handler.identityProvider = oidc.NewProvider(oidc.Config{
+			Issuer:       issuer,
+			ClientID:     config.GetOIDCClientID(),
+			ClientSecret: config.GetOIDCClientSecret(),
+			RedirectURL:  config.GetOIDCRedirectURL(),
+			Scopes:       []string{"email"},
+		})
 	}
+
+	return handler
+}
+
+// requestLogger returns the logger of the handler with the fields of the request context,
+// such as the request ID, route and user ID
+func (h *Handler) requestLogger(r *http.Request) *zap.Logger {
+	return h.logger.With(logger.Fields(r.Context())...)
+}
+
+// bodyError responds to a request whose body could not be read or decoded: 413 Request Entity Too Large
+// if the body exceeds its limit, otherwise 400 Bad Request with the message
+func bodyError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var limitErr *bodylimit.Error
+	if errors.As(err, &limitErr) {
+		logger.HTTPError(w, r, "request entity too large: "+limitErr.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	logger.HTTPError(w, r, message, http.StatusBadRequest)
 }
 
 // constructURL builds the full URL for a shortened link
 func (h *Handler) constructURL(token string, r *http.Request) string {
-	if h.baseURL != "" {
-		return h.baseURL + "/" + token
-	}
-	return "http://" + r.Host + "/" + token
+	return h.shortener.ShortURL(token, r.Host, r.TLS != nil)
 }
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/mailru/easyjson"
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// minPasswordLength is the minimal accepted length of an account password, in characters
+	minPasswordLength = 8
+	// maxPasswordBytes is the maximal accepted size of an account password, bcrypt rejects longer ones
+	maxPasswordBytes = 72
+)
+
+// RegisterHandler handles POST /api/user/register requests.
+// It creates a new account from the login and password in the JSON body and logs the user in.
+//
+// If the request carries a valid anonymous identity, the URLs shortened under it
+// are transferred to the new account.
+//
+// It returns 200 OK on success, 400 Bad Request for malformed credentials
+// and 409 Conflict if the login is already taken.
+func (h *Handler) RegisterHandler(res http.ResponseWriter, req *http.Request) {
+	creds, ok := h.readCredentials(res, req)
+	if !ok {
+		return
+	}
+
+	if strings.HasPrefix(creds.Login, oidcLoginPrefix) {
+		logger.HTTPError(res, req, "bad request: incorrect login", http.StatusBadRequest)
+		return
+	}
+
+	if utf8.RuneCountInString(creds.Password) < minPasswordLength {
+		logger.HTTPError(res, req, "bad request: password is too short", http.StatusBadRequest)
+		return
+	}
+	if len(creds.Password) > maxPasswordBytes {
+		logger.HTTPError(res, req, "bad request: password is too long", http.StatusBadRequest)
+		return
+	}
+
+	passwordHash, err := hashPassword(creds.Password)
+	if err != nil {
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := mod.User{
+		ID:           uuid.New().String(),
+		Login:        creds.Login,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := h.storage.AddUser(req.Context(), user); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			logger.HTTPError(res, req, "login already taken", http.StatusConflict)
+			return
+		}
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logIn(res, req, user)
+}
+
+// LoginHandler handles POST /api/user/login requests.
+// It checks the login and password in the JSON body and sets the account identity cookies.
+//
+// If the request carries a valid anonymous identity, the URLs shortened under it
+// are transferred to the account.
+//
+// It returns 200 OK on success, 400 Bad Request for malformed credentials
+// and 401 Unauthorized for a wrong login or password.
+func (h *Handler) LoginHandler(res http.ResponseWriter, req *http.Request) {
+	creds, ok := h.readCredentials(res, req)
+	if !ok {
+		return
+	}
+
+	user, err := h.storage.GetUserByLogin(req.Context(), creds.Login)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.HTTPError(res, req, "invalid login or password", http.StatusUnauthorized)
+			return
+		}
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !checkPassword(user.PasswordHash, creds.Password) {
+		logger.HTTPError(res, req, "invalid login or password", http.StatusUnauthorized)
+		return
+	}
+
+	h.logIn(res, req, user)
+}
+
+// readCredentials decodes the credentials from the request body, writing an error response on failure
+func (h *Handler) readCredentials(res http.ResponseWriter, req *http.Request) (mod.Credentials, bool) {
+	var creds mod.Credentials
+
+	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+		logger.HTTPError(res, req, "bad request", http.StatusBadRequest)
+		return creds, false
+	}
+
+	err := easyjson.UnmarshalFromReader(req.Body, &creds)
+	defer req.Body.Close()
+
+	if err != nil || creds.Login == "" || creds.Password == "" {
+		bodyError(res, req, err, "bad request: incorrect credentials")
+		return creds, false
+	}
+	return creds, true
+}
+
+// logIn claims the links of the anonymous identity and sets the account identity cookies
+func (h *Handler) logIn(res http.ResponseWriter, req *http.Request, user mod.User) {
+	if err := h.claimAnonymousURLs(req, user); err != nil {
+		h.requestLogger(req).Error("Error claiming anonymous URLs", zap.Error(err))
+		logger.HTTPError(res, req, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.setAuthCookies(res, user.ID)
+	res.WriteHeader(http.StatusOK)
+}
+
+// claimAnonymousURLs transfers the URLs of the anonymous identity carried by the request, if any, to the account
+func (h *Handler) claimAnonymousURLs(req *http.Request, user mod.User) error {
+	anonymousID, ok := h.userIDFromCookies(req)
+	if !ok || anonymousID == user.ID {
+		return nil
+	}
+
+	// Only anonymous identities can be claimed, never another account
+	if _, err := h.storage.GetUserByID(req.Context(), anonymousID); !errors.Is(err, storage.ErrUserNotFound) {
+		return err
+	}
+	return h.storage.ReassignUserURLs(req.Context(), anonymousID, user.ID)
+}
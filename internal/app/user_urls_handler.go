@@ -2,55 +2,125 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/pcristin/urlshortener/internal/service"
+	"go.uber.org/zap"
 )
 
-// UserURL represents a shortened URL with its original URL for API responses
+// UserURL represents a shortened URL with its original URL and metadata for API responses.
+// Times are omitted when unknown, for URLs stored before they were recorded.
 type UserURL struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL     string    `json:"short_url"`
+	OriginalURL  string    `json:"original_url"`
+	CreatedAt    time.Time `json:"created_at,omitzero"`
+	UpdatedAt    time.Time `json:"updated_at,omitzero"`
+	DeletedAt    time.Time `json:"deleted_at,omitzero"`
+	IsDeleted    bool      `json:"is_deleted,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	ClientIPHash string    `json:"client_ip_hash,omitempty"`
 }
 
-// GetUserURLsHandler handles GET /api/user/urls requests
+// GetUserURLsHandler handles GET /api/user/urls requests.
+// It returns a page of the URLs of the user, selected by the limit, cursor, sort, include_deleted, domain,
+// created_after, created_before and search query parameters. The following page is linked by
+// a Link header with rel="next", absent on the last page.
 func (h *Handler) GetUserURLsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		logger.HTTPError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get user ID from context
-	userID := getUserIDFromContext(r.Context())
-	if userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	pageRequest, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		logger.HTTPError(w, r, "bad request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Get user's URLs from storage
-	urls, err := h.storage.GetUserURLs(userID)
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// Get the URLs of the user from the context
+	page, err := h.shortener.UserURLsPage(r.Context(), getUserIDFromContext(r.Context()), pageRequest)
+	switch {
+	case errors.Is(err, service.ErrUnauthenticated):
+		logger.HTTPError(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	case errors.Is(err, service.ErrInvalidPage):
+		logger.HTTPError(w, r, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		h.requestLogger(r).Error("Error listing user URLs", zap.Error(err))
+		logger.HTTPError(w, r, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	// If no URLs found, return 204 No Content
-	if len(urls) == 0 {
+	if len(page.URLs) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	if page.NextCursor != "" {
+		query := r.URL.Query()
+		query.Set("cursor", page.NextCursor)
+		next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+
 	// Convert storage nodes to response format
-	response := make([]UserURL, len(urls))
-	for i, url := range urls {
+	response := make([]UserURL, len(page.URLs))
+	for i, url := range page.URLs {
 		response[i] = UserURL{
-			ShortURL:    h.constructURL(url.ShortURL, r),
-			OriginalURL: url.OriginalURL,
+			ShortURL:     h.constructURL(url.ShortURL, r),
+			OriginalURL:  url.OriginalURL,
+			CreatedAt:    url.CreatedAt,
+			UpdatedAt:    url.UpdatedAt,
+			DeletedAt:    url.DeletedAt,
+			IsDeleted:    url.IsDeleted,
+			Source:       url.Source,
+			ClientIPHash: url.ClientIPHash,
 		}
 	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logger.HTTPError(w, r, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
+
+// parsePageRequest reads the page of URLs requested by the query parameters
+func parsePageRequest(query url.Values) (service.PageRequest, error) {
+	req := service.PageRequest{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+		Domain: query.Get("domain"),
+		Search: query.Get("search"),
+	}
+
+	var err error
+	if req.Limit, err = queryInt(query.Get("limit"), service.DefaultPageSize); err != nil || req.Limit <= 0 {
+		return req, errors.New("incorrect limit")
+	}
+	if value := query.Get("include_deleted"); value != "" {
+		if req.IncludeDeleted, err = strconv.ParseBool(value); err != nil {
+			return req, errors.New("incorrect include_deleted, expected true or false")
+		}
+	}
+	for _, param := range []struct {
+		name string
+		t    *time.Time
+	}{{"created_after", &req.CreatedAfter}, {"created_before", &req.CreatedBefore}} {
+		if value := query.Get(param.name); value != "" {
+			if *param.t, err = time.Parse(time.RFC3339, value); err != nil {
+				return req, fmt.Errorf("incorrect %s, expected an RFC 3339 time", param.name)
+			}
+		}
+	}
+	return req, nil
+}
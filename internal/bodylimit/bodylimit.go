@@ -0,0 +1,210 @@
+// Package bodylimit bounds the size of HTTP request bodies, so a client can't exhaust the memory
+// of the server with a large upload or a small compressed body inflating to gigabytes.
+//
+// Middleware limits the bytes received, and LimitDecompressed the bytes a decompressed body
+// inflates to, both by the limit of the route, as well as the ratio of decompressed to compressed bytes.
+// Reads past a limit fail with an *Error, which handlers report with 413 Request Entity Too Large.
+package bodylimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ratioGrace is the number of decompressed bytes read before the ratio limit applies,
+// so small bodies of repetitive JSON aren't rejected for compressing well
+const ratioGrace = 64 << 10
+
+// Config sets the limits of request bodies
+type Config struct {
+	// MaxBytes is the maximal size of a body, before and after decompression
+	MaxBytes int64
+	// Routes overrides MaxBytes for chi route patterns, such as "/api/shorten/import"
+	Routes map[string]int64
+	// MaxRatio is the maximal ratio of decompressed to compressed bytes, unlimited if zero
+	MaxRatio int64
+}
+
+// Error is returned by reads of a body exceeding a limit
+type Error struct {
+	// Limit is the maximal size of the body
+	Limit int64
+	// Ratio is the exceeded decompression ratio, zero if the size limit was exceeded
+	Ratio int64
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	if e.Ratio > 0 {
+		return fmt.Sprintf("request body decompresses to more than %d times its size", e.Ratio)
+	}
+	return fmt.Sprintf("request body larger than %d bytes", e.Limit)
+}
+
+// ParseRoutes parses the limits of routes written as comma separated route=bytes pairs
+func ParseRoutes(s string) (map[string]int64, error) {
+	routes := make(map[string]int64)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		route, value, found := strings.Cut(pair, "=")
+		if !found || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("body limit %q: expected route=bytes", pair)
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("body limit %q: bytes must be a positive integer", pair)
+		}
+		routes[route] = limit
+	}
+	return routes, nil
+}
+
+// Limiter limits the request bodies of a router
+type Limiter struct {
+	config Config
+}
+
+// New creates a limiter enforcing the limits of the configuration
+func New(config Config) *Limiter {
+	return &Limiter{config: config}
+}
+
+// limit returns the maximal body size of a route
+func (l *Limiter) limit(route string) int64 {
+	if limit, ok := l.config.Routes[route]; ok {
+		return limit
+	}
+	return l.config.MaxBytes
+}
+
+// bodyContextKey is the context key of the limited body of the request being served
+type bodyContextKey struct{}
+
+// Middleware limits the bytes received in request bodies. It must be installed on the chi router:
+// the route, which sets the limit, is looked up on the first read, once the request has been routed.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		b := &body{ReadCloser: r.Body, limiter: l, routing: chi.RouteContext(r.Context()), contentLength: r.ContentLength}
+		r = r.WithContext(context.WithValue(r.Context(), bodyContextKey{}, b))
+		r.Body = b
+		next.ServeHTTP(w, r)
+	})
+}
+
+// body limits the bytes received in a request body
+type body struct {
+	io.ReadCloser
+	limiter       *Limiter
+	routing       *chi.Context
+	contentLength int64
+
+	max  int64
+	read int64
+	err  error
+}
+
+// limit returns the maximal size of the body, set by the route of the request
+func (b *body) limit() int64 {
+	if b.max == 0 {
+		route := ""
+		if b.routing != nil {
+			route = b.routing.RoutePattern()
+		}
+		b.max = b.limiter.limit(route)
+	}
+	return b.max
+}
+
+// Read implements io.Reader, failing as soon as the body is known to exceed its limit
+func (b *body) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	limit := b.limit()
+	if b.contentLength > limit {
+		b.err = &Error{Limit: limit}
+		return 0, b.err
+	}
+
+	n, err := readLimited(b.ReadCloser, p, limit-b.read)
+	b.read += int64(n)
+	if b.read > limit {
+		b.err = &Error{Limit: limit}
+		return n - int(b.read-limit), b.err
+	}
+	return n, err
+}
+
+// CheckContentLength returns an *Error if the declared length of the request body exceeds the limit
+// of its route, for handlers that must reject the request before reading the body.
+// It returns nil outside Middleware.
+func CheckContentLength(r *http.Request) error {
+	b, ok := r.Context().Value(bodyContextKey{}).(*body)
+	if !ok {
+		return nil
+	}
+	if limit := b.limit(); b.contentLength > limit {
+		return &Error{Limit: limit}
+	}
+	return nil
+}
+
+// readLimited reads at most remaining+1 bytes, one more than allowed to detect an exceeded limit
+func readLimited(r io.Reader, p []byte, remaining int64) (int, error) {
+	if int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	return r.Read(p)
+}
+
+// LimitDecompressed limits the bytes the decompressed body of the request inflates to, by the size
+// limit of its route and the ratio limit. It returns the body unchanged outside Middleware.
+func LimitDecompressed(r *http.Request, decompressed io.ReadCloser) io.ReadCloser {
+	raw, ok := r.Context().Value(bodyContextKey{}).(*body)
+	if !ok {
+		return decompressed
+	}
+	return &decompressedBody{ReadCloser: decompressed, raw: raw}
+}
+
+// decompressedBody limits the bytes a body inflates to
+type decompressedBody struct {
+	io.ReadCloser
+	raw *body
+
+	read int64
+	err  error
+}
+
+// Read implements io.Reader, failing as soon as the decompressed body exceeds a limit
+func (d *decompressedBody) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	limit := d.raw.limit()
+	n, err := readLimited(d.ReadCloser, p, limit-d.read)
+	d.read += int64(n)
+	if d.read > limit {
+		d.err = &Error{Limit: limit}
+		return n - int(d.read-limit), d.err
+	}
+	if ratio := d.raw.limiter.config.MaxRatio; ratio > 0 && d.read > ratioGrace && d.read > ratio*d.raw.read {
+		d.err = &Error{Limit: limit, Ratio: ratio}
+		return 0, d.err
+	}
+	return n, err
+}
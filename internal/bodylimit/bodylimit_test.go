@@ -0,0 +1,145 @@
+package bodylimit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes("/api/shorten/import=1048576, /api/shorten/batch=2048,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"/api/shorten/import": 1048576, "/api/shorten/batch": 2048}, routes)
+
+	for _, invalid := range []string{"/api/shorten", "api/shorten=10", "/api/shorten=0", "/api/shorten=ten"} {
+		_, err := ParseRoutes(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter := New(Config{MaxBytes: 10, Routes: map[string]int64{"/import": 100}})
+
+	var readErr error
+	var received []byte
+	r := chi.NewRouter()
+	r.Use(limiter.Middleware)
+	read := func(w http.ResponseWriter, r *http.Request) {
+		received, readErr = io.ReadAll(r.Body)
+	}
+	r.Post("/", read)
+	r.Post("/import", read)
+
+	tests := []struct {
+		name          string
+		path          string
+		body          string
+		hideLength    bool
+		wantErr       bool
+		wantReceived  string
+		wantErrorText string
+	}{
+		{name: "within limit", path: "/", body: "0123456789", wantReceived: "0123456789"},
+		{name: "declared length above limit", path: "/", body: "0123456789a", wantErr: true, wantErrorText: "request body larger than 10 bytes"},
+		{name: "streamed above limit", path: "/", body: "0123456789a", hideLength: true, wantErr: true, wantReceived: "0123456789"},
+		{name: "route limit", path: "/import", body: strings.Repeat("a", 100), wantReceived: strings.Repeat("a", 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.hideLength {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			var limitErr *Error
+			assert.Equal(t, tt.wantErr, errors.As(readErr, &limitErr))
+			assert.Equal(t, tt.wantReceived, string(received))
+			if tt.wantErrorText != "" {
+				assert.EqualError(t, readErr, tt.wantErrorText)
+			}
+		})
+	}
+}
+
+func TestLimitDecompressed(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		compressed int
+		inflated   int
+		wantErr    string
+	}{
+		{
+			name:       "within limits",
+			config:     Config{MaxBytes: 1 << 20, MaxRatio: 10},
+			compressed: 100 << 10,
+			inflated:   200 << 10,
+		},
+		{
+			name:       "size limit",
+			config:     Config{MaxBytes: 1 << 20},
+			compressed: 100,
+			inflated:   2 << 20,
+			wantErr:    "request body larger than 1048576 bytes",
+		},
+		{
+			name:       "ratio limit",
+			config:     Config{MaxBytes: 1 << 20, MaxRatio: 10},
+			compressed: 1 << 10,
+			inflated:   512 << 10,
+			wantErr:    "request body decompresses to more than 10 times its size",
+		},
+		{
+			name:       "small bodies exempt from the ratio",
+			config:     Config{MaxBytes: 1 << 20, MaxRatio: 10},
+			compressed: 100,
+			inflated:   60 << 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inflated int64
+			handler := New(tt.config).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Stands for a decompressor: reads the whole compressed body, then inflates it
+				_, err := io.Copy(io.Discard, r.Body)
+				require.NoError(t, err)
+				decompressed := LimitDecompressed(r, io.NopCloser(bytes.NewReader(make([]byte, tt.inflated))))
+				inflated, err = io.Copy(io.Discard, decompressed)
+				if tt.wantErr == "" {
+					assert.NoError(t, err)
+				} else {
+					assert.EqualError(t, err, tt.wantErr)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(make([]byte, tt.compressed)))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.LessOrEqual(t, inflated, tt.config.MaxBytes)
+		})
+	}
+}
+
+func TestCheckContentLength(t *testing.T) {
+	var err error
+	handler := New(Config{MaxBytes: 10}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err = CheckContentLength(r)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789")))
+	assert.NoError(t, err)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789a")))
+	assert.Error(t, err)
+}
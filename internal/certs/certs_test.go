@@ -0,0 +1,135 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls", "cert.pem")
+	keyPath := filepath.Join(dir, "tls", "key.pem")
+
+	generated, err := EnsureSelfSigned(certPath, keyPath, "shortener.local", "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, generated)
+
+	cert, err := LoadKeyPair(certPath, keyPath)
+	require.NoError(t, err)
+	assert.NoError(t, cert.Leaf.VerifyHostname("localhost"))
+	assert.NoError(t, cert.Leaf.VerifyHostname("shortener.local"))
+	assert.NoError(t, cert.Leaf.VerifyHostname("10.0.0.1"))
+
+	// Existing files are never overwritten
+	generated, err = EnsureSelfSigned(certPath, keyPath)
+	require.NoError(t, err)
+	assert.False(t, generated)
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	_, err := EnsureSelfSigned(certPath, keyPath)
+	require.NoError(t, err)
+
+	reloader, err := NewReloader(certPath, keyPath)
+	require.NoError(t, err)
+
+	first, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	// Replace the files with a new certificate with a later modification time
+	certPEM, keyPEM, err := GenerateSelfSigned("renewed.local")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyPath, keyPEM, 0600))
+	require.NoError(t, os.WriteFile(certPath, certPEM, 0644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(certPath, later, later))
+
+	assert.Eventually(t, func() bool {
+		current, _ := reloader.GetCertificate(&tls.ClientHelloInfo{})
+		return current != first && current.Leaf.VerifyHostname("renewed.local") == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestReloaderKeepsCertificateOnInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	_, err := EnsureSelfSigned(certPath, keyPath)
+	require.NoError(t, err)
+
+	reloader, err := NewReloader(certPath, keyPath)
+	require.NoError(t, err)
+	first, _ := reloader.GetCertificate(&tls.ClientHelloInfo{})
+
+	require.NoError(t, os.WriteFile(certPath, []byte("garbage"), 0644))
+	assert.Error(t, reloader.Reload())
+
+	current, _ := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	assert.Same(t, first, current)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		host      string
+		target    string
+		want      string
+	}{
+		{
+			name:      "custom port",
+			httpsAddr: "localhost:8443",
+			host:      "localhost:8080",
+			target:    "/abc123?x=1",
+			want:      "https://localhost:8443/abc123?x=1",
+		},
+		{
+			name:      "default port",
+			httpsAddr: ":443",
+			host:      "short.example.com",
+			target:    "/api/user/urls",
+			want:      "https://short.example.com/api/user/urls",
+		},
+		{
+			name:      "ipv6 host",
+			httpsAddr: ":443",
+			host:      "[::1]:80",
+			target:    "/",
+			want:      "https://[::1]/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+
+			RedirectHandler(tt.httpsAddr)(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+			assert.Equal(t, tt.want, resp.Header.Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package compress
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/pcristin/urlshortener/internal/metrics"
+)
+
+// MinSize is the size in bytes below which responses are sent uncompressed,
+// the overhead of compression outweighing the savings
+const MinSize = 256
+
+// compressibleTypes are the media type prefixes of the responses worth compressing
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/x-ndjson",
+	"image/svg+xml",
+}
+
+var (
+	uncompressedBytes = metrics.NewCounterVec(
+		"shortener_compression_uncompressed_bytes_total",
+		"Number of response bytes written by handlers before compression, by encoding.",
+		"encoding",
+	)
+	compressedBytes = metrics.NewCounterVec(
+		"shortener_compression_compressed_bytes_total",
+		"Number of response bytes sent after compression, by encoding.",
+		"encoding",
+	)
+	compressionRatio = metrics.NewHistogramVec(
+		"shortener_compression_ratio",
+		"Ratio of compressed to uncompressed size of compressed responses, by encoding.",
+		[]float64{.1, .2, .3, .4, .5, .6, .7, .8, .9, 1, 1.5},
+		"encoding",
+	)
+)
+
+// compressible reports whether a response of the content type is worth compressing
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyless reports whether a response of the status has no body
+func bodyless(statusCode int) bool {
+	return statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w     io.Writer
+	count int
+}
+
+// Write writes to the underlying writer and counts the bytes written
+func (cw *countingWriter) Write(data []byte) (int, error) {
+	n, err := cw.w.Write(data)
+	cw.count += n
+	return n, err
+}
+
+// responseWriter compresses the response with the negotiated encoding. The status and the first
+// bytes of the body are held back until MinSize bytes are written, the handler flushes or the
+// response ends, so the decision to compress is based on the response Content-Type and size.
+type responseWriter struct {
+	http.ResponseWriter
+	encoder *encoder
+
+	status  int
+	buf     []byte
+	decided bool
+
+	// writer is the compressing writer, nil if the response is sent uncompressed
+	writer     Writer
+	compressed *countingWriter
+	// uncompressed counts the bytes written by the handler
+	uncompressed int
+}
+
+// WriteHeader holds back the status until the encoding is decided, bodyless responses
+// are sent at once
+func (rw *responseWriter) WriteHeader(statusCode int) {
+	if rw.decided || rw.status != 0 {
+		return
+	}
+	rw.status = statusCode
+	if bodyless(statusCode) {
+		rw.decide()
+	}
+}
+
+// Write buffers the body until the encoding is decided, then writes through the compressing writer
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if !rw.decided {
+		if rw.Header().Get("Content-Type") == "" {
+			rw.Header().Set("Content-Type", http.DetectContentType(append(rw.buf, data...)))
+		}
+		rw.buf = append(rw.buf, data...)
+		if len(rw.buf) < MinSize {
+			return len(data), nil
+		}
+		if err := rw.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if rw.writer == nil {
+		return rw.ResponseWriter.Write(data)
+	}
+	n, err := rw.writer.Write(data)
+	rw.uncompressed += n
+	return n, err
+}
+
+// decide chooses whether the response is compressed, sends the status and the buffered body
+func (rw *responseWriter) decide() error {
+	rw.decided = true
+	header := rw.Header()
+	if !bodyless(rw.status) && len(rw.buf) >= MinSize && compressible(header.Get("Content-Type")) &&
+		header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" {
+		header.Set("Content-Encoding", rw.encoder.Name)
+		header.Del("Content-Length")
+		rw.compressed = &countingWriter{w: rw.ResponseWriter}
+		rw.writer = rw.encoder.getWriter(rw.compressed)
+	}
+
+	if rw.status != 0 {
+		rw.ResponseWriter.WriteHeader(rw.status)
+	}
+	buf := rw.buf
+	rw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if rw.writer == nil {
+		_, err := rw.ResponseWriter.Write(buf)
+		return err
+	}
+	n, err := rw.writer.Write(buf)
+	rw.uncompressed += n
+	return err
+}
+
+// Flush decides the encoding if needed and flushes the compressed data to the client
+func (rw *responseWriter) Flush() {
+	if !rw.decided {
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		rw.decide()
+	}
+	if rw.writer != nil {
+		rw.writer.Flush()
+	}
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Close sends what is still held back, closes the compressing writer and records the compression metrics
+func (rw *responseWriter) Close() error {
+	if !rw.decided && rw.status != 0 {
+		if err := rw.decide(); err != nil {
+			return err
+		}
+	}
+	if rw.writer == nil {
+		return nil
+	}
+
+	err := rw.writer.Close()
+	rw.encoder.putWriter(rw.writer)
+	rw.writer = nil
+	if rw.uncompressed > 0 {
+		uncompressedBytes.Add(float64(rw.uncompressed), rw.encoder.Name)
+		compressedBytes.Add(float64(rw.compressed.count), rw.encoder.Name)
+		compressionRatio.Observe(float64(rw.compressed.count)/float64(rw.uncompressed), rw.encoder.Name)
+	}
+	return err
+}
+
+// decodeBody replaces the request body by its decompressed content, undoing the codings of its
+// Content-Encoding header in reverse order. It returns false if a coding isn't registered.
+func decodeBody(r *http.Request) (bool, error) {
+	var codings []string
+	for _, value := range r.Header.Values("Content-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			if coding = strings.TrimSpace(coding); coding != "" && !strings.EqualFold(coding, Identity) {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	if len(codings) == 0 {
+		r.Header.Del("Content-Encoding")
+		return true, nil
+	}
+
+	body := r.Body
+	for i := len(codings) - 1; i >= 0; i-- {
+		e, ok := lookup(codings[i])
+		if !ok && strings.EqualFold(codings[i], "x-gzip") {
+			e, ok = lookup(Gzip)
+		}
+		if !ok {
+			return false, nil
+		}
+		reader, err := e.NewReader(body)
+		if err != nil {
+			return true, err
+		}
+		body = readCloser{Reader: reader, closers: []io.Closer{reader, body}}
+	}
+
+	r.Body = bodylimit.LimitDecompressed(r, body)
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return true, nil
+}
+
+// readCloser reads decompressed content, closing the decompressing reader and the compressed body
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the readers, returning the first error
+func (rc readCloser) Close() error {
+	var firstErr error
+	for _, c := range rc.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Middleware compresses responses with the encoding negotiated from the Accept-Encoding header,
+// if their Content-Type is compressible and they are at least MinSize bytes long, and decompresses
+// request bodies sent with a registered Content-Encoding, within the limits of bodylimit.LimitDecompressed.
+// Requests with an unknown coding are answered with 415 Unsupported Media Type, malformed compressed
+// bodies with 400 Bad Request.
+func Middleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			supported, err := decodeBody(r)
+			if !supported {
+				w.Header().Set("Accept-Encoding", strings.Join(Encodings(), ", "))
+				logger.HTTPError(w, r, "unsupported content encoding", http.StatusUnsupportedMediaType)
+				return
+			}
+			var limitErr *bodylimit.Error
+			switch {
+			case errors.As(err, &limitErr):
+				logger.HTTPError(w, r, limitErr.Error(), http.StatusRequestEntityTooLarge)
+				return
+			case err != nil:
+				logger.HTTPError(w, r, "bad request: invalid compressed body", http.StatusBadRequest)
+				return
+			}
+			defer r.Body.Close()
+		}
+
+		// The response depends on Accept-Encoding even when it is sent uncompressed
+		w.Header().Add("Vary", "Accept-Encoding")
+		e, ok := negotiate(r.Header.Get("Accept-Encoding"))
+		if !ok || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &responseWriter{ResponseWriter: w, encoder: e}
+		defer func() {
+			if err := rw.Close(); err != nil {
+				logger.FromContext(r.Context()).Sugar().Errorw("Failed to compress response", "encoding", e.Name, "error", err)
+			}
+		}()
+		h.ServeHTTP(rw, r)
+	}
+}
@@ -0,0 +1,317 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// largeJSON is a compressible response above MinSize
+var largeJSON = `[` + strings.Repeat(`{"short_url":"http://localhost:8080/abc123"},`, 20) + `{}]`
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "none", acceptEncoding: "", want: Identity},
+		{name: "gzip", acceptEncoding: "gzip", want: Gzip},
+		{name: "unknown only", acceptEncoding: "compress, sdch", want: Identity},
+		{name: "brotli", acceptEncoding: "gzip;q=0.8, br", want: Brotli},
+		{name: "zstd", acceptEncoding: "zstd", want: Zstd},
+		{name: "browser", acceptEncoding: "gzip, deflate, br, zstd", want: Zstd},
+		{name: "quality order", acceptEncoding: "gzip;q=0.5, deflate;q=0.8", want: Deflate},
+		{name: "tie goes to preference", acceptEncoding: "deflate, gzip", want: Gzip},
+		{name: "refused", acceptEncoding: "gzip;q=0", want: Identity},
+		{name: "wildcard", acceptEncoding: "*", want: Zstd},
+		{name: "wildcard minus zstd", acceptEncoding: "zstd;q=0, *;q=0.3", want: Brotli},
+		{name: "case and spaces", acceptEncoding: " GZIP ; Q=0.9 ", want: Gzip},
+		{name: "malformed quality ignored", acceptEncoding: "gzip;q=high, deflate;q=0.1", want: Deflate},
+		{name: "legacy alias", acceptEncoding: "x-gzip", want: Gzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Negotiate(tt.acceptEncoding))
+		})
+	}
+}
+
+func TestMiddlewareResponses(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+		wantEncoding   string
+	}{
+		{
+			name:           "gzip",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           largeJSON,
+			wantEncoding:   Gzip,
+		},
+		{
+			name:           "deflate",
+			acceptEncoding: "deflate",
+			contentType:    "application/json",
+			status:         http.StatusCreated,
+			body:           largeJSON,
+			wantEncoding:   Deflate,
+		},
+		{
+			name:           "brotli",
+			acceptEncoding: "br",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           largeJSON,
+			wantEncoding:   Brotli,
+		},
+		{
+			name:           "zstd",
+			acceptEncoding: "zstd",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           largeJSON,
+			wantEncoding:   Zstd,
+		},
+		{
+			name:           "error status",
+			acceptEncoding: "gzip",
+			contentType:    "application/problem+json",
+			status:         http.StatusConflict,
+			body:           largeJSON,
+			wantEncoding:   Gzip,
+		},
+		{
+			name:           "not accepted",
+			acceptEncoding: "",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           largeJSON,
+		},
+		{
+			name:           "below minimal size",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           `{"result":"http://localhost:8080/abc123"}`,
+		},
+		{
+			name:           "incompressible type",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			status:         http.StatusOK,
+			body:           largeJSON,
+		},
+		{
+			name:           "sniffed type",
+			acceptEncoding: "gzip",
+			status:         http.StatusOK,
+			body:           strings.Repeat("plain text ", 50),
+			wantEncoding:   Gzip,
+		},
+		{
+			name:           "no body",
+			acceptEncoding: "gzip",
+			status:         http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				// Written in small chunks, the encoding is decided once enough is buffered
+				for chunk := range slicesOf(tt.body, 100) {
+					w.Write([]byte(chunk))
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.wantEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			assert.Equal(t, tt.body, decode(t, tt.wantEncoding, rec.Body.Bytes()))
+		})
+	}
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(largeJSON))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("\n"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, Gzip, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeJSON+"\n", decode(t, Gzip, rec.Body.Bytes()))
+}
+
+func TestMiddlewareRequests(t *testing.T) {
+	const body = `{"url":"https://practicum.yandex.ru"}`
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		wantStatus      int
+	}{
+		{name: "plain", body: []byte(body), wantStatus: http.StatusOK},
+		{name: "gzip", contentEncoding: "gzip", body: encode(t, Gzip, body), wantStatus: http.StatusOK},
+		{name: "deflate", contentEncoding: "Deflate", body: encode(t, Deflate, body), wantStatus: http.StatusOK},
+		{name: "brotli", contentEncoding: "br", body: encode(t, Brotli, body), wantStatus: http.StatusOK},
+		{name: "zstd", contentEncoding: "zstd", body: encode(t, Zstd, body), wantStatus: http.StatusOK},
+		{name: "zstd over gzip", contentEncoding: "gzip, zstd", body: encode(t, Zstd, string(encode(t, Gzip, body))), wantStatus: http.StatusOK},
+		{name: "identity", contentEncoding: "identity", body: []byte(body), wantStatus: http.StatusOK},
+		{name: "unknown coding", contentEncoding: "compress", body: []byte(body), wantStatus: http.StatusUnsupportedMediaType},
+		{name: "malformed gzip", contentEncoding: "gzip", body: []byte(body), wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+				received, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, body, string(received))
+				assert.Empty(t, r.Header.Get("Content-Encoding"))
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.contentEncoding)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				assert.Equal(t, "zstd, br, gzip, deflate", rec.Header().Get("Accept-Encoding"))
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	// A custom encoding registered later is preferred on equal quality
+	Register(Encoding{
+		Name:      "x-test",
+		NewWriter: func(w io.Writer) Writer { return gzip.NewWriter(w) },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	})
+	defer unregister("x-test")
+
+	assert.Equal(t, []string{"x-test", Zstd, Brotli, Gzip, Deflate}, Encodings())
+	assert.Equal(t, "x-test", Negotiate("zstd, x-test"))
+	assert.Equal(t, Zstd, Negotiate("zstd, x-test;q=0.5"))
+}
+
+func TestCompressionMetrics(t *testing.T) {
+	uncompressed := uncompressedBytes.Value(Gzip)
+	compressed := compressedBytes.Value(Gzip)
+	responses := compressionRatio.Count(Gzip)
+
+	handler := Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(largeJSON))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	assert.Equal(t, uncompressed+float64(len(largeJSON)), uncompressedBytes.Value(Gzip))
+	assert.Equal(t, compressed+float64(rec.Body.Len()), compressedBytes.Value(Gzip))
+	assert.Equal(t, responses+1, compressionRatio.Count(Gzip))
+	assert.Less(t, rec.Body.Len(), len(largeJSON))
+}
+
+// unregister removes an encoding registered by a test
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	var encodings []*encoder
+	for _, e := range registry {
+		if e.Name != name {
+			encodings = append(encodings, e)
+		}
+	}
+	registry = encodings
+}
+
+// slicesOf yields the string in chunks of at most size bytes
+func slicesOf(s string, size int) func(func(string) bool) {
+	return func(yield func(string) bool) {
+		for len(s) > 0 {
+			n := min(size, len(s))
+			if !yield(s[:n]) {
+				return
+			}
+			s = s[n:]
+		}
+	}
+}
+
+// encode compresses the content with the encoding
+func encode(t *testing.T, encoding, content string) []byte {
+	t.Helper()
+	e, ok := lookup(encoding)
+	require.True(t, ok)
+
+	var buf bytes.Buffer
+	zw := e.NewWriter(&buf)
+	_, err := zw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// decode decompresses a response body sent with the encoding, empty for an uncompressed body
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	var reader io.Reader = bytes.NewReader(body)
+	var err error
+	switch encoding {
+	case Gzip:
+		reader, err = gzip.NewReader(reader)
+	case Deflate:
+		reader, err = zlib.NewReader(reader)
+	case Brotli:
+		reader = brotli.NewReader(reader)
+	case Zstd:
+		reader, err = zstd.NewReader(reader)
+	}
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
@@ -0,0 +1,164 @@
+// Package compress negotiates the content coding of HTTP responses and decodes compressed request bodies.
+//
+// Content codings are provided by a registry: zstd, brotli ("br"), gzip and deflate are built in,
+// preferred in this order, and other encoders are plugged in with Register.
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Names of the content codings
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+	Brotli  = "br"
+	Zstd    = "zstd"
+	// Identity is the coding of uncompressed content
+	Identity = "identity"
+)
+
+// Writer is a compressing writer, reused across responses through Reset
+type Writer interface {
+	io.WriteCloser
+	// Flush writes the pending compressed data to the underlying writer
+	Flush() error
+	// Reset discards the state of the writer and makes it write to w
+	Reset(w io.Writer)
+}
+
+// Encoding is a content coding, as named in the Accept-Encoding and Content-Encoding headers
+type Encoding struct {
+	// Name is the content coding token, such as "gzip"
+	Name string
+	// NewWriter returns a writer compressing to w
+	NewWriter func(w io.Writer) Writer
+	// NewReader returns a reader decompressing r
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// encoder is a registered encoding, with the pool of its writers
+type encoder struct {
+	Encoding
+	writers sync.Pool
+}
+
+// getWriter returns a pooled writer compressing to w
+func (e *encoder) getWriter(w io.Writer) Writer {
+	if zw, ok := e.writers.Get().(Writer); ok {
+		zw.Reset(w)
+		return zw
+	}
+	return e.NewWriter(w)
+}
+
+// putWriter returns a closed writer to the pool
+func (e *encoder) putWriter(zw Writer) {
+	zw.Reset(io.Discard)
+	e.writers.Put(zw)
+}
+
+var (
+	registryMu sync.RWMutex
+	// registry holds the encodings from the most preferred one
+	registry []*encoder
+)
+
+func init() {
+	Register(Encoding{
+		Name:      Deflate,
+		NewWriter: func(w io.Writer) Writer { return zlib.NewWriter(w) },
+		NewReader: zlib.NewReader,
+	})
+	Register(Encoding{
+		Name:      Gzip,
+		NewWriter: func(w io.Writer) Writer { return gzip.NewWriter(w) },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	})
+	Register(Encoding{
+		Name:      Brotli,
+		NewWriter: func(w io.Writer) Writer { return brotli.NewWriter(w) },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+	})
+	Register(Encoding{
+		Name:      Zstd,
+		NewWriter: newZstdWriter,
+		NewReader: newZstdReader,
+	})
+}
+
+// zstdMaxWindow bounds the memory a zstd request body can make the decoder allocate
+const zstdMaxWindow = 8 << 20
+
+// newZstdWriter returns a zstd writer compressing on the goroutine of the response.
+// The default concurrency and window are meant for large files, not for responses of a few kilobytes.
+func newZstdWriter(w io.Writer) Writer {
+	// Options are constant and valid, so creating the encoder can't fail
+	zw, _ := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(1<<20))
+	return zw
+}
+
+// newZstdReader returns a reader decompressing a zstd stream on the goroutine of the request
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+
+// Register adds an encoding, replacing the one of the same name. Encodings registered later
+// are preferred when the client accepts several with the same quality.
+func Register(e Encoding) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	e.Name = strings.ToLower(e.Name)
+	encodings := []*encoder{{Encoding: e}}
+	for _, registered := range registry {
+		if registered.Name != e.Name {
+			encodings = append(encodings, registered)
+		}
+	}
+	registry = encodings
+}
+
+// Encodings returns the names of the registered encodings, from the most preferred one
+func Encodings() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, len(registry))
+	for i, e := range registry {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// lookup returns the registered encoding of the name
+func lookup(name string) (*encoder, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	name = strings.ToLower(name)
+	for _, e := range registry {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// encoders returns the registered encodings, from the most preferred one
+func encoders() []*encoder {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry
+}
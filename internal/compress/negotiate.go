@@ -0,0 +1,74 @@
+package compress
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseAcceptEncoding returns the quality of each coding of an Accept-Encoding header,
+// by lowercase name. Codings with a malformed quality are ignored.
+func parseAcceptEncoding(header string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		quality := 1.0
+		valid := true
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(param, "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			quality = q
+		}
+		if valid {
+			qualities[name] = quality
+		}
+	}
+	return qualities
+}
+
+// Negotiate returns the name of the registered encoding the response should use for an
+// Accept-Encoding header, or Identity if the client accepts none of them.
+// The encoding of highest quality wins, ties going to the most preferred encoding;
+// "*" stands for the encodings the header doesn't name.
+func Negotiate(acceptEncoding string) string {
+	if e, ok := negotiate(acceptEncoding); ok {
+		return e.Name
+	}
+	return Identity
+}
+
+// negotiate returns the registered encoding of highest quality accepted by the client
+func negotiate(acceptEncoding string) (*encoder, bool) {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return nil, false
+	}
+	qualities := parseAcceptEncoding(acceptEncoding)
+	wildcard, hasWildcard := qualities["*"]
+
+	var best *encoder
+	bestQuality := 0.0
+	for _, e := range encoders() {
+		quality, ok := qualities[e.Name]
+		if !ok && e.Name == Gzip {
+			quality, ok = qualities["x-gzip"]
+		}
+		if !ok && hasWildcard {
+			quality, ok = wildcard, true
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = e, quality
+		}
+	}
+	return best, best != nil
+}
@@ -1,8 +1,28 @@
+// Package config loads the settings of the URL shortener service.
+//
+// Every option can be set, from highest to lowest precedence, by a command line flag,
+// an environment variable, a JSON or YAML configuration file given by -c or CONFIG,
+// or left to its default value.
+//
+// The log level and base URL can be changed while the server runs: Reload re-reads
+// all sources and pushes the new values to the subscribers. Other options need a restart.
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/accesslog"
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	"go.uber.org/zap/zapcore"
 )
 
 // Options holds configuration settings for the URL shortener service
@@ -11,7 +31,59 @@ type Options struct {
 	baseURL         string
 	pathToSavedData string
 	databaseDSN     string
+	storageURI      string
 	secret          string
+	oidcIssuer      string
+	oidcClientID    string
+	oidcSecret      string
+	oidcRedirectURL string
+	adminToken      string
+	trustedSubnet   string
+	enableHTTPS     bool
+	tlsCertFile     string
+	tlsKeyFile      string
+	httpRedirect    string
+	logLevel        string
+	logHashPII      bool
+	rpcAddress      string
+	metricsAddress  string
+	devMode         bool
+	importMaxRows   int
+
+	maxBodyBytes          int
+	bodyLimitRoutes       string
+	maxDecompressionRatio int
+	maxBatchSize          int
+	maxURLLength          int
+
+	deleteQueueSize   int
+	deleteWorkers     int
+	deleteFlushMillis int
+	deleteJournalPath string
+
+	accessLogFormat       string
+	accessLogOutput       string
+	accessLogMaxSizeMB    int
+	accessLogRotateHours  int
+	accessLogMaxBackups   int
+	accessLogMaxAgeDays   int
+	accessLogSampleRate   int
+	accessLogSampleRoutes string
+
+	configFile  string
+	printConfig bool
+
+	// args are the command line arguments, parsed again on reload
+	args []string
+
+	// mu guards the options that can be reloaded at runtime
+	mu sync.RWMutex
+	// reloadMu serializes reloads and guards the subscribers
+	reloadMu    sync.Mutex
+	subscribers []func(Dynamic)
+
+	// envErrs collects malformed environment variables, reported by Validate
+	envErrs []error
 }
 
 // NewOptions creates a new Options instance
@@ -22,19 +94,116 @@ func NewOptions() *Options {
 		pathToSavedData: "saved_data.json",
 		databaseDSN:     "",
 		secret:          "",
+		tlsCertFile:     "cert.pem",
+		tlsKeyFile:      "key.pem",
+		logLevel:        "info",
+		importMaxRows:   100000,
+
+		maxBodyBytes:          1 << 20,
+		bodyLimitRoutes:       "/api/shorten/import=268435456",
+		maxDecompressionRatio: 100,
+		maxBatchSize:          1000,
+		maxURLLength:          8192,
+
+		deleteQueueSize:   1000,
+		deleteWorkers:     1,
+		deleteFlushMillis: 1000,
+
+		accessLogFormat:       "json",
+		accessLogOutput:       "stdout",
+		accessLogMaxSizeMB:    100,
+		accessLogRotateHours:  24,
+		accessLogMaxBackups:   7,
+		accessLogSampleRate:   1,
+		accessLogSampleRoutes: "/{id}",
 	}
 }
 
-// ParseFlags parses command line flags and environment variables
-func (o *Options) ParseFlags() {
-	flag.StringVar(&o.serverURL, "a", o.serverURL, "address and port to run server")
-	flag.StringVar(&o.baseURL, "b", o.baseURL, "server url and short url path to redirect")
-	flag.StringVar(&o.pathToSavedData, "f", o.pathToSavedData, "path to json file with saved data")
-	flag.StringVar(&o.databaseDSN, "d", o.databaseDSN, "string of db connection params")
+// ParseFlags parses command line flags, environment variables and the configuration file,
+// applying them in order of precedence: flags, environment variables, configuration file, defaults
+func (o *Options) ParseFlags() error {
+	return o.parse(flag.CommandLine, os.Args[1:])
+}
+
+// parse loads the configuration from the given flag set and arguments
+func (o *Options) parse(fs *flag.FlagSet, args []string) error {
+	o.args = args
 
-	flag.Parse()
+	fs.StringVar(&o.configFile, "c", o.configFile, "path to JSON or YAML configuration file")
+	fs.StringVar(&o.configFile, "config", o.configFile, "path to JSON or YAML configuration file")
+	fs.BoolVar(&o.printConfig, "print-config", o.printConfig, "print the effective configuration with secrets redacted and exit")
+	fs.StringVar(&o.serverURL, "a", o.serverURL, "address and port to run server")
+	fs.StringVar(&o.baseURL, "b", o.baseURL, "server url and short url path to redirect")
+	fs.StringVar(&o.pathToSavedData, "f", o.pathToSavedData, "path to json file with saved data")
+	fs.StringVar(&o.databaseDSN, "d", o.databaseDSN, "string of db connection params")
+	fs.StringVar(&o.storageURI, "storage-uri", o.storageURI, "storage URI such as memory://, file:///path/to/data.json or postgres://..., overrides -d and -f")
+	fs.StringVar(&o.secret, "secret", o.secret, "secret key signing the user identity cookies")
+	fs.StringVar(&o.oidcIssuer, "oidc-issuer", o.oidcIssuer, "OpenID Connect issuer URL, enables SSO login")
+	fs.StringVar(&o.oidcClientID, "oidc-client-id", o.oidcClientID, "OpenID Connect client ID")
+	fs.StringVar(&o.oidcSecret, "oidc-client-secret", o.oidcSecret, "OpenID Connect client secret")
+	fs.StringVar(&o.oidcRedirectURL, "oidc-redirect-url", o.oidcRedirectURL, "OpenID Connect callback URL")
+	fs.StringVar(&o.adminToken, "admin-token", o.adminToken, "bearer token for the admin API, disabled if empty")
+	fs.StringVar(&o.trustedSubnet, "t", o.trustedSubnet, "trusted subnet in CIDR notation allowed to read internal statistics")
+	fs.BoolVar(&o.enableHTTPS, "s", o.enableHTTPS, "serve HTTPS, generating a self-signed certificate if none exists")
+	fs.StringVar(&o.tlsCertFile, "tls-cert", o.tlsCertFile, "path to TLS certificate file in PEM format")
+	fs.StringVar(&o.tlsKeyFile, "tls-key", o.tlsKeyFile, "path to TLS private key file in PEM format")
+	fs.StringVar(&o.httpRedirect, "http-redirect", o.httpRedirect, "address of plain HTTP listener redirecting to HTTPS, disabled if empty")
+	fs.StringVar(&o.rpcAddress, "rpc-address", o.rpcAddress, "address of the JSON-RPC listener for internal services, disabled if empty")
+	fs.StringVar(&o.metricsAddress, "metrics-address", o.metricsAddress, "address of the admin listener serving Prometheus metrics at /metrics, disabled if empty")
+	fs.BoolVar(&o.devMode, "dev", o.devMode, "development mode: validate requests and responses against the OpenAPI specification")
+	fs.IntVar(&o.importMaxRows, "import-max-rows", o.importMaxRows, "maximal number of rows of a bulk import")
+	fs.IntVar(&o.maxBodyBytes, "max-body-bytes", o.maxBodyBytes, "maximal size in bytes of request bodies, before and after decompression")
+	fs.StringVar(&o.bodyLimitRoutes, "body-limit-routes", o.bodyLimitRoutes, "comma separated route=bytes pairs overriding the maximal body size of routes")
+	fs.IntVar(&o.maxDecompressionRatio, "max-decompression-ratio", o.maxDecompressionRatio, "maximal ratio of decompressed to compressed request body size, unlimited if 0")
+	fs.IntVar(&o.maxBatchSize, "max-batch-size", o.maxBatchSize, "maximal number of items of a batch request")
+	fs.IntVar(&o.maxURLLength, "max-url-length", o.maxURLLength, "maximal length of shortened URLs")
+	fs.IntVar(&o.deleteQueueSize, "delete-queue-size", o.deleteQueueSize, "number of deletion requests queued before new ones are rejected")
+	fs.IntVar(&o.deleteWorkers, "delete-workers", o.deleteWorkers, "number of workers writing queued deletions")
+	fs.IntVar(&o.deleteFlushMillis, "delete-flush-interval-ms", o.deleteFlushMillis, "interval in milliseconds at which queued deletions are written")
+	fs.StringVar(&o.deleteJournalPath, "delete-journal", o.deleteJournalPath, "path to the journal persisting queued deletions across restarts, disabled if empty")
+	fs.StringVar(&o.accessLogFormat, "access-log-format", o.accessLogFormat, "format of the access log: json, combined or logfmt")
+	fs.StringVar(&o.accessLogOutput, "access-log-output", o.accessLogOutput, "stdout or path of the access log file")
+	fs.IntVar(&o.accessLogMaxSizeMB, "access-log-max-size-mb", o.accessLogMaxSizeMB, "size in megabytes above which the access log file is rotated, never if 0")
+	fs.IntVar(&o.accessLogRotateHours, "access-log-rotate-hours", o.accessLogRotateHours, "age in hours after which the access log file is rotated, never if 0")
+	fs.IntVar(&o.accessLogMaxBackups, "access-log-max-backups", o.accessLogMaxBackups, "number of rotated access log files kept, all if 0")
+	fs.IntVar(&o.accessLogMaxAgeDays, "access-log-max-age-days", o.accessLogMaxAgeDays, "age in days after which rotated access log files are removed, never if 0")
+	fs.IntVar(&o.accessLogSampleRate, "access-log-sample-rate", o.accessLogSampleRate, "log one in this many successful requests of the sampled routes")
+	fs.StringVar(&o.accessLogSampleRoutes, "access-log-sample-routes", o.accessLogSampleRoutes, "comma separated route patterns whose access log is sampled")
+	fs.StringVar(&o.logLevel, "log-level", o.logLevel, "minimal level of logged messages: debug, info, warn or error")
+	fs.BoolVar(&o.logHashPII, "log-hash-pii", o.logHashPII, "replace user IDs and URLs by a hash in the logs")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Remember the flags given explicitly, they are applied again over the file and environment
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if _, ok := explicit["c"]; !ok {
+		if _, ok := explicit["config"]; !ok {
+			if valueConfig, foundConfig := os.LookupEnv("CONFIG"); foundConfig && valueConfig != "" {
+				o.configFile = valueConfig
+			}
+		}
+	}
+
+	if o.configFile != "" {
+		if err := o.LoadFile(o.configFile); err != nil {
+			return err
+		}
+	}
 
 	o.LoadEnvVariables()
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // LoadEnvVariables loads configuration from environment variables
@@ -55,9 +224,369 @@ func (o *Options) LoadEnvVariables() {
 		o.databaseDSN = os.Getenv("DATABASE_DSN")
 	}
 
+	if valueStorageURI, foundStorageURI := os.LookupEnv("STORAGE_URI"); foundStorageURI && valueStorageURI != "" {
+		o.storageURI = valueStorageURI
+	}
+
 	if valueSecret, foundSecret := os.LookupEnv("SECRET_URL_SERVICE"); foundSecret && valueSecret != "" {
 		o.secret = os.Getenv("SECRET_URL_SERVICE")
 	}
+
+	if valueOIDCIssuer, foundOIDCIssuer := os.LookupEnv("OIDC_ISSUER"); foundOIDCIssuer && valueOIDCIssuer != "" {
+		o.oidcIssuer = valueOIDCIssuer
+	}
+
+	if valueOIDCClientID, foundOIDCClientID := os.LookupEnv("OIDC_CLIENT_ID"); foundOIDCClientID && valueOIDCClientID != "" {
+		o.oidcClientID = valueOIDCClientID
+	}
+
+	if valueOIDCSecret, foundOIDCSecret := os.LookupEnv("OIDC_CLIENT_SECRET"); foundOIDCSecret && valueOIDCSecret != "" {
+		o.oidcSecret = valueOIDCSecret
+	}
+
+	if valueOIDCRedirectURL, foundOIDCRedirectURL := os.LookupEnv("OIDC_REDIRECT_URL"); foundOIDCRedirectURL && valueOIDCRedirectURL != "" {
+		o.oidcRedirectURL = valueOIDCRedirectURL
+	}
+
+	if valueAdminToken, foundAdminToken := os.LookupEnv("ADMIN_TOKEN"); foundAdminToken && valueAdminToken != "" {
+		o.adminToken = valueAdminToken
+	}
+
+	if valueTrustedSubnet, foundTrustedSubnet := os.LookupEnv("TRUSTED_SUBNET"); foundTrustedSubnet && valueTrustedSubnet != "" {
+		o.trustedSubnet = valueTrustedSubnet
+	}
+
+	if valueEnableHTTPS, foundEnableHTTPS := os.LookupEnv("ENABLE_HTTPS"); foundEnableHTTPS && valueEnableHTTPS != "" {
+		if enableHTTPS, err := strconv.ParseBool(valueEnableHTTPS); err == nil {
+			o.enableHTTPS = enableHTTPS
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("ENABLE_HTTPS: invalid boolean %q", valueEnableHTTPS))
+		}
+	}
+
+	if valueTLSCertFile, foundTLSCertFile := os.LookupEnv("TLS_CERT_FILE"); foundTLSCertFile && valueTLSCertFile != "" {
+		o.tlsCertFile = valueTLSCertFile
+	}
+
+	if valueTLSKeyFile, foundTLSKeyFile := os.LookupEnv("TLS_KEY_FILE"); foundTLSKeyFile && valueTLSKeyFile != "" {
+		o.tlsKeyFile = valueTLSKeyFile
+	}
+
+	if valueHTTPRedirect, foundHTTPRedirect := os.LookupEnv("HTTP_REDIRECT_ADDRESS"); foundHTTPRedirect && valueHTTPRedirect != "" {
+		o.httpRedirect = valueHTTPRedirect
+	}
+
+	if valueRPCAddress, foundRPCAddress := os.LookupEnv("RPC_ADDRESS"); foundRPCAddress && valueRPCAddress != "" {
+		o.rpcAddress = valueRPCAddress
+	}
+
+	if valueMetricsAddress, foundMetricsAddress := os.LookupEnv("METRICS_ADDRESS"); foundMetricsAddress && valueMetricsAddress != "" {
+		o.metricsAddress = valueMetricsAddress
+	}
+
+	if valueLogLevel, foundLogLevel := os.LookupEnv("LOG_LEVEL"); foundLogLevel && valueLogLevel != "" {
+		o.logLevel = valueLogLevel
+	}
+
+	if valueLogHashPII, foundLogHashPII := os.LookupEnv("LOG_HASH_PII"); foundLogHashPII && valueLogHashPII != "" {
+		if logHashPII, err := strconv.ParseBool(valueLogHashPII); err == nil {
+			o.logHashPII = logHashPII
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("LOG_HASH_PII: invalid boolean %q", valueLogHashPII))
+		}
+	}
+
+	if valueDevMode, foundDevMode := os.LookupEnv("DEV_MODE"); foundDevMode && valueDevMode != "" {
+		if devMode, err := strconv.ParseBool(valueDevMode); err == nil {
+			o.devMode = devMode
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("DEV_MODE: invalid boolean %q", valueDevMode))
+		}
+	}
+
+	if valueImportMaxRows, foundImportMaxRows := os.LookupEnv("IMPORT_MAX_ROWS"); foundImportMaxRows && valueImportMaxRows != "" {
+		if importMaxRows, err := strconv.Atoi(valueImportMaxRows); err == nil {
+			o.importMaxRows = importMaxRows
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("IMPORT_MAX_ROWS: invalid integer %q", valueImportMaxRows))
+		}
+	}
+
+	if valueMaxBodyBytes, foundMaxBodyBytes := os.LookupEnv("MAX_BODY_BYTES"); foundMaxBodyBytes && valueMaxBodyBytes != "" {
+		if maxBodyBytes, err := strconv.Atoi(valueMaxBodyBytes); err == nil {
+			o.maxBodyBytes = maxBodyBytes
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("MAX_BODY_BYTES: invalid integer %q", valueMaxBodyBytes))
+		}
+	}
+
+	if valueBodyLimitRoutes, foundBodyLimitRoutes := os.LookupEnv("BODY_LIMIT_ROUTES"); foundBodyLimitRoutes && valueBodyLimitRoutes != "" {
+		o.bodyLimitRoutes = valueBodyLimitRoutes
+	}
+
+	if valueMaxDecompressionRatio, foundMaxDecompressionRatio := os.LookupEnv("MAX_DECOMPRESSION_RATIO"); foundMaxDecompressionRatio && valueMaxDecompressionRatio != "" {
+		if maxDecompressionRatio, err := strconv.Atoi(valueMaxDecompressionRatio); err == nil {
+			o.maxDecompressionRatio = maxDecompressionRatio
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("MAX_DECOMPRESSION_RATIO: invalid integer %q", valueMaxDecompressionRatio))
+		}
+	}
+
+	if valueMaxBatchSize, foundMaxBatchSize := os.LookupEnv("MAX_BATCH_SIZE"); foundMaxBatchSize && valueMaxBatchSize != "" {
+		if maxBatchSize, err := strconv.Atoi(valueMaxBatchSize); err == nil {
+			o.maxBatchSize = maxBatchSize
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("MAX_BATCH_SIZE: invalid integer %q", valueMaxBatchSize))
+		}
+	}
+
+	if valueMaxURLLength, foundMaxURLLength := os.LookupEnv("MAX_URL_LENGTH"); foundMaxURLLength && valueMaxURLLength != "" {
+		if maxURLLength, err := strconv.Atoi(valueMaxURLLength); err == nil {
+			o.maxURLLength = maxURLLength
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("MAX_URL_LENGTH: invalid integer %q", valueMaxURLLength))
+		}
+	}
+
+	if valueDeleteQueueSize, foundDeleteQueueSize := os.LookupEnv("DELETE_QUEUE_SIZE"); foundDeleteQueueSize && valueDeleteQueueSize != "" {
+		if deleteQueueSize, err := strconv.Atoi(valueDeleteQueueSize); err == nil {
+			o.deleteQueueSize = deleteQueueSize
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("DELETE_QUEUE_SIZE: invalid integer %q", valueDeleteQueueSize))
+		}
+	}
+
+	if valueDeleteWorkers, foundDeleteWorkers := os.LookupEnv("DELETE_WORKERS"); foundDeleteWorkers && valueDeleteWorkers != "" {
+		if deleteWorkers, err := strconv.Atoi(valueDeleteWorkers); err == nil {
+			o.deleteWorkers = deleteWorkers
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("DELETE_WORKERS: invalid integer %q", valueDeleteWorkers))
+		}
+	}
+
+	if valueDeleteFlush, foundDeleteFlush := os.LookupEnv("DELETE_FLUSH_INTERVAL_MS"); foundDeleteFlush && valueDeleteFlush != "" {
+		if deleteFlushMillis, err := strconv.Atoi(valueDeleteFlush); err == nil {
+			o.deleteFlushMillis = deleteFlushMillis
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("DELETE_FLUSH_INTERVAL_MS: invalid integer %q", valueDeleteFlush))
+		}
+	}
+
+	if valueDeleteJournal, foundDeleteJournal := os.LookupEnv("DELETE_JOURNAL_PATH"); foundDeleteJournal && valueDeleteJournal != "" {
+		o.deleteJournalPath = valueDeleteJournal
+	}
+
+	if valueAccessLogFormat, foundAccessLogFormat := os.LookupEnv("ACCESS_LOG_FORMAT"); foundAccessLogFormat && valueAccessLogFormat != "" {
+		o.accessLogFormat = valueAccessLogFormat
+	}
+
+	if valueAccessLogOutput, foundAccessLogOutput := os.LookupEnv("ACCESS_LOG_OUTPUT"); foundAccessLogOutput && valueAccessLogOutput != "" {
+		o.accessLogOutput = valueAccessLogOutput
+	}
+
+	if valueAccessLogMaxSizeMB, foundAccessLogMaxSizeMB := os.LookupEnv("ACCESS_LOG_MAX_SIZE_MB"); foundAccessLogMaxSizeMB && valueAccessLogMaxSizeMB != "" {
+		if accessLogMaxSizeMB, err := strconv.Atoi(valueAccessLogMaxSizeMB); err == nil {
+			o.accessLogMaxSizeMB = accessLogMaxSizeMB
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("ACCESS_LOG_MAX_SIZE_MB: invalid integer %q", valueAccessLogMaxSizeMB))
+		}
+	}
+
+	if valueAccessLogRotateHours, foundAccessLogRotateHours := os.LookupEnv("ACCESS_LOG_ROTATE_HOURS"); foundAccessLogRotateHours && valueAccessLogRotateHours != "" {
+		if accessLogRotateHours, err := strconv.Atoi(valueAccessLogRotateHours); err == nil {
+			o.accessLogRotateHours = accessLogRotateHours
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("ACCESS_LOG_ROTATE_HOURS: invalid integer %q", valueAccessLogRotateHours))
+		}
+	}
+
+	if valueAccessLogMaxBackups, foundAccessLogMaxBackups := os.LookupEnv("ACCESS_LOG_MAX_BACKUPS"); foundAccessLogMaxBackups && valueAccessLogMaxBackups != "" {
+		if accessLogMaxBackups, err := strconv.Atoi(valueAccessLogMaxBackups); err == nil {
+			o.accessLogMaxBackups = accessLogMaxBackups
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("ACCESS_LOG_MAX_BACKUPS: invalid integer %q", valueAccessLogMaxBackups))
+		}
+	}
+
+	if valueAccessLogMaxAgeDays, foundAccessLogMaxAgeDays := os.LookupEnv("ACCESS_LOG_MAX_AGE_DAYS"); foundAccessLogMaxAgeDays && valueAccessLogMaxAgeDays != "" {
+		if accessLogMaxAgeDays, err := strconv.Atoi(valueAccessLogMaxAgeDays); err == nil {
+			o.accessLogMaxAgeDays = accessLogMaxAgeDays
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("ACCESS_LOG_MAX_AGE_DAYS: invalid integer %q", valueAccessLogMaxAgeDays))
+		}
+	}
+
+	if valueAccessLogSampleRate, foundAccessLogSampleRate := os.LookupEnv("ACCESS_LOG_SAMPLE_RATE"); foundAccessLogSampleRate && valueAccessLogSampleRate != "" {
+		if accessLogSampleRate, err := strconv.Atoi(valueAccessLogSampleRate); err == nil {
+			o.accessLogSampleRate = accessLogSampleRate
+		} else {
+			o.envErrs = append(o.envErrs, fmt.Errorf("ACCESS_LOG_SAMPLE_RATE: invalid integer %q", valueAccessLogSampleRate))
+		}
+	}
+
+	if valueAccessLogSampleRoutes, foundAccessLogSampleRoutes := os.LookupEnv("ACCESS_LOG_SAMPLE_ROUTES"); foundAccessLogSampleRoutes && valueAccessLogSampleRoutes != "" {
+		o.accessLogSampleRoutes = valueAccessLogSampleRoutes
+	}
+}
+
+// Validate checks the consistency of the configuration, reporting all problems at once
+func (o *Options) Validate() error {
+	errs := append([]error(nil), o.envErrs...)
+
+	if o.serverURL == "" {
+		errs = append(errs, errors.New("server address can not be empty"))
+	} else if _, _, err := net.SplitHostPort(o.serverURL); err != nil {
+		errs = append(errs, fmt.Errorf("server address %q: %w", o.serverURL, err))
+	}
+
+	if o.baseURL != "" {
+		if err := validateHTTPURL(o.baseURL); err != nil {
+			errs = append(errs, fmt.Errorf("base URL: %w", err))
+		}
+	}
+
+	if o.databaseDSN != "" {
+		if _, err := dsnToURI(o.databaseDSN); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if o.storageURI != "" {
+		// The URI may hold a password, which is never repeated
+		if u, err := url.Parse(o.storageURI); err != nil || u.Scheme == "" {
+			errs = append(errs, errors.New("storage URI: expected a URI such as memory://, file:///path/to/data.json or postgres://host/db"))
+		}
+	}
+
+	if o.trustedSubnet != "" {
+		if _, _, err := net.ParseCIDR(o.trustedSubnet); err != nil {
+			errs = append(errs, fmt.Errorf("trusted subnet: %w", err))
+		}
+	}
+
+	if o.oidcIssuer != "" {
+		if err := validateHTTPURL(o.oidcIssuer); err != nil {
+			errs = append(errs, fmt.Errorf("OpenID Connect issuer: %w", err))
+		}
+		if o.oidcClientID == "" {
+			errs = append(errs, errors.New("OpenID Connect client ID is required with an issuer"))
+		}
+		if o.oidcRedirectURL == "" {
+			errs = append(errs, errors.New("OpenID Connect redirect URL is required with an issuer"))
+		} else if err := validateHTTPURL(o.oidcRedirectURL); err != nil {
+			errs = append(errs, fmt.Errorf("OpenID Connect redirect URL: %w", err))
+		}
+	}
+
+	if o.enableHTTPS && (o.tlsCertFile == "" || o.tlsKeyFile == "") {
+		errs = append(errs, errors.New("TLS certificate and key files are required with HTTPS"))
+	}
+
+	if o.httpRedirect != "" {
+		if !o.enableHTTPS {
+			errs = append(errs, errors.New("HTTP redirect address requires HTTPS to be enabled"))
+		} else if _, _, err := net.SplitHostPort(o.httpRedirect); err != nil {
+			errs = append(errs, fmt.Errorf("HTTP redirect address %q: %w", o.httpRedirect, err))
+		}
+	}
+
+	if o.rpcAddress != "" {
+		if _, _, err := net.SplitHostPort(o.rpcAddress); err != nil {
+			errs = append(errs, fmt.Errorf("RPC address %q: %w", o.rpcAddress, err))
+		}
+		if o.secret == "" {
+			errs = append(errs, errors.New("secret is required to authenticate RPC callers"))
+		}
+	}
+
+	if o.metricsAddress != "" {
+		if _, _, err := net.SplitHostPort(o.metricsAddress); err != nil {
+			errs = append(errs, fmt.Errorf("metrics address %q: %w", o.metricsAddress, err))
+		} else if o.metricsAddress == o.serverURL {
+			errs = append(errs, errors.New("metrics address must differ from the server address"))
+		}
+	}
+
+	if _, err := zapcore.ParseLevel(o.logLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log level: %w", err))
+	}
+
+	if o.importMaxRows <= 0 {
+		errs = append(errs, fmt.Errorf("import max rows %d: must be positive", o.importMaxRows))
+	}
+
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"max body bytes", o.maxBodyBytes},
+		{"max batch size", o.maxBatchSize},
+		{"max URL length", o.maxURLLength},
+	} {
+		if limit.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s %d: must be positive", limit.name, limit.value))
+		}
+	}
+	if o.maxDecompressionRatio < 0 {
+		errs = append(errs, fmt.Errorf("max decompression ratio %d: must not be negative", o.maxDecompressionRatio))
+	}
+	if _, err := bodylimit.ParseRoutes(o.bodyLimitRoutes); err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.deleteQueueSize <= 0 {
+		errs = append(errs, fmt.Errorf("delete queue size %d: must be positive", o.deleteQueueSize))
+	}
+	if o.deleteWorkers <= 0 {
+		errs = append(errs, fmt.Errorf("delete workers %d: must be positive", o.deleteWorkers))
+	}
+	if o.deleteFlushMillis <= 0 {
+		errs = append(errs, fmt.Errorf("delete flush interval %dms: must be positive", o.deleteFlushMillis))
+	}
+
+	if _, err := accesslog.ParseFormat(o.accessLogFormat); err != nil {
+		errs = append(errs, err)
+	}
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"access log max size", o.accessLogMaxSizeMB},
+		{"access log rotate hours", o.accessLogRotateHours},
+		{"access log max backups", o.accessLogMaxBackups},
+		{"access log max age days", o.accessLogMaxAgeDays},
+	} {
+		if limit.value < 0 {
+			errs = append(errs, fmt.Errorf("%s %d: must not be negative", limit.name, limit.value))
+		}
+	}
+	if o.accessLogSampleRate <= 0 {
+		errs = append(errs, fmt.Errorf("access log sample rate %d: must be positive", o.accessLogSampleRate))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateHTTPURL checks that a value is an absolute http or https URL
+func validateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http or https URL", value)
+	}
+	return nil
+}
+
+// GetConfigFile returns the path of the configuration file, empty if none is used
+func (o *Options) GetConfigFile() string {
+	return o.configFile
+}
+
+// GetPrintConfig reports whether the effective configuration should be printed instead of running the server
+func (o *Options) GetPrintConfig() bool {
+	return o.printConfig
 }
 
 // GetServerURL returns the server URL
@@ -67,6 +596,8 @@ func (o *Options) GetServerURL() string {
 
 // GetBaseURL returns the base URL
 func (o *Options) GetBaseURL() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
 	return o.baseURL
 }
 
@@ -80,7 +611,234 @@ func (o *Options) GetDatabaseDSN() string {
 	return o.databaseDSN
 }
 
+// GetStorageURI returns the URI of the storage, whose scheme selects the storage driver.
+// Without a storage URI it is derived from the database DSN, then from the path to the JSON file,
+// falling back to memory storage.
+func (o *Options) GetStorageURI() string {
+	switch {
+	case o.storageURI != "":
+		return o.storageURI
+	case o.databaseDSN != "":
+		uri, _ := dsnToURI(o.databaseDSN)
+		return uri
+	case strings.HasPrefix(o.pathToSavedData, "/"):
+		return (&url.URL{Scheme: "file", Path: o.pathToSavedData}).String()
+	case o.pathToSavedData != "":
+		// A relative path has no authority: file:data.json
+		return "file:" + (&url.URL{Path: o.pathToSavedData}).EscapedPath()
+	default:
+		return "memory://"
+	}
+}
+
+// dsnToURI returns the database DSN as a postgres:// URI. A key/value DSN such as
+// "host=localhost dbname=shortener" is converted to URI query parameters, which PostgreSQL accepts alike.
+func dsnToURI(dsn string) (string, error) {
+	if strings.Contains(dsn, "://") {
+		return dsn, nil
+	}
+
+	query := url.Values{}
+	rest := strings.TrimSpace(dsn)
+	for rest != "" {
+		key, value, found := strings.Cut(rest, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.ContainsAny(key, " \t") {
+			return "", errors.New("database DSN: expected key=value pairs or a postgres:// URI")
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		var b strings.Builder
+		quoted := strings.HasPrefix(value, "'")
+		i := 0
+		if quoted {
+			i = 1
+		}
+		for ; i < len(value); i++ {
+			c := value[i]
+			if c == '\\' && i+1 < len(value) {
+				i++
+				b.WriteByte(value[i])
+				continue
+			}
+			if (quoted && c == '\'') || (!quoted && (c == ' ' || c == '\t')) {
+				break
+			}
+			b.WriteByte(c)
+		}
+		if quoted {
+			if i == len(value) {
+				return "", errors.New("database DSN: unterminated quoted value")
+			}
+			// Skip the closing quote
+			i++
+		}
+		query.Set(key, b.String())
+		rest = strings.TrimSpace(value[i:])
+	}
+	return (&url.URL{Scheme: "postgres", Path: "/", RawQuery: query.Encode()}).String(), nil
+}
+
 // GetSecret returns the secret key for URL service
 func (o *Options) GetSecret() string {
 	return o.secret
 }
+
+// GetOIDCIssuer returns the OpenID Connect issuer URL, empty if SSO login is disabled
+func (o *Options) GetOIDCIssuer() string {
+	return o.oidcIssuer
+}
+
+// GetOIDCClientID returns the OpenID Connect client ID
+func (o *Options) GetOIDCClientID() string {
+	return o.oidcClientID
+}
+
+// GetOIDCClientSecret returns the OpenID Connect client secret
+func (o *Options) GetOIDCClientSecret() string {
+	return o.oidcSecret
+}
+
+// GetOIDCRedirectURL returns the OpenID Connect callback URL
+func (o *Options) GetOIDCRedirectURL() string {
+	return o.oidcRedirectURL
+}
+
+// GetAdminToken returns the bearer token protecting the admin API, empty if the admin API is disabled
+func (o *Options) GetAdminToken() string {
+	return o.adminToken
+}
+
+// GetTrustedSubnet returns the CIDR of the subnet allowed to read internal statistics
+func (o *Options) GetTrustedSubnet() string {
+	return o.trustedSubnet
+}
+
+// GetEnableHTTPS reports whether the server serves HTTPS
+func (o *Options) GetEnableHTTPS() bool {
+	return o.enableHTTPS
+}
+
+// GetTLSCertFile returns the path to the TLS certificate file
+func (o *Options) GetTLSCertFile() string {
+	return o.tlsCertFile
+}
+
+// GetTLSKeyFile returns the path to the TLS private key file
+func (o *Options) GetTLSKeyFile() string {
+	return o.tlsKeyFile
+}
+
+// GetHTTPRedirectAddress returns the address of the HTTP to HTTPS redirect listener
+func (o *Options) GetHTTPRedirectAddress() string {
+	return o.httpRedirect
+}
+
+// GetLogLevel returns the minimal level of logged messages
+func (o *Options) GetLogLevel() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.logLevel
+}
+
+// GetLogHashPII reports whether user IDs and URLs are replaced by a hash in the logs
+func (o *Options) GetLogHashPII() bool {
+	return o.logHashPII
+}
+
+// GetRPCAddress returns the address of the RPC listener, empty if the RPC API is disabled
+func (o *Options) GetRPCAddress() string {
+	return o.rpcAddress
+}
+
+// GetDevMode reports whether the server runs in development mode
+func (o *Options) GetDevMode() bool {
+	return o.devMode
+}
+
+// GetMetricsAddress returns the address of the admin listener serving metrics, empty if disabled
+func (o *Options) GetMetricsAddress() string {
+	return o.metricsAddress
+}
+
+// GetImportMaxRows returns the maximal number of rows of a bulk import
+func (o *Options) GetImportMaxRows() int {
+	return o.importMaxRows
+}
+
+// GetBodyLimits returns the limits of request bodies
+func (o *Options) GetBodyLimits() bodylimit.Config {
+	// Validated by Validate
+	routes, _ := bodylimit.ParseRoutes(o.bodyLimitRoutes)
+	return bodylimit.Config{
+		MaxBytes: int64(o.maxBodyBytes),
+		Routes:   routes,
+		MaxRatio: int64(o.maxDecompressionRatio),
+	}
+}
+
+// GetMaxBatchSize returns the maximal number of items of a batch request
+func (o *Options) GetMaxBatchSize() int {
+	return o.maxBatchSize
+}
+
+// GetMaxURLLength returns the maximal length of shortened URLs
+func (o *Options) GetMaxURLLength() int {
+	return o.maxURLLength
+}
+
+// GetDeleteQueueSize returns the number of deletion requests queued before new ones are rejected
+func (o *Options) GetDeleteQueueSize() int {
+	return o.deleteQueueSize
+}
+
+// GetDeleteWorkers returns the number of workers writing queued deletions
+func (o *Options) GetDeleteWorkers() int {
+	return o.deleteWorkers
+}
+
+// GetDeleteFlushInterval returns the interval at which queued deletions are written
+func (o *Options) GetDeleteFlushInterval() time.Duration {
+	return time.Duration(o.deleteFlushMillis) * time.Millisecond
+}
+
+// GetDeleteJournalPath returns the path of the journal persisting queued deletions, empty if disabled
+func (o *Options) GetDeleteJournalPath() string {
+	return o.deleteJournalPath
+}
+
+// GetAccessLogFormat returns the format of the access log
+func (o *Options) GetAccessLogFormat() accesslog.Format {
+	return accesslog.Format(o.accessLogFormat)
+}
+
+// GetAccessLogOutput returns where the access log is written: stdout or the path of a file
+func (o *Options) GetAccessLogOutput() string {
+	return o.accessLogOutput
+}
+
+// GetAccessLogRotation returns when the access log file is rotated and how long rotated files are kept
+func (o *Options) GetAccessLogRotation() accesslog.Rotation {
+	return accesslog.Rotation{
+		MaxSize:    int64(o.accessLogMaxSizeMB) << 20,
+		Interval:   time.Duration(o.accessLogRotateHours) * time.Hour,
+		MaxBackups: o.accessLogMaxBackups,
+		MaxAge:     time.Duration(o.accessLogMaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// GetAccessLogSampleRate returns the rate at which successful requests of the sampled routes are logged
+func (o *Options) GetAccessLogSampleRate() int {
+	return o.accessLogSampleRate
+}
+
+// GetAccessLogSampleRoutes returns the route patterns whose access log is sampled
+func (o *Options) GetAccessLogSampleRoutes() []string {
+	var routes []string
+	for _, route := range strings.Split(o.accessLogSampleRoutes, ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
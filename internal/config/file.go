@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values in the printed configuration
+const redacted = "REDACTED"
+
+// fileOptions is the representation of Options in a configuration file.
+// Keys are named after the matching environment variables, lower cased.
+type fileOptions struct {
+	ServerAddress         string `json:"server_address" yaml:"server_address"`
+	BaseURL               string `json:"base_url" yaml:"base_url"`
+	FileStoragePath       string `json:"file_storage_path" yaml:"file_storage_path"`
+	DatabaseDSN           string `json:"database_dsn" yaml:"database_dsn"`
+	StorageURI            string `json:"storage_uri" yaml:"storage_uri"`
+	Secret                string `json:"secret" yaml:"secret"`
+	OIDCIssuer            string `json:"oidc_issuer" yaml:"oidc_issuer"`
+	OIDCClientID          string `json:"oidc_client_id" yaml:"oidc_client_id"`
+	OIDCClientSecret      string `json:"oidc_client_secret" yaml:"oidc_client_secret"`
+	OIDCRedirectURL       string `json:"oidc_redirect_url" yaml:"oidc_redirect_url"`
+	AdminToken            string `json:"admin_token" yaml:"admin_token"`
+	TrustedSubnet         string `json:"trusted_subnet" yaml:"trusted_subnet"`
+	EnableHTTPS           bool   `json:"enable_https" yaml:"enable_https"`
+	TLSCertFile           string `json:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSKeyFile            string `json:"tls_key_file" yaml:"tls_key_file"`
+	HTTPRedirectAddress   string `json:"http_redirect_address" yaml:"http_redirect_address"`
+	LogLevel              string `json:"log_level" yaml:"log_level"`
+	LogHashPII            bool   `json:"log_hash_pii" yaml:"log_hash_pii"`
+	RPCAddress            string `json:"rpc_address" yaml:"rpc_address"`
+	MetricsAddress        string `json:"metrics_address" yaml:"metrics_address"`
+	DevMode               bool   `json:"dev_mode" yaml:"dev_mode"`
+	ImportMaxRows         int    `json:"import_max_rows" yaml:"import_max_rows"`
+	MaxBodyBytes          int    `json:"max_body_bytes" yaml:"max_body_bytes"`
+	BodyLimitRoutes       string `json:"body_limit_routes" yaml:"body_limit_routes"`
+	MaxDecompressionRatio int    `json:"max_decompression_ratio" yaml:"max_decompression_ratio"`
+	MaxBatchSize          int    `json:"max_batch_size" yaml:"max_batch_size"`
+	MaxURLLength          int    `json:"max_url_length" yaml:"max_url_length"`
+	DeleteQueueSize       int    `json:"delete_queue_size" yaml:"delete_queue_size"`
+	DeleteWorkers         int    `json:"delete_workers" yaml:"delete_workers"`
+	DeleteFlushMillis     int    `json:"delete_flush_interval_ms" yaml:"delete_flush_interval_ms"`
+	DeleteJournalPath     string `json:"delete_journal_path" yaml:"delete_journal_path"`
+	AccessLogFormat       string `json:"access_log_format" yaml:"access_log_format"`
+	AccessLogOutput       string `json:"access_log_output" yaml:"access_log_output"`
+	AccessLogMaxSizeMB    int    `json:"access_log_max_size_mb" yaml:"access_log_max_size_mb"`
+	AccessLogRotateHours  int    `json:"access_log_rotate_hours" yaml:"access_log_rotate_hours"`
+	AccessLogMaxBackups   int    `json:"access_log_max_backups" yaml:"access_log_max_backups"`
+	AccessLogMaxAgeDays   int    `json:"access_log_max_age_days" yaml:"access_log_max_age_days"`
+	AccessLogSampleRate   int    `json:"access_log_sample_rate" yaml:"access_log_sample_rate"`
+	AccessLogSampleRoutes string `json:"access_log_sample_routes" yaml:"access_log_sample_routes"`
+}
+
+// toFileOptions returns the file representation of the options
+func (o *Options) toFileOptions() fileOptions {
+	return fileOptions{
+		ServerAddress:         o.serverURL,
+		BaseURL:               o.baseURL,
+		FileStoragePath:       o.pathToSavedData,
+		DatabaseDSN:           o.databaseDSN,
+		StorageURI:            o.storageURI,
+		Secret:                o.secret,
+		OIDCIssuer:            o.oidcIssuer,
+		OIDCClientID:          o.oidcClientID,
+		OIDCClientSecret:      o.oidcSecret,
+		OIDCRedirectURL:       o.oidcRedirectURL,
+		AdminToken:            o.adminToken,
+		TrustedSubnet:         o.trustedSubnet,
+		EnableHTTPS:           o.enableHTTPS,
+		TLSCertFile:           o.tlsCertFile,
+		TLSKeyFile:            o.tlsKeyFile,
+		HTTPRedirectAddress:   o.httpRedirect,
+		LogLevel:              o.logLevel,
+		LogHashPII:            o.logHashPII,
+		RPCAddress:            o.rpcAddress,
+		MetricsAddress:        o.metricsAddress,
+		DevMode:               o.devMode,
+		ImportMaxRows:         o.importMaxRows,
+		MaxBodyBytes:          o.maxBodyBytes,
+		BodyLimitRoutes:       o.bodyLimitRoutes,
+		MaxDecompressionRatio: o.maxDecompressionRatio,
+		MaxBatchSize:          o.maxBatchSize,
+		MaxURLLength:          o.maxURLLength,
+		DeleteQueueSize:       o.deleteQueueSize,
+		DeleteWorkers:         o.deleteWorkers,
+		DeleteFlushMillis:     o.deleteFlushMillis,
+		DeleteJournalPath:     o.deleteJournalPath,
+		AccessLogFormat:       o.accessLogFormat,
+		AccessLogOutput:       o.accessLogOutput,
+		AccessLogMaxSizeMB:    o.accessLogMaxSizeMB,
+		AccessLogRotateHours:  o.accessLogRotateHours,
+		AccessLogMaxBackups:   o.accessLogMaxBackups,
+		AccessLogMaxAgeDays:   o.accessLogMaxAgeDays,
+		AccessLogSampleRate:   o.accessLogSampleRate,
+		AccessLogSampleRoutes: o.accessLogSampleRoutes,
+	}
+}
+
+// applyFileOptions sets the options from their file representation
+func (o *Options) applyFileOptions(f fileOptions) {
+	o.serverURL = f.ServerAddress
+	o.baseURL = f.BaseURL
+	o.pathToSavedData = f.FileStoragePath
+	o.databaseDSN = f.DatabaseDSN
+	o.storageURI = f.StorageURI
+	o.secret = f.Secret
+	o.oidcIssuer = f.OIDCIssuer
+	o.oidcClientID = f.OIDCClientID
+	o.oidcSecret = f.OIDCClientSecret
+	o.oidcRedirectURL = f.OIDCRedirectURL
+	o.adminToken = f.AdminToken
+	o.trustedSubnet = f.TrustedSubnet
+	o.enableHTTPS = f.EnableHTTPS
+	o.tlsCertFile = f.TLSCertFile
+	o.tlsKeyFile = f.TLSKeyFile
+	o.httpRedirect = f.HTTPRedirectAddress
+	o.logLevel = f.LogLevel
+	o.logHashPII = f.LogHashPII
+	o.rpcAddress = f.RPCAddress
+	o.metricsAddress = f.MetricsAddress
+	o.devMode = f.DevMode
+	o.importMaxRows = f.ImportMaxRows
+	o.maxBodyBytes = f.MaxBodyBytes
+	o.bodyLimitRoutes = f.BodyLimitRoutes
+	o.maxDecompressionRatio = f.MaxDecompressionRatio
+	o.maxBatchSize = f.MaxBatchSize
+	o.maxURLLength = f.MaxURLLength
+	o.deleteQueueSize = f.DeleteQueueSize
+	o.deleteWorkers = f.DeleteWorkers
+	o.deleteFlushMillis = f.DeleteFlushMillis
+	o.deleteJournalPath = f.DeleteJournalPath
+	o.accessLogFormat = f.AccessLogFormat
+	o.accessLogOutput = f.AccessLogOutput
+	o.accessLogMaxSizeMB = f.AccessLogMaxSizeMB
+	o.accessLogRotateHours = f.AccessLogRotateHours
+	o.accessLogMaxBackups = f.AccessLogMaxBackups
+	o.accessLogMaxAgeDays = f.AccessLogMaxAgeDays
+	o.accessLogSampleRate = f.AccessLogSampleRate
+	o.accessLogSampleRoutes = f.AccessLogSampleRoutes
+}
+
+// LoadFile loads configuration from a JSON or YAML file, chosen by the .yaml/.yml extension.
+// Only the keys present in the file override the current options; unknown keys are rejected
+// so that typos don't silently fall back to defaults.
+func (o *Options) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	// Decoding over the current values keeps the options missing from the file
+	f := o.toFileOptions()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&f); err != nil && err != io.EOF {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&f); err != nil {
+			return fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	o.applyFileOptions(f)
+	return nil
+}
+
+// PrintConfig writes the effective configuration as JSON with secrets redacted.
+// The output uses the configuration file keys, so it can serve as a starting config file.
+func (o *Options) PrintConfig(w io.Writer) error {
+	f := o.toFileOptions()
+	for _, secret := range []*string{&f.Secret, &f.OIDCClientSecret, &f.AdminToken} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	f.DatabaseDSN = redactDSN(f.DatabaseDSN)
+	f.StorageURI = redactDSN(f.StorageURI)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(f)
+}
+
+// dsnPasswordPattern matches the password of a key/value connection string
+var dsnPasswordPattern = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+// redactDSN hides the password of a database connection string in URL or key/value form
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+		return dsn
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "${1}"+redacted)
+}
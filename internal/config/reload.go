@@ -0,0 +1,90 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Dynamic holds the options that can be reloaded while the server runs
+type Dynamic struct {
+	LogLevel string
+	BaseURL  string
+}
+
+// dynamicKeys are the configuration file keys of the reloadable options
+var dynamicKeys = map[string]bool{
+	"log_level": true,
+	"base_url":  true,
+}
+
+// Dynamic returns the current values of the reloadable options
+func (o *Options) Dynamic() Dynamic {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return Dynamic{
+		LogLevel: o.logLevel,
+		BaseURL:  o.baseURL,
+	}
+}
+
+// Subscribe registers a function called with the new reloadable options after each reload changing them
+func (o *Options) Subscribe(fn func(Dynamic)) {
+	o.reloadMu.Lock()
+	defer o.reloadMu.Unlock()
+	o.subscribers = append(o.subscribers, fn)
+}
+
+// Reload re-reads the flags, environment variables and configuration file and applies
+// the reloadable options, notifying the subscribers if they changed.
+// The new configuration must be valid as a whole, otherwise nothing is changed.
+// Changes to options that need a restart are not applied and returned as warnings.
+func (o *Options) Reload() ([]string, error) {
+	o.reloadMu.Lock()
+	defer o.reloadMu.Unlock()
+
+	next := NewOptions()
+	fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := next.parse(fs, o.args); err != nil {
+		return nil, err
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	warnings := staticChanges(o.toFileOptions(), next.toFileOptions())
+
+	previous := o.Dynamic()
+	o.mu.Lock()
+	o.logLevel = next.logLevel
+	o.baseURL = next.baseURL
+	o.mu.Unlock()
+
+	if current := o.Dynamic(); current != previous {
+		for _, fn := range o.subscribers {
+			fn(current)
+		}
+	}
+	return warnings, nil
+}
+
+// staticChanges lists the options needing a restart that differ between two configurations.
+// Only the option names are reported so secrets are never exposed.
+func staticChanges(current, next fileOptions) []string {
+	var warnings []string
+
+	currentValue, nextValue := reflect.ValueOf(current), reflect.ValueOf(next)
+	for i := range currentValue.NumField() {
+		key, _, _ := strings.Cut(currentValue.Type().Field(i).Tag.Get("json"), ",")
+		if dynamicKeys[key] {
+			continue
+		}
+		if !currentValue.Field(i).Equal(nextValue.Field(i)) {
+			warnings = append(warnings, fmt.Sprintf("%s changed, restart required to apply it", key))
+		}
+	}
+	return warnings
+}
@@ -10,6 +10,7 @@ import (
 // DatabaseManagerInterface defines the contract for database operations
 type DatabaseManagerInterface interface {
 	Ping(ctx context.Context) error
+	Migrate(ctx context.Context) error
 	Close()
 	GetPool() *pgxpool.Pool
 }
@@ -19,16 +20,27 @@ type DatabaseManager struct {
 	pool *pgxpool.Pool
 }
 
-// NewDatabaseManager creates a new database manager instance
+// NewDatabaseManager creates a new database manager instance and checks the database is reachable.
+// The schema is created by Migrate.
 func NewDatabaseManager(databaseDSN string) (DatabaseManagerInterface, error) {
 	pool, err := pgxpool.New(context.Background(), databaseDSN)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create connection pool: %v", err)
 	}
 
-	// Create the database table if it doesn't exist
-	ctx := context.Background()
-	_, err = pool.Exec(ctx, `
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("unable to reach database: %v", err)
+	}
+
+	return &DatabaseManager{
+		pool: pool,
+	}, nil
+}
+
+// Migrate creates the database tables and indexes if they don't exist
+func (dm *DatabaseManager) Migrate(ctx context.Context) error {
+	_, err := dm.pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS urls (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			token VARCHAR(10) NOT NULL UNIQUE,
@@ -37,17 +49,27 @@ func NewDatabaseManager(databaseDSN string) (DatabaseManagerInterface, error) {
 			is_deleted BOOLEAN NOT NULL DEFAULT FALSE,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_disabled BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS client_ip_hash TEXT NOT NULL DEFAULT '';
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_original_url ON urls (original_url);
 		CREATE INDEX IF NOT EXISTS idx_urls_user_id ON urls (user_id);
+		CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls (created_at);
+		CREATE INDEX IF NOT EXISTS idx_urls_user_created_at ON urls (user_id, created_at NULLS FIRST, token COLLATE "C");
+		CREATE INDEX IF NOT EXISTS idx_urls_user_original_url ON urls (user_id, original_url COLLATE "C", token COLLATE "C");
+		CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			login TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
 	`)
 	if err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("unable to create table: %v", err)
+		return fmt.Errorf("unable to create table: %v", err)
 	}
-
-	return &DatabaseManager{
-		pool: pool,
-	}, nil
+	return nil
 }
 
 // Ping checks database connectivity
@@ -0,0 +1,212 @@
+package deletion
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// compactThreshold is the number of completion records after which the journal is rewritten
+// with the pending requests only
+const compactThreshold = 1000
+
+// journalRecord is a line of the journal: an accepted request, or the completion of one
+type journalRecord struct {
+	Request
+	Done bool `json:"done,omitempty"`
+}
+
+// journal persists the accepted requests until they are written to storage.
+// It is an append-only file of JSON lines, compacted on startup and once enough requests completed.
+// Without a path it only numbers the requests.
+type journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	nextID  uint64
+	pending map[uint64]Request
+	// completed counts the completion records written since the last compaction
+	completed int
+}
+
+// openJournal opens the journal at path, creating it if needed, and returns the requests it holds
+// that were never completed, in the order they were accepted
+func openJournal(path string) (*journal, []Request, error) {
+	j := &journal{path: path, nextID: 1, pending: make(map[uint64]Request)}
+	if path == "" {
+		return j, nil, nil
+	}
+
+	if err := j.load(); err != nil {
+		return nil, nil, err
+	}
+	replayed := j.pendingRequests()
+	if err := j.compact(); err != nil {
+		return nil, nil, err
+	}
+	return j, replayed, nil
+}
+
+// load reads the records of the journal file, if it exists
+func (j *journal) load() error {
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record journalRecord
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				// A record cut short by a crash is dropped, the request was never acknowledged
+				zap.L().Warn("Skipping malformed deletion journal record", zap.String("path", j.path), zap.Error(jsonErr))
+			} else {
+				if record.Done {
+					delete(j.pending, record.ID)
+				} else {
+					j.pending[record.ID] = record.Request
+				}
+				j.nextID = max(j.nextID, record.ID+1)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// pendingRequests returns the pending requests ordered by ID
+func (j *journal) pendingRequests() []Request {
+	requests := make([]Request, 0, len(j.pending))
+	for _, req := range j.pending {
+		requests = append(requests, req)
+	}
+	sort.Slice(requests, func(a, b int) bool { return requests[a].ID < requests[b].ID })
+	return requests
+}
+
+// compact rewrites the journal with the pending requests only, replacing the file atomically
+func (j *journal) compact() error {
+	tmpPath := j.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, req := range j.pendingRequests() {
+		if err := writeRecord(writer, journalRecord{Request: req}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file, err = os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0600)
+	j.completed = 0
+	return err
+}
+
+// add numbers a request and persists it before it is acknowledged
+func (j *journal) add(req Request) (Request, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	req.ID = j.nextID
+	j.nextID++
+	if j.file == nil {
+		return req, nil
+	}
+
+	if err := writeRecord(j.file, journalRecord{Request: req}); err != nil {
+		return Request{}, err
+	}
+	if err := j.file.Sync(); err != nil {
+		return Request{}, err
+	}
+	j.pending[req.ID] = req
+	return req, nil
+}
+
+// done records the completion of requests. Completion records are not synced: if they are lost,
+// the deletions are only written again on restart.
+func (j *journal) done(requests []Request) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, req := range requests {
+		if err := writeRecord(&buf, journalRecord{Request: Request{ID: req.ID}, Done: true}); err != nil {
+			return err
+		}
+		delete(j.pending, req.ID)
+	}
+	if _, err := j.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	j.completed += len(requests)
+	if j.completed >= compactThreshold {
+		return j.compact()
+	}
+	return nil
+}
+
+// close closes the journal file, which keeps the requests still pending for the next run
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// writeRecord writes a record as a line of JSON
+func writeRecord(w io.Writer, record journalRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
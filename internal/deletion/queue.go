@@ -0,0 +1,342 @@
+// Package deletion deletes the URLs of users in the background.
+//
+// Deletion requests are accepted into a bounded queue and return at once. Workers coalesce
+// the tokens of all the requests received during a flush interval into a single write to storage,
+// retrying failed writes with exponential backoff. With a journal file, accepted requests are
+// persisted until written, so deletions still pending when the process stops are resumed on restart.
+package deletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Errors returned by Enqueue
+var (
+	// ErrQueueFull is returned when the queue is at capacity, the request should be retried later
+	ErrQueueFull = errors.New("deletion queue is full")
+	// ErrClosed is returned once the queue is closed
+	ErrClosed = errors.New("deletion queue is closed")
+)
+
+// Deleter marks URLs as deleted, given as tokens by user ID, in a single operation
+type Deleter interface {
+	DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error
+}
+
+// Config sets the behaviour of a Queue. Zero values are replaced by the defaults.
+type Config struct {
+	// Size is the number of requests the queue holds before rejecting new ones
+	Size int
+	// Workers is the number of workers writing deletions concurrently
+	Workers int
+	// FlushInterval is how long a worker collects requests before writing them
+	FlushInterval time.Duration
+	// MaxBatch is the number of tokens that triggers a write before the flush interval elapses
+	MaxBatch int
+	// MaxAttempts is the number of times a write is tried before giving up
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry, doubled for every following one
+	RetryBackoff time.Duration
+	// JournalPath is the file pending requests are persisted to, none if empty
+	JournalPath string
+}
+
+// Default configuration values
+const (
+	DefaultSize          = 1000
+	DefaultWorkers       = 1
+	DefaultFlushInterval = time.Second
+	DefaultMaxBatch      = 10000
+	DefaultMaxAttempts   = 5
+	DefaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// maxRetryBackoff caps the delay between two attempts of a write
+const maxRetryBackoff = 10 * time.Second
+
+// withDefaults returns the configuration with zero values replaced by the defaults
+func (c Config) withDefaults() Config {
+	if c.Size <= 0 {
+		c.Size = DefaultSize
+	}
+	if c.Workers <= 0 {
+		c.Workers = DefaultWorkers
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultFlushInterval
+	}
+	if c.MaxBatch <= 0 {
+		c.MaxBatch = DefaultMaxBatch
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = DefaultRetryBackoff
+	}
+	return c
+}
+
+// Request asks for the URLs of a user to be deleted
+type Request struct {
+	ID     uint64   `json:"id"`
+	UserID string   `json:"user_id,omitempty"`
+	Tokens []string `json:"tokens,omitempty"`
+	// RequestID is the ID of the HTTP request that asked for the deletion, to correlate the logs of its write
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Stats describes the state of the queue
+type Stats struct {
+	// Queued is the number of requests waiting for a worker
+	Queued int `json:"queued"`
+	// Pending is the number of accepted requests not written to storage yet, queued ones included
+	Pending int64 `json:"pending"`
+	// Failed is the number of requests given up after all attempts; they are retried on restart with a journal
+	Failed int64 `json:"failed"`
+}
+
+// Queue deletes URLs in the background, see the package documentation
+type Queue struct {
+	deleter  Deleter
+	config   Config
+	journal  *journal
+	requests chan Request
+	logger   *zap.Logger
+
+	// mu guards closed, so no request is sent once the channel is closed
+	mu     sync.RWMutex
+	closed bool
+
+	workers sync.WaitGroup
+	pending atomic.Int64
+	failed  atomic.Int64
+}
+
+// NewQueue creates a queue writing deletions with the deleter and starts its workers.
+// The requests left pending in the journal by a previous run are queued again before it returns.
+func NewQueue(deleter Deleter, config Config) (*Queue, error) {
+	config = config.withDefaults()
+
+	j, replayed, err := openJournal(config.JournalPath)
+	if err != nil {
+		return nil, fmt.Errorf("open deletion journal: %w", err)
+	}
+
+	q := &Queue{
+		deleter:  deleter,
+		config:   config,
+		journal:  j,
+		requests: make(chan Request, config.Size),
+		logger:   zap.L(),
+	}
+	for range config.Workers {
+		q.workers.Add(1)
+		go q.work()
+	}
+
+	if len(replayed) > 0 {
+		q.logger.Info("Resuming pending deletions", zap.Int("requests", len(replayed)))
+	}
+	for _, req := range replayed {
+		q.pending.Add(1)
+		q.requests <- req
+	}
+	return q, nil
+}
+
+// Enqueue accepts a request to delete URLs of a user and returns before they are deleted.
+// It returns ErrQueueFull if the queue is at capacity and ErrClosed once the queue is closed.
+// The request ID of the context is kept with the request and logged when it is written.
+func (q *Queue) Enqueue(ctx context.Context, userID string, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+
+	req, err := q.journal.add(Request{UserID: userID, Tokens: tokens, RequestID: logger.RequestIDFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("journal deletion: %w", err)
+	}
+
+	q.pending.Add(1)
+	select {
+	case q.requests <- req:
+		return nil
+	default:
+		q.pending.Add(-1)
+		if err := q.journal.done([]Request{req}); err != nil {
+			logger.FromContext(ctx).Error("Failed to journal rejected deletion", zap.Error(err))
+		}
+		return ErrQueueFull
+	}
+}
+
+// Depth returns the number of accepted requests not written to storage yet
+func (q *Queue) Depth() int {
+	return int(q.pending.Load())
+}
+
+// Stats returns the state of the queue
+func (q *Queue) Stats() Stats {
+	return Stats{
+		Queued:  len(q.requests),
+		Pending: q.pending.Load(),
+		Failed:  q.failed.Load(),
+	}
+}
+
+// CheckHealth returns ErrClosed once the queue is closed and ErrQueueFull while it is at capacity,
+// as new deletions are rejected in both cases
+func (q *Queue) CheckHealth(ctx context.Context) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+	if len(q.requests) >= q.config.Size {
+		return fmt.Errorf("%w: %d requests queued", ErrQueueFull, len(q.requests))
+	}
+	return nil
+}
+
+// Close stops accepting requests and waits for the workers to write the queued ones.
+// It returns the context error if they are not written before the context is done.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.requests)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return q.journal.close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work collects requests and writes them every flush interval, or as soon as the batch is full,
+// until the queue is closed and drained
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	ticker := time.NewTicker(q.config.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []Request
+	tokens := 0
+	flush := func() {
+		if len(batch) > 0 {
+			q.flush(batch)
+			batch, tokens = nil, 0
+		}
+	}
+
+	for {
+		select {
+		case req, ok := <-q.requests:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, req)
+			if tokens += len(req.Tokens); tokens >= q.config.MaxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// flush writes the deletions of a batch of requests in a single operation, retrying with backoff.
+// Requests are removed from the journal once written; if every attempt fails they are kept in it.
+func (q *Queue) flush(batch []Request) {
+	defer q.pending.Add(-int64(len(batch)))
+
+	ctx := batchContext(batch)
+	log := q.logger.With(logger.Fields(ctx)...)
+	tokensByUser := coalesce(batch)
+	backoff := q.config.RetryBackoff
+	var err error
+	for attempt := 1; attempt <= q.config.MaxAttempts; attempt++ {
+		if err = q.deleter.DeleteURLsByUser(ctx, tokensByUser); err == nil {
+			break
+		}
+		if attempt == q.config.MaxAttempts {
+			break
+		}
+		log.Warn("Deleting URLs failed, retrying",
+			zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+
+	if err != nil {
+		q.failed.Add(int64(len(batch)))
+		log.Error("Deleting URLs failed, giving up",
+			zap.Int("requests", len(batch)), zap.Int("attempts", q.config.MaxAttempts), zap.Error(err))
+		return
+	}
+
+	if err := q.journal.done(batch); err != nil {
+		log.Error("Failed to journal completed deletions", zap.Error(err))
+	}
+}
+
+// batchContext returns the context of the write of a batch, whose logger carries the IDs
+// of the HTTP requests that asked for the deletions
+func batchContext(batch []Request) context.Context {
+	requestIDs := make([]string, 0, len(batch))
+	for _, req := range batch {
+		if req.RequestID != "" {
+			requestIDs = append(requestIDs, req.RequestID)
+		}
+	}
+	ctx := context.Background()
+	if len(requestIDs) == 0 {
+		return ctx
+	}
+	return logger.WithFields(ctx, zap.Strings("request_ids", requestIDs))
+}
+
+// coalesce merges the tokens of the requests by user, without duplicates
+func coalesce(batch []Request) map[string][]string {
+	seen := make(map[string]map[string]struct{})
+	tokensByUser := make(map[string][]string)
+	for _, req := range batch {
+		if seen[req.UserID] == nil {
+			seen[req.UserID] = make(map[string]struct{})
+		}
+		for _, token := range req.Tokens {
+			if _, found := seen[req.UserID][token]; found {
+				continue
+			}
+			seen[req.UserID][token] = struct{}{}
+			tokensByUser[req.UserID] = append(tokensByUser[req.UserID], token)
+		}
+	}
+	return tokensByUser
+}
@@ -0,0 +1,203 @@
+package deletion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingDeleter records the writes it receives and fails the first ones if asked to
+type recordingDeleter struct {
+	mu     sync.Mutex
+	fail   int
+	calls  int
+	writes []map[string][]string
+	fields [][]zap.Field
+}
+
+func (d *recordingDeleter) DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls++
+	if d.calls <= d.fail {
+		return errors.New("storage unavailable")
+	}
+	d.writes = append(d.writes, tokensByUser)
+	d.fields = append(d.fields, logger.Fields(ctx))
+	return nil
+}
+
+func (d *recordingDeleter) snapshot() (int, []map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls, d.writes
+}
+
+func TestQueueCoalescesRequests(t *testing.T) {
+	deleter := &recordingDeleter{}
+	q, err := NewQueue(deleter, Config{FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(logger.WithRequestID(ctx, "req-1"), "user1", []string{"a", "b"}))
+	require.NoError(t, q.Enqueue(ctx, "user2", []string{"c"}))
+	require.NoError(t, q.Enqueue(logger.WithRequestID(ctx, "req-3"), "user1", []string{"b", "d"}))
+	require.NoError(t, q.Enqueue(ctx, "user1", nil))
+
+	// Closing flushes the requests collected so far in a single write
+	require.NoError(t, q.Close(context.Background()))
+
+	calls, writes := deleter.snapshot()
+	assert.Equal(t, 1, calls)
+	require.Len(t, writes, 1)
+	sort.Strings(writes[0]["user1"])
+	assert.Equal(t, map[string][]string{"user1": {"a", "b", "d"}, "user2": {"c"}}, writes[0])
+	assert.Equal(t, Stats{}, q.Stats())
+	// The write is logged with the IDs of the HTTP requests it serves
+	assert.Equal(t, []zap.Field{zap.Strings("request_ids", []string{"req-1", "req-3"})}, deleter.fields[0])
+
+	assert.ErrorIs(t, q.Enqueue(ctx, "user1", []string{"e"}), ErrClosed)
+}
+
+func TestQueueRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       int
+		wantCalls  int
+		wantWrites int
+		wantFailed int64
+	}{
+		{
+			name:       "succeeds after retries",
+			fail:       2,
+			wantCalls:  3,
+			wantWrites: 1,
+		},
+		{
+			name:       "gives up after all attempts",
+			fail:       10,
+			wantCalls:  3,
+			wantFailed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleter := &recordingDeleter{fail: tt.fail}
+			q, err := NewQueue(deleter, Config{
+				FlushInterval: time.Hour,
+				MaxAttempts:   3,
+				RetryBackoff:  time.Millisecond,
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+			require.NoError(t, q.Close(context.Background()))
+
+			calls, writes := deleter.snapshot()
+			assert.Equal(t, tt.wantCalls, calls)
+			assert.Len(t, writes, tt.wantWrites)
+			assert.Equal(t, tt.wantFailed, q.Stats().Failed)
+			assert.Equal(t, 0, q.Depth())
+		})
+	}
+}
+
+func TestQueueJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletions.journal")
+
+	// The first run can't write, its request stays in the journal
+	failing := &recordingDeleter{fail: 10}
+	q, err := NewQueue(failing, Config{FlushInterval: time.Hour, MaxAttempts: 1, JournalPath: path})
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a", "b"}))
+	require.NoError(t, q.Close(context.Background()))
+
+	// The next run resumes it and later requests get new IDs
+	deleter := &recordingDeleter{}
+	q, err = NewQueue(deleter, Config{FlushInterval: time.Hour, JournalPath: path})
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(context.Background(), "user2", []string{"c"}))
+	require.NoError(t, q.Close(context.Background()))
+
+	_, writes := deleter.snapshot()
+	require.Len(t, writes, 1)
+	assert.Equal(t, map[string][]string{"user1": {"a", "b"}, "user2": {"c"}}, writes[0])
+
+	// Nothing is left for a third run
+	deleter = &recordingDeleter{}
+	q, err = NewQueue(deleter, Config{FlushInterval: time.Hour, JournalPath: path})
+	require.NoError(t, err)
+	assert.Equal(t, 0, q.Depth())
+	require.NoError(t, q.Close(context.Background()))
+	calls, _ := deleter.snapshot()
+	assert.Zero(t, calls)
+}
+
+func TestQueueCheckHealth(t *testing.T) {
+	q, err := NewQueue(&recordingDeleter{}, Config{FlushInterval: time.Hour})
+	require.NoError(t, err)
+	assert.NoError(t, q.CheckHealth(context.Background()))
+
+	require.NoError(t, q.Close(context.Background()))
+	assert.ErrorIs(t, q.CheckHealth(context.Background()), ErrClosed)
+}
+
+func TestJournalCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletions.journal")
+
+	j, _, err := openJournal(path)
+	require.NoError(t, err)
+	req, err := j.add(Request{UserID: "user1", Tokens: []string{"a"}})
+	require.NoError(t, err)
+	require.NoError(t, j.done([]Request{req}))
+
+	// Completing every pending request doesn't rewrite the journal
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(data, []byte("\n")))
+	require.NoError(t, j.close())
+
+	// The next run compacts it
+	j, replayed, err := openJournal(path)
+	require.NoError(t, err)
+	defer j.close()
+	assert.Empty(t, replayed)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestJournalSkipsTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletions.journal")
+
+	j, _, err := openJournal(path)
+	require.NoError(t, err)
+	_, err = j.add(Request{UserID: "user1", Tokens: []string{"a"}})
+	require.NoError(t, err)
+	// A crash in the middle of a write leaves a partial line
+	_, err = j.file.WriteString(`{"id":2,"user_id":"us`)
+	require.NoError(t, err)
+	require.NoError(t, j.close())
+
+	j, replayed, err := openJournal(path)
+	require.NoError(t, err)
+	defer j.close()
+	assert.Equal(t, []Request{{ID: 1, UserID: "user1", Tokens: []string{"a"}}}, replayed)
+
+	req, err := j.add(Request{UserID: "user1", Tokens: []string{"b"}})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), req.ID)
+}
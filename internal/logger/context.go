@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, read from clients and echoed in responses
+const RequestIDHeader = "X-Request-ID"
+
+// contextKey is the type of the context keys of the package
+type contextKey int
+
+const (
+	fieldsContextKey contextKey = iota
+	requestIDContextKey
+)
+
+// validRequestID matches the inbound request IDs that are honored, others are replaced
+// so clients can't inject arbitrary text into logs and responses
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// WithFields returns a context whose logger adds the fields to every message
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	existing, _ := ctx.Value(fieldsContextKey).([]zap.Field)
+	merged := make([]zap.Field, 0, len(existing)+len(fields))
+	merged = append(merged, existing...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, fieldsContextKey, merged)
+}
+
+// Fields returns the fields added to the logger of the context.
+// The slice is clipped so appending to it never changes the context.
+func Fields(ctx context.Context) []zap.Field {
+	fields, _ := ctx.Value(fieldsContextKey).([]zap.Field)
+	return slices.Clip(fields)
+}
+
+// FromContext returns the logger of a request: the global logger with the fields of the context,
+// such as the request ID, user ID and route
+func FromContext(ctx context.Context) *zap.Logger {
+	fields := Fields(ctx)
+	if len(fields) == 0 {
+		return zap.L()
+	}
+	return zap.L().With(fields...)
+}
+
+// RequestIDFromContext returns the ID of the request of the context, empty if none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// WithRequestID returns a context carrying the request ID, which its logger adds to every message
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDContextKey, requestID)
+	return WithFields(ctx, zap.String("request_id", requestID))
+}
+
+// RequestID is middleware giving every request an ID, taken from the X-Request-ID header when the client
+// sends a valid one and generated otherwise. The ID is echoed in the X-Request-ID response header
+// and added to the logger of the request context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// HTTPError replies to the request with the error message and HTTP code like http.Error,
+// appending the request ID to the message so clients can report it
+func HTTPError(w http.ResponseWriter, r *http.Request, message string, code int) {
+	if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+		message += " (request ID " + requestID + ")"
+	}
+	http.Error(w, message, code)
+}
+
+// withRoute adds the chi route pattern of the request, once routed, to the logger of its context
+func withRoute(r *http.Request) *http.Request {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.RoutePattern() == "" {
+		return r
+	}
+	return r.WithContext(WithFields(r.Context(), zap.String("route", rctx.RoutePattern())))
+}
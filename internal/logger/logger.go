@@ -2,76 +2,32 @@ package logger
 
 import (
 	"net/http"
-	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-type (
-	// responseData holds HTTP response information for logging
-	responseData struct {
-		status int
-		size   int
-	}
-
-	// loggingResponseWriter wraps http.ResponseWriter to capture response data for logging
-	loggingResponseWriter struct {
-		http.ResponseWriter
-		responseData *responseData
-	}
-)
-
-// Write captures the size of the response data being written
-func (r *loggingResponseWriter) Write(b []byte) (int, error) {
-	size, err := r.ResponseWriter.Write(b)
-	r.responseData.size += size
-	return size, err
-}
-
-// WriteHeader captures the status code of the response
-func (r *loggingResponseWriter) WriteHeader(statusCode int) {
-	if r.responseData.status != 0 {
-		return
-	}
-	r.ResponseWriter.WriteHeader(statusCode)
-	r.responseData.status = statusCode
-}
+// level is the minimal level of logged messages, shared by all loggers built by Initialize
+// so it can be changed while the application runs
+var level = zap.NewAtomicLevel()
 
-// WithLogging is middleware that logs details about HTTP requests and responses
-func WithLogging(h http.HandlerFunc, log *zap.SugaredLogger) http.HandlerFunc {
-	logFn := func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		responseData := &responseData{
-			status: 0,
-			size:   0,
-		}
-
-		lw := loggingResponseWriter{
-			ResponseWriter: w,
-			responseData:   responseData,
-		}
-		h.ServeHTTP(&lw, r)
-
-		duration := time.Since(start)
-		log.Infoln(
-			"uri", r.RequestURI,
-			"method", r.Method,
-			"status", responseData.status,
-			"duration", duration,
-			"size", responseData.size,
-		)
+// WithRoute adds the chi route pattern of the request to the logger of its context.
+// It wraps the handlers registered on the router, once the route is known; requests themselves
+// are logged by the access log.
+func WithRoute(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h(w, withRoute(r))
 	}
-	return logFn
 }
 
-// Initialize sets up and returns a configured zap logger for the application
+// Initialize sets up and returns a configured zap logger for the application.
+// Its output is redacted, see NewRedactingCore.
 func Initialize() (*zap.SugaredLogger, error) {
 	config := zap.NewProductionConfig()
 
-	config.Level = zap.NewAtomicLevel()
+	config.Level = level
 
-	prodLogger, err := config.Build()
+	prodLogger, err := config.Build(zap.WrapCore(NewRedactingCore))
 
 	if err != nil {
 		return nil, err
@@ -79,3 +35,18 @@ func Initialize() (*zap.SugaredLogger, error) {
 	zap.ReplaceGlobals(prodLogger)
 	return prodLogger.Sugar(), nil
 }
+
+// SetLevel changes the minimal level of logged messages of the loggers built by Initialize
+func SetLevel(name string) error {
+	l, err := zapcore.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(l)
+	return nil
+}
+
+// Level returns the current minimal level of logged messages
+func Level() string {
+	return level.String()
+}
@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces secrets in log output
+const Redacted = "REDACTED"
+
+// Redaction sets how personal data is written to the logs. Secrets such as passwords
+// and tokens are always masked.
+type Redaction struct {
+	// HashUserIDs replaces user IDs by a hash, still allowing to correlate the lines of a user
+	HashUserIDs bool
+	// HashURLs replaces URLs by a hash
+	HashURLs bool
+}
+
+// redaction is the redaction applied by the loggers built by Initialize and the helpers of the package
+var redaction atomic.Pointer[Redaction]
+
+func init() {
+	redaction.Store(&Redaction{})
+}
+
+// SetRedaction changes how personal data is written to the logs
+func SetRedaction(r Redaction) {
+	redaction.Store(&r)
+}
+
+// Field keys whose values are user IDs or URLs, hashed if enabled
+var (
+	userIDKeys = map[string]bool{"user_id": true, "userID": true, "user": true, "owner": true, "login": true}
+	urlKeys    = map[string]bool{"url": true, "long_url": true, "longURL": true, "original_url": true, "uri": true, "referrer": true}
+)
+
+var (
+	// urlCredentialsPattern matches the password of the user information of a URL, such as a DSN
+	urlCredentialsPattern = regexp.MustCompile(`(\b[A-Za-z][A-Za-z0-9+.-]*://[^:/@\s]*:)[^@\s]*@`)
+	// keyValuePasswordPattern matches the password of a key/value connection string
+	keyValuePasswordPattern = regexp.MustCompile(`(?i)(^|[\s,;])(password=)('[^']*'|[^\s&]+)`)
+	// querySecretPattern matches the query parameters carrying secrets
+	querySecretPattern = regexp.MustCompile(`(?i)([?&](?:access_token|refresh_token|id_token|token|password|passwd|secret|client_secret|api_key|apikey|signature|code|state)=)[^&#\s"]*`)
+)
+
+// RedactString masks the secrets found in free text: credentials of URLs and connection strings,
+// and secret query parameters
+func RedactString(s string) string {
+	if !strings.ContainsAny(s, "=@") {
+		return s
+	}
+	s = urlCredentialsPattern.ReplaceAllString(s, "${1}"+Redacted+"@")
+	s = keyValuePasswordPattern.ReplaceAllString(s, "${1}${2}"+Redacted)
+	return querySecretPattern.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// RedactURL masks the secrets of a URL, and replaces it by a hash if URLs are hashed
+func RedactURL(url string) string {
+	if url != "" && redaction.Load().HashURLs {
+		return hash(url)
+	}
+	return RedactString(url)
+}
+
+// RedactUserID replaces a user ID by a hash if user IDs are hashed
+func RedactUserID(userID string) string {
+	if userID != "" && redaction.Load().HashUserIDs {
+		return hash(userID)
+	}
+	return userID
+}
+
+// hash returns a short stable digest of a value, so log lines can still be correlated
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// redactField returns the field with its value redacted according to its key
+func redactField(f zapcore.Field) zapcore.Field {
+	switch f.Type {
+	case zapcore.StringType:
+		switch {
+		case userIDKeys[f.Key]:
+			f.String = RedactUserID(f.String)
+		case urlKeys[f.Key]:
+			f.String = RedactURL(f.String)
+		default:
+			f.String = RedactString(f.String)
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			if message := err.Error(); RedactString(message) != message {
+				return zap.String(f.Key, RedactString(message))
+			}
+		}
+	}
+	return f
+}
+
+// redactFields redacts the fields, copying them so the caller's slice is left untouched
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		redacted[i] = redactField(f)
+	}
+	return redacted
+}
+
+// redactingCore masks secrets and personal data in the messages and fields written by a core
+type redactingCore struct {
+	zapcore.Core
+}
+
+// NewRedactingCore wraps a core so that everything it writes is redacted, see RedactString and Redaction
+func NewRedactingCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core}
+}
+
+// With implements zapcore.Core
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+// Check implements zapcore.Core, registering the redacting core so Write goes through it
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write implements zapcore.Core
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = RedactString(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// unmatchedRoute labels the requests no route matched, so unknown paths don't create series
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = NewCounterVec(
+		"shortener_http_requests_total",
+		"Number of HTTP requests by method, route pattern and status class.",
+		"method", "route", "status",
+	)
+	httpDuration = NewHistogramVec(
+		"shortener_http_request_duration_seconds",
+		"Latency of HTTP requests by method, route pattern and status class.",
+		DefBuckets,
+		"method", "route", "status",
+	)
+)
+
+// statusRecorder captures the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader captures the first status code written
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records the implicit 200 status of a response written without WriteHeader
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware counts HTTP requests and measures their latency by method, chi route pattern
+// and status class. It must be installed on the chi router, whose routing sets the pattern.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		class := strconv.Itoa(status/100) + "xx"
+
+		httpRequests.Inc(r.Method, route, class)
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, route, class)
+	})
+}
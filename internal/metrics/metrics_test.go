@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Number of requests.", "route", "status")
+	errors := r.NewCounterVec("errors_total", "Number of errors.")
+	latency := r.NewHistogramVec("latency_seconds", "Request latency.", []float64{1, 0.1}, "route")
+	r.NewGaugeFunc("queue_depth", "Queued items.", func() float64 { return 3 })
+
+	requests.Inc("/{id}", "3xx")
+	requests.Add(2, "/api/shorten", "2xx")
+	requests.Inc(`/"quoted"\`, "4xx")
+	latency.Observe(0.05, "/{id}")
+	latency.Observe(0.5, "/{id}")
+	latency.Observe(2, "/{id}")
+
+	var out strings.Builder
+	require.NoError(t, r.WriteText(&out))
+
+	want := `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{route="/\"quoted\"\\",status="4xx"} 1
+requests_total{route="/api/shorten",status="2xx"} 2
+requests_total{route="/{id}",status="3xx"} 1
+# HELP errors_total Number of errors.
+# TYPE errors_total counter
+errors_total 0
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/{id}",le="0.1"} 1
+latency_seconds_bucket{route="/{id}",le="1"} 2
+latency_seconds_bucket{route="/{id}",le="+Inf"} 3
+latency_seconds_sum{route="/{id}"} 2.55
+latency_seconds_count{route="/{id}"} 3
+# HELP queue_depth Queued items.
+# TYPE queue_depth gauge
+queue_depth 3
+`
+	assert.Equal(t, want, out.String())
+	assert.Equal(t, float64(2), requests.Value("/api/shorten", "2xx"))
+	assert.Equal(t, uint64(3), latency.Count("/{id}"))
+	errors.Inc()
+	assert.Equal(t, float64(1), errors.Value())
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounterVec("requests_total", "Number of requests.", "route")
+
+	assert.Panics(t, func() { r.NewCounterVec("requests_total", "Again.") }, "duplicate name")
+	assert.Panics(t, func() {
+		r.NewHistogramVec("latency", "Clashes with a histogram series.", nil)
+		r.NewCounterVec("latency_count", "Clash.")
+	}, "name of a histogram series")
+	assert.Panics(t, func() { counter.Inc() }, "missing label value")
+	assert.Panics(t, func() { counter.Add(-1, "/") }, "decreasing counter")
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	r := NewRegistry()
+	RegisterRuntime(r)
+
+	w := httptest.NewRecorder()
+	r.Handler()(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	for _, name := range []string{"go_goroutines", "go_memstats_alloc_bytes", "go_gc_cycles_total", "process_uptime_seconds"} {
+		assert.Contains(t, w.Body.String(), "# TYPE "+name+" ")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Post("/api/shorten", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("created"))
+	})
+
+	before := map[string]float64{
+		"redirect":  httpRequests.Value(http.MethodGet, "/{id}", "3xx"),
+		"implicit":  httpRequests.Value(http.MethodPost, "/api/shorten", "2xx"),
+		"unmatched": httpRequests.Value(http.MethodGet, unmatchedRoute, "4xx"),
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/abc123", nil),
+		httptest.NewRequest(http.MethodGet, "/def456", nil),
+		httptest.NewRequest(http.MethodPost, "/api/shorten", nil),
+		httptest.NewRequest(http.MethodGet, "/not/routed", nil),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, before["redirect"]+2, httpRequests.Value(http.MethodGet, "/{id}", "3xx"), "requests are grouped by route pattern")
+	assert.Equal(t, before["implicit"]+1, httpRequests.Value(http.MethodPost, "/api/shorten", "2xx"))
+	assert.Equal(t, before["unmatched"]+1, httpRequests.Value(http.MethodGet, unmatchedRoute, "4xx"))
+	assert.NotZero(t, httpDuration.Count(http.MethodGet, "/{id}", "3xx"))
+}
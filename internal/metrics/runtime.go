@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"bufio"
+	"runtime"
+	"time"
+)
+
+// runtimeCollector reports Go runtime statistics, reading them once per scrape
+type runtimeCollector struct {
+	start time.Time
+}
+
+// RegisterRuntime registers the Go runtime statistics on a registry: goroutines, memory,
+// garbage collections and uptime
+func RegisterRuntime(r *Registry) {
+	r.register(&runtimeCollector{start: time.Now()})
+}
+
+// runtimeMetrics describes the metrics of the runtime collector, in the order they are written
+var runtimeMetrics = []desc{
+	{name: "go_goroutines", help: "Number of goroutines that currently exist.", kind: "gauge"},
+	{name: "go_threads", help: "Number of OS threads created.", kind: "gauge"},
+	{name: "go_memstats_alloc_bytes", help: "Number of bytes allocated and still in use.", kind: "gauge"},
+	{name: "go_memstats_alloc_bytes_total", help: "Total number of bytes allocated, even if freed.", kind: "counter"},
+	{name: "go_memstats_heap_objects", help: "Number of allocated objects.", kind: "gauge"},
+	{name: "go_memstats_heap_inuse_bytes", help: "Number of heap bytes that are in use.", kind: "gauge"},
+	{name: "go_memstats_sys_bytes", help: "Number of bytes obtained from system.", kind: "gauge"},
+	{name: "go_gc_cycles_total", help: "Number of completed GC cycles.", kind: "counter"},
+	{name: "go_gc_pause_seconds_total", help: "Total time spent in GC stop-the-world pauses.", kind: "counter"},
+	{name: "process_uptime_seconds", help: "Number of seconds since the metrics were registered.", kind: "gauge"},
+}
+
+// names implements collector
+func (c *runtimeCollector) names() []string {
+	names := make([]string, len(runtimeMetrics))
+	for i, d := range runtimeMetrics {
+		names[i] = d.name
+	}
+	return names
+}
+
+// write implements collector
+func (c *runtimeCollector) write(w *bufio.Writer) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	threads, _ := runtime.ThreadCreateProfile(nil)
+
+	values := []float64{
+		float64(runtime.NumGoroutine()),
+		float64(threads),
+		float64(mem.Alloc),
+		float64(mem.TotalAlloc),
+		float64(mem.HeapObjects),
+		float64(mem.HeapInuse),
+		float64(mem.Sys),
+		float64(mem.NumGC),
+		time.Duration(mem.PauseTotalNs).Seconds(),
+		time.Since(c.start).Seconds(),
+	}
+	for i, d := range runtimeMetrics {
+		d.writeHeader(w)
+		writeSample(w, d.name, nil, nil, "", "", values[i])
+	}
+}
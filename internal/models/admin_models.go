@@ -0,0 +1,46 @@
+package models
+
+//go:generate easyjson -all admin_models.go
+
+// AdminURL represents a shortened URL with its full state in admin API responses
+//
+//easyjson:json
+type AdminURL struct {
+	Token       string `json:"token"`
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	UserID      string `json:"user_id"`
+	IsDeleted   bool   `json:"is_deleted"`
+	IsDisabled  bool   `json:"is_disabled"`
+}
+
+// AdminURLList is a page of URLs returned by the admin API
+//
+//easyjson:json
+type AdminURLList struct {
+	Total  int        `json:"total"`
+	Offset int        `json:"offset"`
+	Limit  int        `json:"limit"`
+	Items  []AdminURL `json:"items"`
+}
+
+// OwnerRequest represents a request to transfer ownership of a URL
+//
+//easyjson:json
+type OwnerRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// ConfigReloadResponse reports the outcome of a configuration reload
+//
+//easyjson:json
+type ConfigReloadResponse struct {
+	Warnings []string `json:"warnings"`
+}
+
+// LogLevel is the minimal level of logged messages, read and changed through the admin API
+//
+//easyjson:json
+type LogLevel struct {
+	Level string `json:"level"`
+}
@@ -0,0 +1,30 @@
+package models
+
+//go:generate easyjson -all health_models.go
+
+// Health statuses of the service and its components
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	HealthStatusFailing  = "failing"
+	HealthStatusStarting = "starting"
+	HealthStatusStopping = "stopping"
+)
+
+// HealthComponent is the outcome of the health check of a component the service depends on
+//
+//easyjson:json
+type HealthComponent struct {
+	Name          string  `json:"name"`
+	Status        string  `json:"status"`
+	LatencyMillis float64 `json:"latency_ms"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// HealthResponse reports the health of the service, returned by the liveness and readiness checks
+//
+//easyjson:json
+type HealthResponse struct {
+	Status     string            `json:"status"`
+	Components []HealthComponent `json:"components"`
+}
@@ -0,0 +1,211 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package models
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson92c373e5DecodeGithubComPcristinUrlshortenerInternalModels(in *jlexer.Lexer, out *HealthResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "status":
+			out.Status = string(in.String())
+		case "components":
+			if in.IsNull() {
+				in.Skip()
+				out.Components = nil
+			} else {
+				in.Delim('[')
+				if out.Components == nil {
+					if !in.IsDelim(']') {
+						out.Components = make([]HealthComponent, 0, 1)
+					} else {
+						out.Components = []HealthComponent{}
+					}
+				} else {
+					out.Components = (out.Components)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 HealthComponent
+					(v1).UnmarshalEasyJSON(in)
+					out.Components = append(out.Components, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson92c373e5EncodeGithubComPcristinUrlshortenerInternalModels(out *jwriter.Writer, in HealthResponse) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Status))
+	}
+	{
+		const prefix string = ",\"components\":"
+		out.RawString(prefix)
+		if in.Components == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v2, v3 := range in.Components {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				(v3).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HealthResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson92c373e5EncodeGithubComPcristinUrlshortenerInternalModels(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HealthResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson92c373e5EncodeGithubComPcristinUrlshortenerInternalModels(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HealthResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson92c373e5DecodeGithubComPcristinUrlshortenerInternalModels(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HealthResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson92c373e5DecodeGithubComPcristinUrlshortenerInternalModels(l, v)
+}
+func easyjson92c373e5DecodeGithubComPcristinUrlshortenerInternalModels1(in *jlexer.Lexer, out *HealthComponent) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "name":
+			out.Name = string(in.String())
+		case "status":
+			out.Status = string(in.String())
+		case "latency_ms":
+			out.LatencyMillis = float64(in.Float64())
+		case "error":
+			out.Error = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson92c373e5EncodeGithubComPcristinUrlshortenerInternalModels1(out *jwriter.Writer, in HealthComponent) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"name\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Name))
+	}
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix)
+		out.String(string(in.Status))
+	}
+	{
+		const prefix string = ",\"latency_ms\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.LatencyMillis))
+	}
+	if in.Error != "" {
+		const prefix string = ",\"error\":"
+		out.RawString(prefix)
+		out.String(string(in.Error))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HealthComponent) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson92c373e5EncodeGithubComPcristinUrlshortenerInternalModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HealthComponent) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson92c373e5EncodeGithubComPcristinUrlshortenerInternalModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HealthComponent) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson92c373e5DecodeGithubComPcristinUrlshortenerInternalModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HealthComponent) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson92c373e5DecodeGithubComPcristinUrlshortenerInternalModels1(l, v)
+}
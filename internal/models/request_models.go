@@ -24,12 +24,16 @@ type BatchRequestItem struct {
 	OriginalURL   string `json:"original_url"`
 }
 
-// BatchResponseItem represents a single result in a batch shortening response
+// BatchResponseItem represents a single result in a batch shortening response.
+// Status is "created", "exists", "invalid" or "error"; the short URL is only set for the first two,
+// and the error only for the last two.
 //
 //easyjson:json
 type BatchResponseItem struct {
 	CorrelationID string `json:"correlation_id"`
-	ShortURL      string `json:"short_url"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
 }
 
 // BatchRequest is a collection of URLs to be shortened in a single request
@@ -41,3 +45,32 @@ type BatchRequest []BatchRequestItem
 //
 //easyjson:json
 type BatchResponse []BatchResponseItem
+
+// ImportResult is the outcome of one row of a bulk import, streamed back as a line of NDJSON.
+// It carries either the short URL or the error of the row. A final result with only an error
+// and no line reports why the import stopped early.
+//
+//easyjson:json
+type ImportResult struct {
+	Line          int    `json:"line,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Existing      bool   `json:"existing,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ExportedURL is a URL of a user with its metadata, as exported to CSV, NDJSON or JSON.
+// Times are in RFC 3339 format, empty for URLs stored before they were recorded.
+//
+//easyjson:json
+type ExportedURL struct {
+	ShortURL     string `json:"short_url"`
+	OriginalURL  string `json:"original_url"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+	DeletedAt    string `json:"deleted_at,omitempty"`
+	IsDeleted    bool   `json:"is_deleted"`
+	IsDisabled   bool   `json:"is_disabled"`
+	Source       string `json:"source,omitempty"`
+	ClientIPHash string `json:"client_ip_hash,omitempty"`
+}
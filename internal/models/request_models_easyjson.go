@@ -149,7 +149,7 @@ func (v *Request) UnmarshalJSON(data []byte) error {
 func (v *Request) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels1(l, v)
 }
-func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(in *jlexer.Lexer, out *BatchResponseItem) {
+func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(in *jlexer.Lexer, out *ImportResult) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -168,10 +168,16 @@ func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(in *jlex
 			continue
 		}
 		switch key {
+		case "line":
+			out.Line = int(in.Int())
 		case "correlation_id":
 			out.CorrelationID = string(in.String())
 		case "short_url":
 			out.ShortURL = string(in.String())
+		case "existing":
+			out.Existing = bool(in.Bool())
+		case "error":
+			out.Error = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -182,7 +188,242 @@ func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(in *jlex
 		in.Consumed()
 	}
 }
-func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels2(out *jwriter.Writer, in BatchResponseItem) {
+func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels2(out *jwriter.Writer, in ImportResult) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.Line != 0 {
+		const prefix string = ",\"line\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.Int(int(in.Line))
+	}
+	if in.CorrelationID != "" {
+		const prefix string = ",\"correlation_id\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.CorrelationID))
+	}
+	if in.ShortURL != "" {
+		const prefix string = ",\"short_url\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.ShortURL))
+	}
+	if in.Existing {
+		const prefix string = ",\"existing\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Bool(bool(in.Existing))
+	}
+	if in.Error != "" {
+		const prefix string = ",\"error\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Error))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ImportResult) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ImportResult) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ImportResult) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ImportResult) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(l, v)
+}
+func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(in *jlexer.Lexer, out *ExportedURL) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "short_url":
+			out.ShortURL = string(in.String())
+		case "original_url":
+			out.OriginalURL = string(in.String())
+		case "created_at":
+			out.CreatedAt = string(in.String())
+		case "updated_at":
+			out.UpdatedAt = string(in.String())
+		case "deleted_at":
+			out.DeletedAt = string(in.String())
+		case "is_deleted":
+			out.IsDeleted = bool(in.Bool())
+		case "is_disabled":
+			out.IsDisabled = bool(in.Bool())
+		case "source":
+			out.Source = string(in.String())
+		case "client_ip_hash":
+			out.ClientIPHash = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels3(out *jwriter.Writer, in ExportedURL) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"short_url\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.ShortURL))
+	}
+	{
+		const prefix string = ",\"original_url\":"
+		out.RawString(prefix)
+		out.String(string(in.OriginalURL))
+	}
+	if in.CreatedAt != "" {
+		const prefix string = ",\"created_at\":"
+		out.RawString(prefix)
+		out.String(string(in.CreatedAt))
+	}
+	if in.UpdatedAt != "" {
+		const prefix string = ",\"updated_at\":"
+		out.RawString(prefix)
+		out.String(string(in.UpdatedAt))
+	}
+	if in.DeletedAt != "" {
+		const prefix string = ",\"deleted_at\":"
+		out.RawString(prefix)
+		out.String(string(in.DeletedAt))
+	}
+	{
+		const prefix string = ",\"is_deleted\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.IsDeleted))
+	}
+	{
+		const prefix string = ",\"is_disabled\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.IsDisabled))
+	}
+	if in.Source != "" {
+		const prefix string = ",\"source\":"
+		out.RawString(prefix)
+		out.String(string(in.Source))
+	}
+	if in.ClientIPHash != "" {
+		const prefix string = ",\"client_ip_hash\":"
+		out.RawString(prefix)
+		out.String(string(in.ClientIPHash))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ExportedURL) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ExportedURL) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ExportedURL) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ExportedURL) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(l, v)
+}
+func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels4(in *jlexer.Lexer, out *BatchResponseItem) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "correlation_id":
+			out.CorrelationID = string(in.String())
+		case "short_url":
+			out.ShortURL = string(in.String())
+		case "status":
+			out.Status = string(in.String())
+		case "error":
+			out.Error = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels4(out *jwriter.Writer, in BatchResponseItem) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -191,38 +432,48 @@ func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels2(out *jwr
 		out.RawString(prefix[1:])
 		out.String(string(in.CorrelationID))
 	}
-	{
+	if in.ShortURL != "" {
 		const prefix string = ",\"short_url\":"
 		out.RawString(prefix)
 		out.String(string(in.ShortURL))
 	}
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix)
+		out.String(string(in.Status))
+	}
+	if in.Error != "" {
+		const prefix string = ",\"error\":"
+		out.RawString(prefix)
+		out.String(string(in.Error))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v BatchResponseItem) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels2(&w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v BatchResponseItem) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels2(w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *BatchResponseItem) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(&r, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *BatchResponseItem) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels2(l, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels4(l, v)
 }
-func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(in *jlexer.Lexer, out *BatchResponse) {
+func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels5(in *jlexer.Lexer, out *BatchResponse) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -231,7 +482,7 @@ func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(in *jlex
 		in.Delim('[')
 		if *out == nil {
 			if !in.IsDelim(']') {
-				*out = make(BatchResponse, 0, 2)
+				*out = make(BatchResponse, 0, 1)
 			} else {
 				*out = BatchResponse{}
 			}
@@ -250,7 +501,7 @@ func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(in *jlex
 		in.Consumed()
 	}
 }
-func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels3(out *jwriter.Writer, in BatchResponse) {
+func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels5(out *jwriter.Writer, in BatchResponse) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
@@ -268,27 +519,27 @@ func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels3(out *jwr
 // MarshalJSON supports json.Marshaler interface
 func (v BatchResponse) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels3(&w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels5(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v BatchResponse) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels3(w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels5(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *BatchResponse) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(&r, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels5(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *BatchResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels3(l, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels5(l, v)
 }
-func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels4(in *jlexer.Lexer, out *BatchRequestItem) {
+func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels6(in *jlexer.Lexer, out *BatchRequestItem) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -321,7 +572,7 @@ func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels4(in *jlex
 		in.Consumed()
 	}
 }
-func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels4(out *jwriter.Writer, in BatchRequestItem) {
+func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels6(out *jwriter.Writer, in BatchRequestItem) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -341,27 +592,27 @@ func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels4(out *jwr
 // MarshalJSON supports json.Marshaler interface
 func (v BatchRequestItem) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels4(&w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels6(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v BatchRequestItem) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels4(w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels6(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *BatchRequestItem) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels4(&r, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels6(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *BatchRequestItem) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels4(l, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels6(l, v)
 }
-func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels5(in *jlexer.Lexer, out *BatchRequest) {
+func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels7(in *jlexer.Lexer, out *BatchRequest) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -389,7 +640,7 @@ func easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels5(in *jlex
 		in.Consumed()
 	}
 }
-func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels5(out *jwriter.Writer, in BatchRequest) {
+func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels7(out *jwriter.Writer, in BatchRequest) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
@@ -407,23 +658,23 @@ func easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels5(out *jwr
 // MarshalJSON supports json.Marshaler interface
 func (v BatchRequest) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels5(&w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels7(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v BatchRequest) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels5(w, v)
+	easyjson7df0efccEncodeGithubComPcristinUrlshortenerInternalModels7(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *BatchRequest) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels5(&r, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels7(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *BatchRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels5(l, v)
+	easyjson7df0efccDecodeGithubComPcristinUrlshortenerInternalModels7(l, v)
 }
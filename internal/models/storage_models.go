@@ -1,14 +1,47 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // URLStorageNode represents a URL entry stored in the system.
 // It contains information about the original and shortened URLs,
-// the user who created the shortened URL, and the deletion status.
+// the user who created the shortened URL, the deletion and moderation status,
+// and the metadata recorded when the URL was shortened and changed.
+// Timestamps and creator metadata are zero for URLs stored before they were recorded.
 type URLStorageNode struct {
-	UUID        uuid.UUID `json:"uuid"`         // Unique identifier for the URL node
-	ShortURL    string    `json:"short_url"`    // The shortened URL or token
-	OriginalURL string    `json:"original_url"` // The original, full-length URL
-	UserID      string    `json:"user_id"`      // ID of the user who created this URL
-	IsDeleted   bool      `json:"is_deleted"`   // Whether this URL has been marked as deleted
+	UUID         uuid.UUID `json:"uuid"`                     // Unique identifier for the URL node
+	ShortURL     string    `json:"short_url"`                // The shortened URL or token
+	OriginalURL  string    `json:"original_url"`             // The original, full-length URL
+	UserID       string    `json:"user_id"`                  // ID of the user who created this URL
+	IsDeleted    bool      `json:"is_deleted"`               // Whether this URL has been marked as deleted
+	IsDisabled   bool      `json:"is_disabled"`              // Whether this URL has been disabled by an administrator
+	CreatedAt    time.Time `json:"created_at"`               // Time the URL was shortened
+	UpdatedAt    time.Time `json:"updated_at"`               // Time the URL was last changed: shortened, deleted, disabled or reassigned
+	DeletedAt    time.Time `json:"deleted_at"`               // Time the URL was marked as deleted, zero if it wasn't
+	Source       string    `json:"source,omitempty"`         // API the URL was shortened through, one of the Source constants
+	ClientIPHash string    `json:"client_ip_hash,omitempty"` // Keyed hash of the IP address of the client that shortened the URL
+}
+
+// Sources of URLs, the API they were shortened through
+const (
+	SourceText   = "text"   // POST / with the URL as plain text
+	SourceJSON   = "json"   // POST /api/shorten
+	SourceBatch  = "batch"  // POST /api/shorten/batch
+	SourceImport = "import" // POST /api/shorten/import
+	SourceRPC    = "rpc"    // The RPC API
+)
+
+// Stats holds service-wide statistics of the stored URLs
+//
+//easyjson:json
+type Stats struct {
+	URLs         int `json:"urls"`          // Total number of shortened URLs, including deleted ones
+	Users        int `json:"users"`         // Number of registered users and of anonymous users owning at least one URL
+	DeletedURLs  int `json:"deleted_urls"`  // Number of URLs marked as deleted
+	CreatedToday int `json:"created_today"` // Number of URLs shortened since midnight (server time)
+
+	PendingDeletions int `json:"pending_deletions"` // Number of deletion requests queued and not written yet
 }
@@ -48,6 +48,24 @@ func easyjson91813e18DecodeGithubComPcristinUrlshortenerInternalModels(in *jlexe
 			out.UserID = string(in.String())
 		case "is_deleted":
 			out.IsDeleted = bool(in.Bool())
+		case "is_disabled":
+			out.IsDisabled = bool(in.Bool())
+		case "created_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.CreatedAt).UnmarshalJSON(data))
+			}
+		case "updated_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.UpdatedAt).UnmarshalJSON(data))
+			}
+		case "deleted_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.DeletedAt).UnmarshalJSON(data))
+			}
+		case "source":
+			out.Source = string(in.String())
+		case "client_ip_hash":
+			out.ClientIPHash = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -87,6 +105,36 @@ func easyjson91813e18EncodeGithubComPcristinUrlshortenerInternalModels(out *jwri
 		out.RawString(prefix)
 		out.Bool(bool(in.IsDeleted))
 	}
+	{
+		const prefix string = ",\"is_disabled\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.IsDisabled))
+	}
+	{
+		const prefix string = ",\"created_at\":"
+		out.RawString(prefix)
+		out.Raw((in.CreatedAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"updated_at\":"
+		out.RawString(prefix)
+		out.Raw((in.UpdatedAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"deleted_at\":"
+		out.RawString(prefix)
+		out.Raw((in.DeletedAt).MarshalJSON())
+	}
+	if in.Source != "" {
+		const prefix string = ",\"source\":"
+		out.RawString(prefix)
+		out.String(string(in.Source))
+	}
+	if in.ClientIPHash != "" {
+		const prefix string = ",\"client_ip_hash\":"
+		out.RawString(prefix)
+		out.String(string(in.ClientIPHash))
+	}
 	out.RawByte('}')
 }
 
@@ -113,3 +161,97 @@ func (v *URLStorageNode) UnmarshalJSON(data []byte) error {
 func (v *URLStorageNode) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson91813e18DecodeGithubComPcristinUrlshortenerInternalModels(l, v)
 }
+func easyjson91813e18DecodeGithubComPcristinUrlshortenerInternalModels1(in *jlexer.Lexer, out *Stats) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "urls":
+			out.URLs = int(in.Int())
+		case "users":
+			out.Users = int(in.Int())
+		case "deleted_urls":
+			out.DeletedURLs = int(in.Int())
+		case "created_today":
+			out.CreatedToday = int(in.Int())
+		case "pending_deletions":
+			out.PendingDeletions = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson91813e18EncodeGithubComPcristinUrlshortenerInternalModels1(out *jwriter.Writer, in Stats) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"urls\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.URLs))
+	}
+	{
+		const prefix string = ",\"users\":"
+		out.RawString(prefix)
+		out.Int(int(in.Users))
+	}
+	{
+		const prefix string = ",\"deleted_urls\":"
+		out.RawString(prefix)
+		out.Int(int(in.DeletedURLs))
+	}
+	{
+		const prefix string = ",\"created_today\":"
+		out.RawString(prefix)
+		out.Int(int(in.CreatedToday))
+	}
+	{
+		const prefix string = ",\"pending_deletions\":"
+		out.RawString(prefix)
+		out.Int(int(in.PendingDeletions))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Stats) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson91813e18EncodeGithubComPcristinUrlshortenerInternalModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Stats) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson91813e18EncodeGithubComPcristinUrlshortenerInternalModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Stats) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson91813e18DecodeGithubComPcristinUrlshortenerInternalModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Stats) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson91813e18DecodeGithubComPcristinUrlshortenerInternalModels1(l, v)
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+//go:generate easyjson -all user_models.go
+
+// User represents a registered account of the URL shortener service.
+// The ID is used as the user identity in cookies and as the owner of shortened URLs.
+//
+//easyjson:json
+type User struct {
+	ID           string    `json:"id"`            // Identifier used as the owner of shortened URLs
+	Login        string    `json:"login"`         // Unique login chosen by the user
+	PasswordHash string    `json:"password_hash"` // Salted bcrypt hash of the password
+	CreatedAt    time.Time `json:"created_at"`    // Time of registration
+}
+
+// Credentials represents a request to register a new account or to log in
+//
+//easyjson:json
+type Credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
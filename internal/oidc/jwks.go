@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksCacheTTL is how long fetched signing keys are trusted before being refreshed
+	jwksCacheTTL = time.Hour
+	// jwksMinRefreshInterval limits refetching on unknown key IDs to protect the provider
+	jwksMinRefreshInterval = 10 * time.Second
+)
+
+// jsonWebKey holds the fields of an RSA JSON Web Key
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// jsonWebKeySet is the document served at the provider jwks_uri
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// keySet caches the signing keys of the provider.
+// Keys are refreshed when the cache expires or when a token references an unknown key ID,
+// which handles key rotation without refetching on every verification.
+type keySet struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	uri       string
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// newKeySet creates an empty key set cache
+func newKeySet(client *http.Client) *keySet {
+	return &keySet{
+		client: client,
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+}
+
+// setURI sets the location of the key set discovered from the provider metadata
+func (ks *keySet) setURI(uri string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.uri = uri
+}
+
+// key returns the public key with the given key ID, refreshing the cache if needed
+func (ks *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	expired := time.Since(ks.fetchedAt) > jwksCacheTTL
+	if key, ok := ks.lookup(kid); ok && !expired {
+		return key, nil
+	}
+
+	if expired || time.Since(ks.fetchedAt) > jwksMinRefreshInterval {
+		if err := ks.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+}
+
+// lookup finds a cached key; an empty key ID matches the only cached key
+func (ks *keySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the key set from the provider, must be called with the mutex held
+func (ks *keySet) refresh(ctx context.Context) error {
+	if ks.uri == "" {
+		return errors.New("oidc: jwks uri not discovered")
+	}
+
+	var set jsonWebKeySet
+	if err := getJSON(ctx, ks.client, ks.uri, &set); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("oidc jwks: key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+	return nil
+}
+
+// publicKey decodes the RSA public key from the JWK modulus and exponent
+func (jwk jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
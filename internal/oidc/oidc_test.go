@@ -0,0 +1,158 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/oidc"
+	"github.com/pcristin/urlshortener/internal/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID    = "shortener"
+	testRedirectURL = "http://localhost:8080/api/user/oidc/callback"
+)
+
+// login runs the authorization code flow against the mock provider
+func login(t *testing.T, provider *oidc.Provider, server *oidctest.Server, verifier, nonce string) (oidc.Identity, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-value", nonce, verifier)
+	require.NoError(t, err)
+
+	callback, err := server.Authorize(authURL)
+	require.NoError(t, err)
+
+	callbackURL, err := url.Parse(callback)
+	require.NoError(t, err)
+	assert.Equal(t, "state-value", callbackURL.Query().Get("state"))
+
+	return provider.Exchange(ctx, callbackURL.Query().Get("code"), verifier, nonce)
+}
+
+func TestProviderFlow(t *testing.T) {
+	server := oidctest.NewServer("subject-1")
+	defer server.Close()
+	server.SetSubject("subject-1", "user@example.com")
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:      server.Issuer(),
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+		Scopes:      []string{"email"},
+	})
+
+	verifier, err := oidc.GenerateVerifier()
+	require.NoError(t, err)
+
+	identity, err := login(t, provider, server, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, server.Issuer(), identity.Issuer)
+	assert.Equal(t, "subject-1", identity.Subject)
+	assert.Equal(t, "user@example.com", identity.Email)
+
+	// A second login reuses the cached signing keys
+	_, err = login(t, provider, server, verifier, "nonce-2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), server.JWKSRequests())
+}
+
+func TestProviderKeyRotation(t *testing.T) {
+	server := oidctest.NewServer("subject-1")
+	defer server.Close()
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:      server.Issuer(),
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	})
+
+	_, err := login(t, provider, server, "verifier-verifier-verifier-verifier-01", "nonce")
+	require.NoError(t, err)
+
+	// Tokens signed by an unknown key trigger a refetch, rate limited to protect the provider
+	server.RotateKey()
+	_, err = login(t, provider, server, "verifier-verifier-verifier-verifier-01", "nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+	assert.Equal(t, int64(1), server.JWKSRequests())
+}
+
+func TestProviderRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		hook  func(claims map[string]any)
+		nonce string
+	}{
+		{
+			name: "wrong audience",
+			hook: func(claims map[string]any) { claims["aud"] = "another-client" },
+		},
+		{
+			name: "expired",
+			hook: func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+		},
+		{
+			name: "wrong issuer",
+			hook: func(claims map[string]any) { claims["iss"] = "https://evil.example.com" },
+		},
+		{
+			name: "missing subject",
+			hook: func(claims map[string]any) { delete(claims, "sub") },
+		},
+		{
+			name: "nonce mismatch",
+			hook: func(claims map[string]any) { claims["nonce"] = "replayed" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := oidctest.NewServer("subject-1")
+			defer server.Close()
+			server.ClaimsHook = tt.hook
+
+			provider := oidc.NewProvider(oidc.Config{
+				Issuer:      server.Issuer(),
+				ClientID:    testClientID,
+				RedirectURL: testRedirectURL,
+			})
+
+			_, err := login(t, provider, server, "verifier-verifier-verifier-verifier-01", "nonce")
+			assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+		})
+	}
+}
+
+func TestProviderRejectsWrongVerifier(t *testing.T) {
+	server := oidctest.NewServer("subject-1")
+	defer server.Close()
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:      server.Issuer(),
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	})
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "original-verifier")
+	require.NoError(t, err)
+	callback, err := server.Authorize(authURL)
+	require.NoError(t, err)
+	callbackURL, err := url.Parse(callback)
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, callbackURL.Query().Get("code"), "another-verifier", "nonce")
+	assert.Error(t, err)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// Test vector from RFC 7636, appendix B
+	assert.Equal(t,
+		"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
@@ -0,0 +1,113 @@
+// Package openapi holds the OpenAPI 3 specification of the HTTP API.
+//
+// The specification is embedded in the binary and served as is. The subset of it
+// needed to validate JSON bodies is decoded into a Document, which provides
+// a middleware checking requests and responses against it in development mode.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// ServeSpec handles GET /api/openapi.json requests with the specification
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(spec)
+}
+
+// Document is the part of the specification describing paths and bodies
+type Document struct {
+	// Paths maps the path templates, in the chi pattern syntax, to their operations by lower case method
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Components are the reusable objects referenced from operations
+type Components struct {
+	Schemas   map[string]*Schema   `json:"schemas"`
+	Responses map[string]*Response `json:"responses"`
+}
+
+// Operation describes a method on a path
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// RequestBody describes the accepted request bodies by media type
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response body by media type, or references a reusable response
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema used by the specification
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MinLength            *int               `json:"minLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+// Load decodes the embedded specification
+func Load() (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("decode OpenAPI specification: %w", err)
+	}
+	return &doc, nil
+}
+
+// Operation returns the operation documented for the method on the path template, nil if there is none
+func (d *Document) Operation(method, pattern string) *Operation {
+	return d.Paths[pattern][strings.ToLower(method)]
+}
+
+// schema follows the reference of a schema to the component it designates
+func (d *Document) schema(s *Schema) (*Schema, error) {
+	if s == nil || s.Ref == "" {
+		return s, nil
+	}
+	name, found := strings.CutPrefix(s.Ref, "#/components/schemas/")
+	if !found || d.Components.Schemas[name] == nil {
+		return nil, fmt.Errorf("unresolved schema reference %q", s.Ref)
+	}
+	return d.schema(d.Components.Schemas[name])
+}
+
+// response follows the reference of a response to the component it designates
+func (d *Document) response(r *Response) (*Response, error) {
+	if r == nil || r.Ref == "" {
+		return r, nil
+	}
+	name, found := strings.CutPrefix(r.Ref, "#/components/responses/")
+	if !found || d.Components.Responses[name] == nil {
+		return nil, fmt.Errorf("unresolved response reference %q", r.Ref)
+	}
+	return d.response(d.Components.Responses[name])
+}
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+
+	// Every reference must resolve, otherwise validation silently fails at runtime
+	for pattern, operations := range doc.Paths {
+		for method, op := range operations {
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					_, err := doc.schema(media.Schema)
+					assert.NoError(t, err, "%s %s", method, pattern)
+				}
+			}
+			for status, response := range op.Responses {
+				resolved, err := doc.response(response)
+				require.NoError(t, err, "%s %s %s", method, pattern, status)
+				for _, media := range resolved.Content {
+					_, err := doc.schema(media.Schema)
+					assert.NoError(t, err, "%s %s %s", method, pattern, status)
+				}
+			}
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		method      string
+		pattern     string
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{
+			name:        "valid shorten request",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url": "https://practicum.yandex.ru"}`,
+		},
+		{
+			name:        "content type parameters",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"url": "https://practicum.yandex.ru"}`,
+		},
+		{
+			name:        "missing property",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten",
+			contentType: "application/json",
+			body:        `{}`,
+			wantErr:     `$: missing property "url"`,
+		},
+		{
+			name:        "unknown property",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url": "https://practicum.yandex.ru", "link": "x"}`,
+			wantErr:     `$: unknown property "link"`,
+		},
+		{
+			name:        "wrong type",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url": 42}`,
+			wantErr:     "$.url: expected a string",
+		},
+		{
+			name:        "invalid JSON",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":`,
+			wantErr:     "invalid JSON",
+		},
+		{
+			name:        "empty batch",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten/batch",
+			contentType: "application/json",
+			body:        `[]`,
+			wantErr:     "$: expected at least 1 items",
+		},
+		{
+			name:        "invalid batch item",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten/batch",
+			contentType: "application/json",
+			body:        `[{"correlation_id": "1", "original_url": "https://a.example"}, {"correlation_id": "2", "original_url": ""}]`,
+			wantErr:     "$[1].original_url: expected at least 1 characters",
+		},
+		{
+			name:        "missing required body",
+			method:      http.MethodPut,
+			pattern:     "/api/admin/urls/{token}/owner",
+			contentType: "application/json",
+			wantErr:     "request body is required",
+		},
+		{
+			name:        "plain text is left to the handler",
+			method:      http.MethodPost,
+			pattern:     "/",
+			contentType: "text/plain",
+			body:        "https://practicum.yandex.ru",
+		},
+		{
+			name:    "undocumented route",
+			method:  http.MethodGet,
+			pattern: "/api/unknown",
+			wantErr: "GET /api/unknown is not documented",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doc.ValidateRequest(tt.method, tt.pattern, tt.contentType, []byte(tt.body))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		method      string
+		pattern     string
+		status      int
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{
+			name:        "valid list",
+			method:      http.MethodGet,
+			pattern:     "/api/admin/urls",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"total": 1, "offset": 0, "limit": 100, "items": [{"token": "abc", "short_url": "http://localhost/abc", "original_url": "https://a.example", "user_id": "u", "is_deleted": false, "is_disabled": false}]}`,
+		},
+		{
+			name:        "wrong item type",
+			method:      http.MethodGet,
+			pattern:     "/api/admin/urls",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"total": 1, "offset": 0, "limit": 100, "items": [{"token": "abc", "short_url": "http://localhost/abc", "original_url": "https://a.example", "user_id": "u", "is_deleted": "no", "is_disabled": false}]}`,
+			wantErr:     "$.items[0].is_deleted: expected a boolean",
+		},
+		{
+			name:        "text error response",
+			method:      http.MethodPost,
+			pattern:     "/api/shorten",
+			status:      http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			body:        "bad request",
+		},
+		{
+			name:    "undocumented status",
+			method:  http.MethodPost,
+			pattern: "/api/shorten",
+			status:  http.StatusTeapot,
+			wantErr: "status 418 is not documented",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doc.ValidateResponse(tt.method, tt.pattern, tt.status, tt.contentType, []byte(tt.body))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
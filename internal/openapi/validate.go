@@ -0,0 +1,240 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pcristin/urlshortener/internal/bodylimit"
+	"github.com/pcristin/urlshortener/internal/logger"
+)
+
+// jsonMediaType is the only media type whose bodies are validated
+const jsonMediaType = "application/json"
+
+// ValidateRequest checks a request body against the operation documented for the method
+// on the path template. Only JSON bodies are validated, other media types are left to the handlers.
+func (d *Document) ValidateRequest(method, pattern, contentType string, body []byte) error {
+	op := d.Operation(method, pattern)
+	if op == nil {
+		return fmt.Errorf("%s %s is not documented", method, pattern)
+	}
+	if op.RequestBody == nil || mediaType(contentType) != jsonMediaType {
+		return nil
+	}
+
+	media, ok := op.RequestBody.Content[jsonMediaType]
+	if !ok {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return errors.New("request body is required")
+		}
+		return nil
+	}
+	return d.validateBody(media.Schema, body)
+}
+
+// ValidateResponse checks a response body against the response documented for the status code
+func (d *Document) ValidateResponse(method, pattern string, status int, contentType string, body []byte) error {
+	op := d.Operation(method, pattern)
+	if op == nil {
+		return fmt.Errorf("%s %s is not documented", method, pattern)
+	}
+
+	response, err := d.response(op.Responses[strconv.Itoa(status)])
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	if mediaType(contentType) != jsonMediaType {
+		return nil
+	}
+
+	media, ok := response.Content[jsonMediaType]
+	if !ok {
+		return fmt.Errorf("JSON body is not documented for status %d", status)
+	}
+	return d.validateBody(media.Schema, body)
+}
+
+// validateBody decodes a JSON body and checks it against the schema
+func (d *Document) validateBody(schema *Schema, body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return d.validate(schema, value, "$")
+}
+
+// validate checks a decoded JSON value against the schema, path locating the value in the body
+func (d *Document) validate(schema *Schema, value any, path string) error {
+	schema, err := d.schema(schema)
+	if err != nil || schema == nil {
+		return err
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		for _, name := range schema.Required {
+			if _, found := object[name]; !found {
+				return fmt.Errorf("%s: missing property %q", path, name)
+			}
+		}
+		for name, property := range object {
+			propertySchema, known := schema.Properties[name]
+			if !known {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s: unknown property %q", path, name)
+				}
+				continue
+			}
+			if err := d.validate(propertySchema, property, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		if schema.MinItems != nil && len(array) < *schema.MinItems {
+			return fmt.Errorf("%s: expected at least %d items", path, *schema.MinItems)
+		}
+		for i, item := range array {
+			if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string", path)
+		}
+		if schema.MinLength != nil && len(s) < *schema.MinLength {
+			return fmt.Errorf("%s: expected at least %d characters", path, *schema.MinLength)
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected a number", path)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if schema.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return fmt.Errorf("%s: expected an integer", path)
+			}
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return fmt.Errorf("%s: expected at least %v", path, *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return fmt.Errorf("%s: expected at most %v", path, *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+	}
+	return nil
+}
+
+// mediaType returns the media type of a Content-Type header without its parameters
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mt
+}
+
+// responseRecorder keeps a copy of the response body while writing it through
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// ValidationMiddleware checks requests and responses against the specification.
+// It must wrap handlers registered on a chi router, whose route pattern identifies the operation.
+//
+// Requests with a body not matching the specification are rejected with 400 Bad Request
+// before reaching the handler. Responses are not altered: mismatches are logged, since they are
+// bugs of the server rather than of the client. Meant for development, as it buffers bodies.
+func (d *Document) ValidationMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pattern := chi.RouteContext(r.Context()).RoutePattern()
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		var limitErr *bodylimit.Error
+		switch {
+		case errors.As(err, &limitErr):
+			logger.HTTPError(w, r, "request entity too large: "+limitErr.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			logger.HTTPError(w, r, "bad request: unable to read body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err := d.ValidateRequest(r.Method, pattern, r.Header.Get("Content-Type"), body); err != nil {
+			logger.HTTPError(w, r, "bad request: request does not match the API specification: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		if err := d.ValidateResponse(r.Method, pattern, recorder.status, w.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			// The request logger carries the route and request ID
+			logger.FromContext(r.Context()).Sugar().Warnw("Response does not match the API specification",
+				"method", r.Method,
+				"status", recorder.status,
+				"error", err,
+			)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package rpcapi
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+)
+
+// Client calls the shortener RPC API on behalf of a user
+type Client struct {
+	client   *rpc.Client
+	metadata Metadata
+}
+
+// Dial connects to the RPC API at the address.
+// The metadata is sent with every call, use WithMetadata to act for another user.
+func Dial(address string, metadata Metadata) (*Client, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		client:   jsonrpc.NewClient(conn),
+		metadata: metadata,
+	}, nil
+}
+
+// WithMetadata returns a client sharing the connection but sending other metadata
+func (c *Client) WithMetadata(metadata Metadata) *Client {
+	return &Client{
+		client:   c.client,
+		metadata: metadata,
+	}
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+// Shorten shortens a URL
+func (c *Client) Shorten(url string) (ShortenReply, error) {
+	var reply ShortenReply
+	err := c.call("Shorten", &ShortenArgs{Metadata: c.metadata, URL: url}, &reply)
+	return reply, err
+}
+
+// ShortenBatch shortens several URLs
+func (c *Client) ShortenBatch(args ShortenBatchArgs) (ShortenBatchReply, error) {
+	var reply ShortenBatchReply
+	args.Metadata = c.metadata
+	err := c.call("ShortenBatch", &args, &reply)
+	return reply, err
+}
+
+// Expand returns the original URL of a token
+func (c *Client) Expand(token string) (string, error) {
+	var reply ExpandReply
+	err := c.call("Expand", &ExpandArgs{Metadata: c.metadata, Token: token}, &reply)
+	return reply.OriginalURL, err
+}
+
+// UserURLs lists the URLs shortened by the user
+func (c *Client) UserURLs() ([]UserURL, error) {
+	var reply UserURLsReply
+	err := c.call("UserURLs", &UserURLsArgs{Metadata: c.metadata}, &reply)
+	return reply.URLs, err
+}
+
+// Delete marks URLs of the user as deleted
+func (c *Client) Delete(tokens []string) error {
+	return c.call("Delete", &DeleteArgs{Metadata: c.metadata, Tokens: tokens}, &DeleteReply{})
+}
+
+// call calls a shortener method, converting server errors to *Error
+func (c *Client) call(method string, args, reply any) error {
+	err := c.client.Call(ServiceName+"."+method, args, reply)
+	if serverErr, ok := err.(rpc.ServerError); ok {
+		code := ErrorCode(serverErr)
+		message, _ := strings.CutPrefix(string(serverErr), string(code)+": ")
+		return &Error{Code: code, Message: message}
+	}
+	return err
+}
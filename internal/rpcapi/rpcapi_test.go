@@ -0,0 +1,196 @@
+package rpcapi
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/pcristin/urlshortener/internal/config"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/service"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret-key"
+
+// startServer serves the RPC API on a random local port and returns its address
+func startServer(t *testing.T, interceptors ...UnaryInterceptor) (*Server, string) {
+	t.Helper()
+
+	shortener := service.NewShortener(storage.NewMemoryStorage(), config.NewOptions())
+	server, err := NewServer(shortener, testSecret, "short.example", interceptors...)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Shutdown(context.Background())
+	})
+	return server, listener.Addr().String()
+}
+
+// dial connects to the server acting for the user, anonymously if the user ID is empty
+func dial(t *testing.T, address, userID string) *Client {
+	t.Helper()
+
+	var md Metadata
+	if userID != "" {
+		md = Metadata{UserID: userID, Signature: Sign(userID, testSecret)}
+	}
+	client, err := Dial(address, md)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestShortenAndExpand(t *testing.T) {
+	_, address := startServer(t)
+	client := dial(t, address, "user1")
+
+	reply, err := client.Shorten("https://google.com")
+	require.NoError(t, err)
+	assert.False(t, reply.Existing)
+	assert.True(t, strings.HasPrefix(reply.ShortURL, "http://short.example/"))
+
+	again, err := client.Shorten("https://google.com")
+	require.NoError(t, err)
+	assert.True(t, again.Existing)
+	assert.Equal(t, reply.ShortURL, again.ShortURL)
+
+	// Expanding doesn't require an identity
+	token := strings.TrimPrefix(reply.ShortURL, "http://short.example/")
+	originalURL, err := dial(t, address, "").Expand(token)
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", originalURL)
+
+	_, err = client.Expand("missing")
+	assert.Equal(t, CodeNotFound, ErrorCode(err))
+
+	_, err = client.Shorten("")
+	assert.Equal(t, CodeInvalidArgument, ErrorCode(err))
+}
+
+func TestBatchListAndDelete(t *testing.T) {
+	_, address := startServer(t)
+	client := dial(t, address, "user1")
+
+	batch, err := client.ShortenBatch(ShortenBatchArgs{Items: mod.BatchRequest{
+		{CorrelationID: "1", OriginalURL: "https://google.com"},
+		{CorrelationID: "2", OriginalURL: "https://yandex.ru"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.Items, 2)
+	assert.Equal(t, "1", batch.Items[0].CorrelationID)
+	assert.Equal(t, "2", batch.Items[1].CorrelationID)
+
+	urls, err := client.UserURLs()
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	// Other users see neither the URLs nor can delete them
+	other := client.WithMetadata(Metadata{UserID: "user2", Signature: Sign("user2", testSecret)})
+	urls, err = other.UserURLs()
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	token := strings.TrimPrefix(batch.Items[0].ShortURL, "http://short.example/")
+	require.NoError(t, other.Delete([]string{token}))
+	_, err = client.Expand(token)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Delete([]string{token}))
+	_, err = client.Expand(token)
+	assert.Equal(t, CodeGone, ErrorCode(err))
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	_, address := startServer(t)
+
+	tests := []struct {
+		name     string
+		metadata Metadata
+		wantCode Code
+	}{
+		{
+			name:     "anonymous",
+			wantCode: CodeUnauthenticated,
+		},
+		{
+			name:     "missing signature",
+			metadata: Metadata{UserID: "user1"},
+			wantCode: CodeUnauthenticated,
+		},
+		{
+			name:     "signature of another user",
+			metadata: Metadata{UserID: "user1", Signature: Sign("user2", testSecret)},
+			wantCode: CodeUnauthenticated,
+		},
+		{
+			name:     "signature with another secret",
+			metadata: Metadata{UserID: "user1", Signature: Sign("user1", "other-secret")},
+			wantCode: CodeUnauthenticated,
+		},
+		{
+			name:     "valid signature",
+			metadata: Metadata{UserID: "user1", Signature: Sign("user1", testSecret)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := Dial(address, tt.metadata)
+			require.NoError(t, err)
+			defer client.Close()
+
+			_, err = client.UserURLs()
+			assert.Equal(t, tt.wantCode, ErrorCode(err))
+		})
+	}
+}
+
+func TestInterceptorChain(t *testing.T) {
+	var calls []string
+	_, address := startServer(t, func(ctx context.Context, info *UnaryInfo, next UnaryHandler) error {
+		// Custom interceptors run after authentication
+		calls = append(calls, info.Method+" "+UserIDFromContext(ctx)+" "+info.Metadata.RequestID)
+		if info.Metadata.RequestID == "blocked" {
+			return &Error{Code: CodeUnavailable, Message: "blocked"}
+		}
+		return next(ctx)
+	})
+
+	md := Metadata{UserID: "user1", Signature: Sign("user1", testSecret), RequestID: "req-1"}
+	client, err := Dial(address, md)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.UserURLs()
+	require.NoError(t, err)
+
+	md.RequestID = "blocked"
+	_, err = client.WithMetadata(md).UserURLs()
+	var rpcErr *Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, &Error{Code: CodeUnavailable, Message: "blocked"}, rpcErr)
+
+	assert.Equal(t, []string{"Shortener.UserURLs user1 req-1", "Shortener.UserURLs user1 blocked"}, calls)
+}
+
+func TestShutdown(t *testing.T) {
+	server, address := startServer(t)
+	client := dial(t, address, "user1")
+
+	_, err := client.Shorten("https://google.com")
+	require.NoError(t, err)
+
+	require.NoError(t, server.Shutdown(context.Background()))
+
+	_, err = client.Shorten("https://yandex.ru")
+	assert.Error(t, err)
+	_, err = Dial(address, Metadata{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,348 @@
+package rpcapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/logger"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/service"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// UnaryInfo describes an intercepted call
+type UnaryInfo struct {
+	// Method is the full method name, such as "Shortener.Shorten"
+	Method   string
+	Metadata Metadata
+}
+
+// UnaryHandler performs a call
+type UnaryHandler func(ctx context.Context) error
+
+// UnaryInterceptor wraps calls, like HTTP middlewares do for handlers.
+// It must call next to continue the call, or return an error to reject it.
+type UnaryInterceptor func(ctx context.Context, info *UnaryInfo, next UnaryHandler) error
+
+type contextKey string
+
+const userIDContextKey contextKey = "userID"
+
+// UserIDFromContext returns the authenticated user ID of the call, empty for anonymous calls
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDContextKey).(string)
+	return userID
+}
+
+// Server serves the shortener RPC API
+type Server struct {
+	shortener    *service.Shortener
+	rpcServer    *rpc.Server
+	host         string
+	interceptors []UnaryInterceptor
+
+	// calls tracks the calls in progress so Shutdown can wait for them
+	calls sync.WaitGroup
+
+	mu           sync.Mutex
+	listener     net.Listener
+	conns        map[net.Conn]struct{}
+	shuttingDown bool
+}
+
+// NewServer creates a server calling the shortener.
+// Callers are authenticated with signatures made with the secret, and short URLs
+// are built for the host unless a base URL is configured.
+// Logging and authentication interceptors run before the given ones.
+func NewServer(shortener *service.Shortener, secret, host string, interceptors ...UnaryInterceptor) (*Server, error) {
+	s := &Server{
+		shortener:    shortener,
+		rpcServer:    rpc.NewServer(),
+		host:         host,
+		interceptors: append([]UnaryInterceptor{LoggingInterceptor(zap.L()), AuthInterceptor(secret)}, interceptors...),
+		conns:        make(map[net.Conn]struct{}),
+	}
+	if err := s.rpcServer.RegisterName(ServiceName, &Shortener{server: s}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Serve accepts connections on the listener and serves JSON-RPC on each of them.
+// It returns nil once Shutdown has been called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isShuttingDown() {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go func() {
+			s.rpcServer.ServeCodec(jsonrpc.NewServerCodec(conn))
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and calls, waits for the calls in progress
+// to complete or the context to be done, then closes the connections
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.calls.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// isShuttingDown reports whether Shutdown has been called
+func (s *Server) isShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
+}
+
+// invoke runs a call through the interceptors, with the request ID of the metadata in the logger of its context.
+// URLs shortened by the call are recorded with the RPC source, without client IP: net/rpc doesn't expose the peer.
+func (s *Server) invoke(method string, md Metadata, call UnaryHandler) error {
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return newError(CodeUnavailable, "server is shutting down")
+	}
+	s.calls.Add(1)
+	s.mu.Unlock()
+	defer s.calls.Done()
+
+	info := &UnaryInfo{Method: method, Metadata: md}
+	handler := call
+	for i := len(s.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := s.interceptors[i], handler
+		handler = func(ctx context.Context) error {
+			return interceptor(ctx, info, next)
+		}
+	}
+	ctx := storage.WithCreator(context.Background(), storage.Creator{Source: mod.SourceRPC})
+	if md.RequestID != "" {
+		ctx = logger.WithRequestID(ctx, md.RequestID)
+	}
+	return handler(ctx)
+}
+
+// publicMethods can be called without identity
+var publicMethods = map[string]bool{
+	ServiceName + ".Expand": true,
+}
+
+// AuthInterceptor authenticates the user of the call from the metadata signature
+// and stores the user ID in the context. Only public methods accept anonymous calls.
+func AuthInterceptor(secret string) UnaryInterceptor {
+	return func(ctx context.Context, info *UnaryInfo, next UnaryHandler) error {
+		md := info.Metadata
+		if md.UserID == "" {
+			if !publicMethods[info.Method] {
+				return newError(CodeUnauthenticated, "user identity required")
+			}
+			return next(ctx)
+		}
+
+		if secret == "" || !hmac.Equal([]byte(md.Signature), []byte(Sign(md.UserID, secret))) {
+			return newError(CodeUnauthenticated, "invalid user signature")
+		}
+		ctx = logger.WithFields(ctx, zap.String("user_id", md.UserID))
+		return next(context.WithValue(ctx, userIDContextKey, md.UserID))
+	}
+}
+
+// LoggingInterceptor logs every call with its outcome and duration, and the fields of the context logger
+func LoggingInterceptor(log *zap.Logger) UnaryInterceptor {
+	return func(ctx context.Context, info *UnaryInfo, next UnaryHandler) error {
+		start := time.Now()
+		err := next(ctx)
+
+		fields := append(logger.Fields(ctx),
+			zap.String("method", info.Method),
+			zap.Duration("duration", time.Since(start)),
+		)
+		if err != nil {
+			log.Info("RPC call failed", append(fields, zap.String("code", string(ErrorCode(err))), zap.Error(err))...)
+			return err
+		}
+		log.Info("RPC call", fields...)
+		return nil
+	}
+}
+
+// Shortener is the RPC receiver of the shortener methods
+type Shortener struct {
+	server *Server
+}
+
+// Shorten shortens a URL on behalf of the user
+func (r *Shortener) Shorten(args *ShortenArgs, reply *ShortenReply) error {
+	return r.server.invoke(ServiceName+".Shorten", args.Metadata, func(ctx context.Context) error {
+		token, err := r.server.shortener.Shorten(ctx, UserIDFromContext(ctx), args.URL)
+		if err != nil && !errors.Is(err, service.ErrConflict) {
+			return r.server.serviceError(ctx, err)
+		}
+		reply.ShortURL = r.server.shortURL(token)
+		reply.Existing = err != nil
+		return nil
+	})
+}
+
+// ShortenBatch shortens several URLs on behalf of the user, reporting the status of every item
+func (r *Shortener) ShortenBatch(args *ShortenBatchArgs, reply *ShortenBatchReply) error {
+	return r.server.invoke(ServiceName+".ShortenBatch", args.Metadata, func(ctx context.Context) error {
+		longURLs := make([]string, len(args.Items))
+		for i, item := range args.Items {
+			longURLs[i] = item.OriginalURL
+		}
+
+		results, err := r.server.shortener.ShortenBatch(ctx, UserIDFromContext(ctx), longURLs)
+		if err != nil {
+			return r.server.serviceError(ctx, err)
+		}
+
+		for i, item := range args.Items {
+			replyItem := mod.BatchResponseItem{
+				CorrelationID: item.CorrelationID,
+				Status:        string(results[i].Status),
+			}
+			switch {
+			case results[i].Succeeded():
+				replyItem.ShortURL = r.server.shortURL(results[i].Token)
+			case results[i].Status == service.BatchError:
+				logger.FromContext(ctx).Error("RPC batch item error", zap.Error(results[i].Err))
+				replyItem.Error = "internal server error"
+			default:
+				replyItem.Error = results[i].Err.Error()
+			}
+			reply.Items = append(reply.Items, replyItem)
+		}
+		return nil
+	})
+}
+
+// Expand returns the original URL of a token
+func (r *Shortener) Expand(args *ExpandArgs, reply *ExpandReply) error {
+	return r.server.invoke(ServiceName+".Expand", args.Metadata, func(ctx context.Context) error {
+		originalURL, err := r.server.shortener.Expand(ctx, args.Token)
+		if err != nil {
+			return r.server.serviceError(ctx, err)
+		}
+		reply.OriginalURL = originalURL
+		return nil
+	})
+}
+
+// UserURLs lists the URLs shortened by the user
+func (r *Shortener) UserURLs(args *UserURLsArgs, reply *UserURLsReply) error {
+	return r.server.invoke(ServiceName+".UserURLs", args.Metadata, func(ctx context.Context) error {
+		nodes, err := r.server.shortener.UserURLs(ctx, UserIDFromContext(ctx))
+		if err != nil {
+			return r.server.serviceError(ctx, err)
+		}
+
+		reply.URLs = make([]UserURL, len(nodes))
+		for i, node := range nodes {
+			reply.URLs[i] = UserURL{
+				ShortURL:     r.server.shortURL(node.ShortURL),
+				OriginalURL:  node.OriginalURL,
+				IsDeleted:    node.IsDeleted,
+				CreatedAt:    node.CreatedAt,
+				UpdatedAt:    node.UpdatedAt,
+				DeletedAt:    node.DeletedAt,
+				Source:       node.Source,
+				ClientIPHash: node.ClientIPHash,
+			}
+		}
+		return nil
+	})
+}
+
+// Delete marks URLs of the user as deleted. Unlike the HTTP API the deletion is
+// complete when the call returns.
+func (r *Shortener) Delete(args *DeleteArgs, reply *DeleteReply) error {
+	return r.server.invoke(ServiceName+".Delete", args.Metadata, func(ctx context.Context) error {
+		if err := r.server.shortener.DeleteURLs(ctx, UserIDFromContext(ctx), args.Tokens); err != nil {
+			return r.server.serviceError(ctx, err)
+		}
+		return nil
+	})
+}
+
+// shortURL builds the short URL of a token
+func (s *Server) shortURL(token string) string {
+	return s.shortener.ShortURL(token, s.host, false)
+}
+
+// serviceError maps a domain error of the service to an RPC error
+func (s *Server) serviceError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidURL):
+		return newError(CodeInvalidArgument, "empty URL")
+	case errors.Is(err, service.ErrEmptyBatch):
+		return newError(CodeInvalidArgument, "empty batch")
+	case errors.Is(err, service.ErrBatchTooLarge), errors.Is(err, service.ErrURLTooLong):
+		return newError(CodeInvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidToken):
+		return newError(CodeInvalidArgument, "empty token")
+	case errors.Is(err, service.ErrUnauthenticated):
+		return newError(CodeUnauthenticated, "user identity required")
+	case errors.Is(err, service.ErrDeleted):
+		return newError(CodeGone, "URL was deleted")
+	case errors.Is(err, service.ErrDisabled):
+		return newError(CodeGone, "URL was disabled")
+	case errors.Is(err, service.ErrNotFound):
+		return newError(CodeNotFound, "URL not found")
+	default:
+		return s.internalError(ctx, err)
+	}
+}
+
+// internalError logs an unexpected error and hides its details from the caller
+func (s *Server) internalError(ctx context.Context, err error) error {
+	logger.FromContext(ctx).Error("RPC internal error", zap.Error(err))
+	return newError(CodeInternal, "internal server error")
+}
@@ -0,0 +1,157 @@
+// Package rpcapi exposes the URL shortener to internal services over RPC.
+//
+// It uses net/rpc with the JSON-RPC 1.0 codec on a dedicated listener. Every call carries
+// Metadata identifying the user it is made on behalf of: the user ID and its signature,
+// which is the same HMAC as the signature cookie of the HTTP API. Calls go through
+// a chain of unary interceptors, by default logging and authentication.
+package rpcapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/rpc"
+	"strings"
+	"time"
+
+	mod "github.com/pcristin/urlshortener/internal/models"
+)
+
+// ServiceName is the name the shortener is registered under, methods are called as "Shortener.Method"
+const ServiceName = "Shortener"
+
+// Metadata carries the identity of the caller and request-scoped information
+type Metadata struct {
+	UserID    string `json:"user_id,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Sign returns the signature of a user ID expected in the call metadata
+func Sign(userID, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(userID))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ShortenArgs are the arguments of Shortener.Shorten
+type ShortenArgs struct {
+	Metadata
+	URL string `json:"url"`
+}
+
+// ShortenReply is the result of Shortener.Shorten.
+// Existing is set if the URL had already been shortened, ShortURL is then the existing short URL.
+type ShortenReply struct {
+	ShortURL string `json:"short_url"`
+	Existing bool   `json:"existing"`
+}
+
+// ShortenBatchArgs are the arguments of Shortener.ShortenBatch
+type ShortenBatchArgs struct {
+	Metadata
+	Items mod.BatchRequest `json:"items"`
+}
+
+// ShortenBatchReply is the result of Shortener.ShortenBatch
+type ShortenBatchReply struct {
+	Items mod.BatchResponse `json:"items"`
+}
+
+// ExpandArgs are the arguments of Shortener.Expand
+type ExpandArgs struct {
+	Metadata
+	Token string `json:"token"`
+}
+
+// ExpandReply is the result of Shortener.Expand
+type ExpandReply struct {
+	OriginalURL string `json:"original_url"`
+}
+
+// UserURLsArgs are the arguments of Shortener.UserURLs
+type UserURLsArgs struct {
+	Metadata
+}
+
+// UserURL is a URL shortened by the user, with the metadata recorded since it was shortened.
+// Times are omitted when unknown, for URLs stored before they were recorded.
+type UserURL struct {
+	ShortURL     string    `json:"short_url"`
+	OriginalURL  string    `json:"original_url"`
+	IsDeleted    bool      `json:"is_deleted,omitempty"`
+	CreatedAt    time.Time `json:"created_at,omitzero"`
+	UpdatedAt    time.Time `json:"updated_at,omitzero"`
+	DeletedAt    time.Time `json:"deleted_at,omitzero"`
+	Source       string    `json:"source,omitempty"`
+	ClientIPHash string    `json:"client_ip_hash,omitempty"`
+}
+
+// UserURLsReply is the result of Shortener.UserURLs
+type UserURLsReply struct {
+	URLs []UserURL `json:"urls"`
+}
+
+// DeleteArgs are the arguments of Shortener.Delete
+type DeleteArgs struct {
+	Metadata
+	Tokens []string `json:"tokens"`
+}
+
+// DeleteReply is the result of Shortener.Delete
+type DeleteReply struct{}
+
+// Code classifies the errors returned by the RPC API
+type Code string
+
+// Error codes returned by the RPC API
+const (
+	CodeInvalidArgument Code = "invalid_argument"
+	CodeUnauthenticated Code = "unauthenticated"
+	CodeNotFound        Code = "not_found"
+	CodeGone            Code = "gone"
+	CodeUnavailable     Code = "unavailable"
+	CodeInternal        Code = "internal"
+)
+
+// Error is an RPC error with a code the caller can act on.
+// It travels as "code: message" since net/rpc only transmits error strings.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// newError creates an RPC error
+func newError(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// ErrorCode returns the code of an error returned by the server or the client,
+// CodeInternal if it carries none
+func ErrorCode(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code
+	}
+
+	var serverErr rpc.ServerError
+	if errors.As(err, &serverErr) {
+		if code, _, found := strings.Cut(string(serverErr), ": "); found {
+			switch c := Code(code); c {
+			case CodeInvalidArgument, CodeUnauthenticated, CodeNotFound, CodeGone, CodeUnavailable, CodeInternal:
+				return c
+			}
+		}
+	}
+	return CodeInternal
+}
@@ -0,0 +1,32 @@
+package service
+
+import "errors"
+
+// Domain errors returned by the shortener. Frontends map them to their own status codes,
+// any other error is an unexpected failure of the storage.
+var (
+	// ErrInvalidURL is returned for an empty URL to shorten
+	ErrInvalidURL = errors.New("invalid URL")
+	// ErrInvalidToken is returned for an empty token
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrEmptyBatch is returned for a batch without URLs
+	ErrEmptyBatch = errors.New("empty batch")
+	// ErrBatchTooLarge is returned for a batch with more items than allowed
+	ErrBatchTooLarge = errors.New("batch too large")
+	// ErrURLTooLong is returned for a URL longer than allowed
+	ErrURLTooLong = errors.New("URL too long")
+	// ErrInvalidPage is returned for a page of URLs requested with an invalid limit, sort order, filter or cursor
+	ErrInvalidPage = errors.New("invalid page request")
+	// ErrRowLimit is returned when an import has more rows than allowed
+	ErrRowLimit = errors.New("too many rows")
+	// ErrConflict is returned with the existing token when the URL was already shortened
+	ErrConflict = errors.New("URL already shortened")
+	// ErrNotFound is returned for an unknown token
+	ErrNotFound = errors.New("URL not found")
+	// ErrDeleted is returned for a URL deleted by its owner
+	ErrDeleted = errors.New("URL was deleted")
+	// ErrDisabled is returned for a URL disabled by an administrator
+	ErrDisabled = errors.New("URL was disabled")
+	// ErrUnauthenticated is returned when a use case requires a user and none is given
+	ErrUnauthenticated = errors.New("user identity required")
+)
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mailru/easyjson"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	uu "github.com/pcristin/urlshortener/internal/urlutils"
+)
+
+// importChunkSize is the number of rows written to storage at once during an import
+const importChunkSize = 500
+
+// Shortened is the outcome of shortening one URL of a bulk operation
+type Shortened struct {
+	Token string
+	// Existing is set if the URL had already been shortened, Token is then its existing token
+	Existing bool
+}
+
+// ShortenMany shortens URLs on behalf of a user with a single write to storage
+// and returns their outcomes in the same order. URLs repeated in the list share one token.
+func (s *Shortener) ShortenMany(ctx context.Context, userID string, longURLs []string) ([]Shortened, error) {
+	if len(longURLs) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	tokens := make(map[string]string, len(longURLs))
+	urls := make(map[string]string, len(longURLs))
+	for _, longURL := range longURLs {
+		if longURL == "" {
+			return nil, ErrInvalidURL
+		}
+		if _, found := tokens[longURL]; found {
+			continue
+		}
+		token := uu.GenerateToken()
+		tokens[longURL] = token
+		urls[token] = longURL
+	}
+
+	existing, err := s.storage.AddURLBatch(ctx, userID, urls)
+	if err != nil {
+		return nil, fmt.Errorf("shorten URLs: %w", err)
+	}
+
+	results := make([]Shortened, len(longURLs))
+	for i, longURL := range longURLs {
+		if token, found := existing[longURL]; found {
+			results[i] = Shortened{Token: token, Existing: true}
+			continue
+		}
+		results[i] = Shortened{Token: tokens[longURL]}
+	}
+	return results, nil
+}
+
+// ImportRow is a URL to shorten read from an import stream
+type ImportRow struct {
+	// Line is the position of the row in the stream, starting at 1
+	Line          int
+	CorrelationID string
+	OriginalURL   string
+}
+
+// RowError reports a malformed row, which is skipped while the import goes on
+type RowError struct {
+	Line int
+	Err  error
+}
+
+// Error implements the error interface
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the cause of the error
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// RowReader reads the rows of an import stream one at a time
+type RowReader interface {
+	// Read returns the next row, a *RowError for a malformed row, io.EOF at the end of the stream,
+	// or any other error if the stream can't be read further
+	Read() (ImportRow, error)
+}
+
+// csvRows reads rows from CSV with the correlation ID and the URL, or only the URL
+type csvRows struct {
+	r       *csv.Reader
+	started bool
+}
+
+// NewCSVRows returns a reader of CSV rows of the form "correlation_id,original_url" or "original_url".
+// A header row naming these columns is skipped.
+func NewCSVRows(r io.Reader) RowReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+	return &csvRows{r: reader}
+}
+
+// Read implements RowReader
+func (c *csvRows) Read() (ImportRow, error) {
+	for {
+		record, err := c.r.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return ImportRow{}, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+			}
+			return ImportRow{}, err
+		}
+
+		line, _ := c.r.FieldPos(0)
+		isHeader := !c.started && strings.EqualFold(record[len(record)-1], "original_url")
+		c.started = true
+		if isHeader {
+			continue
+		}
+
+		switch len(record) {
+		case 1:
+			return ImportRow{Line: line, OriginalURL: record[0]}, nil
+		case 2:
+			return ImportRow{Line: line, CorrelationID: record[0], OriginalURL: record[1]}, nil
+		default:
+			return ImportRow{}, &RowError{Line: line, Err: fmt.Errorf("expected 1 or 2 fields, got %d", len(record))}
+		}
+	}
+}
+
+// ndjsonRows reads rows from newline delimited JSON objects
+type ndjsonRows struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewNDJSONRows returns a reader of rows given as one JSON object per line,
+// with the fields of a batch request item. Blank lines are skipped.
+func NewNDJSONRows(r io.Reader) RowReader {
+	return &ndjsonRows{r: bufio.NewReader(r)}
+}
+
+// Read implements RowReader
+func (n *ndjsonRows) Read() (ImportRow, error) {
+	for {
+		data, err := n.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return ImportRow{}, err
+		}
+		if err != nil && err != io.EOF {
+			return ImportRow{}, err
+		}
+		n.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var item mod.BatchRequestItem
+		if err := easyjson.Unmarshal(data, &item); err != nil {
+			return ImportRow{}, &RowError{Line: n.line, Err: errors.New("invalid JSON")}
+		}
+		return ImportRow{Line: n.line, CorrelationID: item.CorrelationID, OriginalURL: item.OriginalURL}, nil
+	}
+}
+
+// ImportResult is the outcome of one row of an import
+type ImportResult struct {
+	Line          int
+	CorrelationID string
+	Token         string
+	Existing      bool
+	// Err is the reason the row was not imported, nil on success
+	Err error
+}
+
+// Import shortens the URLs read from the rows on behalf of a user, writing them to storage in chunks
+// so the stream is never held in memory, and emits the result of every row in the order of the stream.
+//
+// Malformed rows and empty URLs are reported in their result and don't stop the import. Reading more
+// than maxRows rows stops it with ErrRowLimit, once the results of the rows before the limit are emitted.
+func (s *Shortener) Import(ctx context.Context, userID string, rows RowReader, maxRows int, emit func(ImportResult) error) error {
+	if userID == "" {
+		return ErrUnauthenticated
+	}
+
+	pending := make([]ImportResult, 0, importChunkSize)
+	longURLs := make([]string, 0, importChunkSize)
+
+	// flush writes the pending URLs and emits the pending results
+	flush := func() error {
+		if len(longURLs) > 0 {
+			shortened, err := s.ShortenMany(ctx, userID, longURLs)
+			if err != nil {
+				return err
+			}
+			next := 0
+			for i := range pending {
+				if pending[i].Err == nil {
+					pending[i].Token = shortened[next].Token
+					pending[i].Existing = shortened[next].Existing
+					next++
+				}
+			}
+		}
+		for _, result := range pending {
+			if err := emit(result); err != nil {
+				return err
+			}
+		}
+		pending, longURLs = pending[:0], longURLs[:0]
+		return nil
+	}
+
+	for count := 1; ; count++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		row, err := rows.Read()
+		if err == io.EOF {
+			return flush()
+		}
+		if count > maxRows {
+			if err := flush(); err != nil {
+				return err
+			}
+			return ErrRowLimit
+		}
+
+		var rowErr *RowError
+		switch {
+		case errors.As(err, &rowErr):
+			pending = append(pending, ImportResult{Line: rowErr.Line, Err: rowErr.Err})
+		case err != nil:
+			return errors.Join(fmt.Errorf("read import: %w", err), flush())
+		case !uu.URLCheck(row.OriginalURL):
+			pending = append(pending, ImportResult{Line: row.Line, CorrelationID: row.CorrelationID, Err: ErrInvalidURL})
+		case len(row.OriginalURL) > s.config.GetMaxURLLength():
+			pending = append(pending, ImportResult{Line: row.Line, CorrelationID: row.CorrelationID, Err: ErrURLTooLong})
+		default:
+			pending = append(pending, ImportResult{Line: row.Line, CorrelationID: row.CorrelationID})
+			longURLs = append(longURLs, row.OriginalURL)
+		}
+
+		if len(pending) >= importChunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortenMany(t *testing.T) {
+	urlStorage := storage.NewMemoryStorage()
+	s := NewShortener(urlStorage, config.NewOptions())
+	ctx := context.Background()
+
+	existing, err := s.Shorten(ctx, "user1", "https://google.com")
+	require.NoError(t, err)
+
+	results, err := s.ShortenMany(ctx, "user2", []string{"https://yandex.ru", "https://google.com", "https://yandex.ru"})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.False(t, results[0].Existing)
+	assert.Equal(t, Shortened{Token: existing, Existing: true}, results[1])
+	assert.Equal(t, results[0], results[2], "repeated URLs share one token")
+
+	urls, err := s.UserURLs(ctx, "user2")
+	require.NoError(t, err)
+	require.Len(t, urls, 1, "URLs are added on behalf of the user")
+	assert.Equal(t, "https://yandex.ru", urls[0].OriginalURL)
+
+	_, err = s.ShortenMany(ctx, "user2", []string{"https://ok.example", ""})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+}
+
+func TestRowReaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     RowReader
+		wantRows []ImportRow
+		wantErrs map[int]string
+	}{
+		{
+			name: "csv with header",
+			rows: NewCSVRows(strings.NewReader("correlation_id,original_url\n1,https://a.example\n2, https://b.example\n")),
+			wantRows: []ImportRow{
+				{Line: 2, CorrelationID: "1", OriginalURL: "https://a.example"},
+				{Line: 3, CorrelationID: "2", OriginalURL: "https://b.example"},
+			},
+		},
+		{
+			name: "csv of URLs",
+			rows: NewCSVRows(strings.NewReader("https://a.example\nhttps://b.example")),
+			wantRows: []ImportRow{
+				{Line: 1, OriginalURL: "https://a.example"},
+				{Line: 2, OriginalURL: "https://b.example"},
+			},
+		},
+		{
+			name: "csv malformed rows",
+			rows: NewCSVRows(strings.NewReader("1,https://a.example,extra\n2,\"https://b.example\n")),
+			wantErrs: map[int]string{
+				1: "expected 1 or 2 fields, got 3",
+				2: "extraneous or missing \" in quoted-field",
+			},
+		},
+		{
+			name: "ndjson",
+			rows: NewNDJSONRows(strings.NewReader("{\"correlation_id\":\"1\",\"original_url\":\"https://a.example\"}\n\n{\"original_url\":\"https://b.example\"}")),
+			wantRows: []ImportRow{
+				{Line: 1, CorrelationID: "1", OriginalURL: "https://a.example"},
+				{Line: 3, OriginalURL: "https://b.example"},
+			},
+		},
+		{
+			name: "ndjson malformed row",
+			rows: NewNDJSONRows(strings.NewReader("{\"original_url\":\n{\"original_url\":\"https://b.example\"}\n")),
+			wantRows: []ImportRow{
+				{Line: 2, OriginalURL: "https://b.example"},
+			},
+			wantErrs: map[int]string{1: "invalid JSON"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []ImportRow
+			errs := make(map[int]string)
+			for {
+				row, err := tt.rows.Read()
+				if err == io.EOF {
+					break
+				}
+				var rowErr *RowError
+				if errors.As(err, &rowErr) {
+					errs[rowErr.Line] = rowErr.Err.Error()
+					continue
+				}
+				require.NoError(t, err)
+				rows = append(rows, row)
+			}
+
+			assert.Equal(t, tt.wantRows, rows)
+			if tt.wantErrs == nil {
+				tt.wantErrs = map[int]string{}
+			}
+			assert.Equal(t, tt.wantErrs, errs)
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("results in order across chunks", func(t *testing.T) {
+		s := NewShortener(storage.NewMemoryStorage(), config.NewOptions())
+		existing, err := s.Shorten(ctx, "user1", "https://url0.example")
+		require.NoError(t, err)
+
+		var upload strings.Builder
+		rowCount := importChunkSize*2 + 10
+		for i := range rowCount {
+			if i == 7 {
+				upload.WriteString(fmt.Sprintf("%d,\n", i))
+				continue
+			}
+			if i == 8 {
+				upload.WriteString(fmt.Sprintf("%d,not a url\n", i))
+				continue
+			}
+			upload.WriteString(fmt.Sprintf("%d,https://url%d.example\n", i, i))
+		}
+
+		var results []ImportResult
+		err = s.Import(ctx, "user1", NewCSVRows(strings.NewReader(upload.String())), rowCount, func(r ImportResult) error {
+			results = append(results, r)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, results, rowCount)
+
+		for i, result := range results {
+			assert.Equal(t, i+1, result.Line)
+			assert.Equal(t, fmt.Sprint(i), result.CorrelationID)
+		}
+		assert.Equal(t, ImportResult{Line: 1, CorrelationID: "0", Token: existing, Existing: true}, results[0])
+		assert.ErrorIs(t, results[7].Err, ErrInvalidURL)
+		assert.Empty(t, results[7].Token)
+		assert.ErrorIs(t, results[8].Err, ErrInvalidURL, "rows are validated like batch items")
+		assert.Empty(t, results[8].Token)
+		assert.NoError(t, results[rowCount-1].Err)
+		assert.NotEmpty(t, results[rowCount-1].Token)
+	})
+
+	t.Run("row limit", func(t *testing.T) {
+		s := NewShortener(storage.NewMemoryStorage(), config.NewOptions())
+		upload := "https://a.example\nhttps://b.example\nhttps://c.example\n"
+
+		var results []ImportResult
+		err := s.Import(ctx, "user1", NewCSVRows(strings.NewReader(upload)), 2, func(r ImportResult) error {
+			results = append(results, r)
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrRowLimit)
+		assert.Len(t, results, 2, "rows before the limit are imported")
+
+		_, err = s.Expand(ctx, results[1].Token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("anonymous user", func(t *testing.T) {
+		s := NewShortener(storage.NewMemoryStorage(), config.NewOptions())
+		err := s.Import(ctx, "", NewCSVRows(strings.NewReader("https://a.example")), 10, func(ImportResult) error { return nil })
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	})
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/storage"
+)
+
+// Page sizes of the URLs of a user
+const (
+	// DefaultPageSize is the number of URLs of a page when no limit is requested
+	DefaultPageSize = 100
+	// MaxPageSize is the largest number of URLs of a page
+	MaxPageSize = 1000
+)
+
+// PageRequest selects a page of the URLs of a user
+type PageRequest struct {
+	// Limit is the maximal number of URLs of the page, DefaultPageSize if zero
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	// Sort is created_at (the default) or original_url, prefixed with a minus sign for descending order
+	Sort string
+	// IncludeDeleted lists the URLs deleted by the user too
+	IncludeDeleted bool
+	// Domain only lists the URLs whose host is this domain or one of its subdomains, if set
+	Domain string
+	// CreatedAfter and CreatedBefore only list the URLs shortened within the range, if set
+	CreatedAfter, CreatedBefore time.Time
+	// Search only lists the URLs whose token or original URL contains this text, case-insensitively, if set
+	Search string
+}
+
+// URLPage is a page of the URLs of a user
+type URLPage struct {
+	URLs []mod.URLStorageNode
+	// NextCursor requests the following page, empty on the last page
+	NextCursor string
+}
+
+// cursor is the position a page starts after, bound to the sort order it was issued for.
+// Only the sort key of that order is kept, to keep cursors short.
+type cursor struct {
+	Sort        string    `json:"s"`
+	CreatedAt   time.Time `json:"c,omitzero"`
+	OriginalURL string    `json:"u,omitempty"`
+	Token       string    `json:"t"`
+}
+
+// encodeCursor returns the opaque cursor of the position of a URL in the sort order
+func encodeCursor(sort string, field storage.URLSortField, node mod.URLStorageNode) (string, error) {
+	c := cursor{Sort: sort, Token: node.ShortURL}
+	if field == storage.SortByOriginalURL {
+		c.OriginalURL = node.OriginalURL
+	} else {
+		c.CreatedAt = node.CreatedAt
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the position of an opaque cursor issued for the sort order
+func decodeCursor(value, sort string) (*storage.URLPosition, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Token == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidPage, c.Sort)
+	}
+	return &storage.URLPosition{CreatedAt: c.CreatedAt, OriginalURL: c.OriginalURL, Token: c.Token}, nil
+}
+
+// parseSort returns the field and direction of a sort order
+func parseSort(sort string) (storage.URLSortField, bool, error) {
+	field, descending := strings.CutPrefix(sort, "-")
+	switch storage.URLSortField(field) {
+	case storage.SortByCreatedAt, storage.SortByOriginalURL:
+		return storage.URLSortField(field), descending, nil
+	default:
+		return "", false, fmt.Errorf("%w: unknown sort %q, expected created_at or original_url", ErrInvalidPage, sort)
+	}
+}
+
+// UserURLsPage returns a page of the URLs of a user matching the filters of the request, in the requested
+// order. Pages are keyset paginated: the cursor of a page is the position of its last URL, so URLs added
+// or deleted while paging don't shift the following pages. It returns ErrInvalidPage for an invalid
+// limit, sort order, filter or cursor.
+func (s *Shortener) UserURLsPage(ctx context.Context, userID string, req PageRequest) (URLPage, error) {
+	if userID == "" {
+		return URLPage{}, ErrUnauthenticated
+	}
+
+	if req.Limit == 0 {
+		req.Limit = DefaultPageSize
+	}
+	if req.Limit < 0 || req.Limit > MaxPageSize {
+		return URLPage{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidPage, MaxPageSize)
+	}
+	if req.Sort == "" {
+		req.Sort = string(storage.SortByCreatedAt)
+	}
+	field, descending, err := parseSort(req.Sort)
+	if err != nil {
+		return URLPage{}, err
+	}
+	if strings.ContainsAny(req.Domain, "/:@?#") {
+		return URLPage{}, fmt.Errorf("%w: domain must be a host name", ErrInvalidPage)
+	}
+	if !req.CreatedAfter.IsZero() && !req.CreatedBefore.IsZero() && !req.CreatedAfter.Before(req.CreatedBefore) {
+		return URLPage{}, fmt.Errorf("%w: created_after must be before created_before", ErrInvalidPage)
+	}
+
+	query := storage.UserURLQuery{
+		UserID:         userID,
+		SortBy:         field,
+		Descending:     descending,
+		IncludeDeleted: req.IncludeDeleted,
+		Domain:         req.Domain,
+		CreatedAfter:   req.CreatedAfter,
+		CreatedBefore:  req.CreatedBefore,
+		Search:         req.Search,
+		// One more URL than requested tells whether a following page exists
+		Limit: req.Limit + 1,
+	}
+	if req.Cursor != "" {
+		if query.After, err = decodeCursor(req.Cursor, req.Sort); err != nil {
+			return URLPage{}, err
+		}
+	}
+
+	urls, err := s.storage.QueryUserURLs(ctx, query)
+	if err != nil {
+		return URLPage{}, fmt.Errorf("query user URLs: %w", err)
+	}
+
+	page := URLPage{URLs: urls}
+	if len(urls) > req.Limit {
+		page.URLs = urls[:req.Limit]
+		if page.NextCursor, err = encodeCursor(req.Sort, field, page.URLs[req.Limit-1]); err != nil {
+			return URLPage{}, fmt.Errorf("encode cursor: %w", err)
+		}
+	}
+	return page, nil
+}
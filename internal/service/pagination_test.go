@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserURLsPage(t *testing.T) {
+	s := NewShortener(storage.NewMemoryStorage(), config.NewOptions())
+	ctx := context.Background()
+
+	var shortened []string
+	for i := range 5 {
+		token, err := s.Shorten(ctx, "user1", fmt.Sprintf("https://example.com/%d", 4-i))
+		require.NoError(t, err)
+		shortened = append(shortened, token)
+	}
+
+	// readAll follows the cursors from the first page to the last one
+	readAll := func(req PageRequest) []string {
+		t.Helper()
+		var tokens []string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 10, "pagination doesn't end")
+			page, err := s.UserURLsPage(ctx, "user1", req)
+			require.NoError(t, err)
+			for _, url := range page.URLs {
+				tokens = append(tokens, url.ShortURL)
+			}
+			if page.NextCursor == "" {
+				return tokens
+			}
+			req.Cursor = page.NextCursor
+		}
+	}
+
+	assert.Equal(t, shortened, readAll(PageRequest{Limit: 2}))
+	assert.Equal(t, shortened, readAll(PageRequest{Limit: 5}))
+	reversed := []string{shortened[4], shortened[3], shortened[2], shortened[1], shortened[0]}
+	assert.Equal(t, reversed, readAll(PageRequest{Limit: 2, Sort: "-created_at"}))
+	assert.Equal(t, reversed, readAll(PageRequest{Limit: 3, Sort: "original_url"}))
+
+	// URLs shortened while paging don't shift the following pages
+	first, err := s.UserURLsPage(ctx, "user1", PageRequest{Limit: 2, Sort: "original_url"})
+	require.NoError(t, err)
+	_, err = s.Shorten(ctx, "user1", "https://example.com/0a")
+	require.NoError(t, err)
+	second, err := s.UserURLsPage(ctx, "user1", PageRequest{Limit: 2, Sort: "original_url", Cursor: first.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, second.URLs, 2)
+	assert.Equal(t, shortened[2], second.URLs[0].ShortURL)
+
+	tests := []struct {
+		name    string
+		userID  string
+		req     PageRequest
+		wantErr error
+	}{
+		{name: "anonymous", req: PageRequest{}, wantErr: ErrUnauthenticated},
+		{name: "limit too large", userID: "user1", req: PageRequest{Limit: MaxPageSize + 1}, wantErr: ErrInvalidPage},
+		{name: "unknown sort", userID: "user1", req: PageRequest{Sort: "token"}, wantErr: ErrInvalidPage},
+		{name: "malformed cursor", userID: "user1", req: PageRequest{Cursor: "not a cursor"}, wantErr: ErrInvalidPage},
+		{name: "cursor of another sort", userID: "user1", req: PageRequest{Sort: "-created_at", Cursor: first.NextCursor}, wantErr: ErrInvalidPage},
+		{name: "domain with path", userID: "user1", req: PageRequest{Domain: "example.com/path"}, wantErr: ErrInvalidPage},
+		{
+			name:    "empty created range",
+			userID:  "user1",
+			req:     PageRequest{CreatedAfter: time.Now(), CreatedBefore: time.Now().Add(-time.Hour)},
+			wantErr: ErrInvalidPage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.UserURLsPage(ctx, tt.userID, tt.req)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package service implements the URL shortener use cases independently of the transport,
+// so the HTTP and RPC frontends share one implementation of the business rules.
+// Frontends only decode requests, call the Shortener and map its domain errors.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/pcristin/urlshortener/internal/config"
+	mod "github.com/pcristin/urlshortener/internal/models"
+	"github.com/pcristin/urlshortener/internal/storage"
+	uu "github.com/pcristin/urlshortener/internal/urlutils"
+)
+
+// Shortener shortens, expands and manages the URLs of users
+type Shortener struct {
+	storage storage.URLStorager
+	config  *config.Options
+}
+
+// NewShortener creates a shortener on top of the storage.
+// The base URL of shortened links is read from the configuration on every call so reloads apply.
+func NewShortener(storage storage.URLStorager, config *config.Options) *Shortener {
+	return &Shortener{
+		storage: storage,
+		config:  config,
+	}
+}
+
+// Shorten shortens a URL on behalf of a user and returns its token.
+// URLs are shortened once: if the URL was already shortened, by any user,
+// it returns the existing token with ErrConflict. URLs longer than the configured maximum
+// are rejected with ErrURLTooLong.
+func (s *Shortener) Shorten(ctx context.Context, userID, longURL string) (string, error) {
+	if longURL == "" {
+		return "", ErrInvalidURL
+	}
+	if len(longURL) > s.config.GetMaxURLLength() {
+		return "", ErrURLTooLong
+	}
+
+	token, err := uu.EncodeURL(ctx, longURL, s.storage, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrURLExists) {
+			return token, ErrConflict
+		}
+		return "", fmt.Errorf("shorten URL: %w", err)
+	}
+	return token, nil
+}
+
+// BatchStatus is the outcome of one URL of a batch
+type BatchStatus string
+
+// Outcomes of the URLs of a batch
+const (
+	// BatchCreated is the status of a URL shortened by the batch
+	BatchCreated BatchStatus = "created"
+	// BatchExists is the status of a URL that had already been shortened, its existing token is returned
+	BatchExists BatchStatus = "exists"
+	// BatchInvalid is the status of a URL rejected by validation
+	BatchInvalid BatchStatus = "invalid"
+	// BatchError is the status of a valid URL that could not be written to storage
+	BatchError BatchStatus = "error"
+)
+
+// BatchResult is the outcome of one URL of a batch
+type BatchResult struct {
+	Token  string
+	Status BatchStatus
+	// Err is the reason of an invalid or error status
+	Err error
+}
+
+// Succeeded reports whether the URL has a token
+func (r BatchResult) Succeeded() bool {
+	return r.Status == BatchCreated || r.Status == BatchExists
+}
+
+// ShortenBatch shortens several URLs on behalf of a user and returns their outcomes in the same order.
+// Every URL is validated and the valid ones are written to storage at once, so an invalid URL doesn't
+// prevent the others from being shortened. Unlike Shorten, URLs that were already shortened are not
+// a conflict and get their existing token. Batches larger than the configured maximum are rejected
+// as a whole with ErrBatchTooLarge. If the write fails, no URL is shortened and the storage error
+// is returned.
+func (s *Shortener) ShortenBatch(ctx context.Context, userID string, longURLs []string) ([]BatchResult, error) {
+	if len(longURLs) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if err := s.CheckBatchSize(len(longURLs)); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(longURLs))
+	valid := make([]string, 0, len(longURLs))
+	positions := make([]int, 0, len(longURLs))
+	for i, longURL := range longURLs {
+		if !uu.URLCheck(longURL) {
+			results[i] = BatchResult{Status: BatchInvalid, Err: ErrInvalidURL}
+			continue
+		}
+		if len(longURL) > s.config.GetMaxURLLength() {
+			results[i] = BatchResult{Status: BatchInvalid, Err: ErrURLTooLong}
+			continue
+		}
+		valid = append(valid, longURL)
+		positions = append(positions, i)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	shortened, err := s.ShortenMany(ctx, userID, valid)
+	if err != nil {
+		return nil, fmt.Errorf("shorten batch: %w", err)
+	}
+	for j, i := range positions {
+		switch {
+		case shortened[j].Existing:
+			results[i] = BatchResult{Token: shortened[j].Token, Status: BatchExists}
+		default:
+			results[i] = BatchResult{Token: shortened[j].Token, Status: BatchCreated}
+		}
+	}
+	return results, nil
+}
+
+// Expand returns the original URL of a token
+func (s *Shortener) Expand(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	longURL, err := uu.DecodeURL(ctx, token, s.storage)
+	switch {
+	case err == nil:
+		return longURL, nil
+	case errors.Is(err, storage.ErrURLDeleted):
+		return "", ErrDeleted
+	case errors.Is(err, storage.ErrURLDisabled):
+		return "", ErrDisabled
+	case errors.Is(err, storage.ErrURLNotFound):
+		return "", ErrNotFound
+	default:
+		return "", fmt.Errorf("expand token: %w", err)
+	}
+}
+
+// UserURLs returns the URLs shortened by a user
+func (s *Shortener) UserURLs(ctx context.Context, userID string) ([]mod.URLStorageNode, error) {
+	if userID == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	urls, err := s.storage.GetUserURLs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user URLs: %w", err)
+	}
+	return urls, nil
+}
+
+// ExportURLs iterates over all the URLs of a user with their metadata, deleted ones included,
+// in the order they were shortened. URLs are read from storage as they are consumed.
+func (s *Shortener) ExportURLs(ctx context.Context, userID string) (iter.Seq2[mod.URLStorageNode, error], error) {
+	if userID == "" {
+		return nil, ErrUnauthenticated
+	}
+	return s.storage.IterUserURLs(ctx, userID), nil
+}
+
+// DeleteURLs marks URLs of a user as deleted, ignoring the tokens the user doesn't own.
+// It returns ErrBatchTooLarge for more tokens than the configured maximal batch size.
+func (s *Shortener) DeleteURLs(ctx context.Context, userID string, tokens []string) error {
+	if userID == "" {
+		return ErrUnauthenticated
+	}
+	if err := s.CheckBatchSize(len(tokens)); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteURLs(ctx, userID, tokens); err != nil {
+		return fmt.Errorf("delete URLs: %w", err)
+	}
+	return nil
+}
+
+// CheckBatchSize returns ErrBatchTooLarge if a batch of size items exceeds the configured maximum
+func (s *Shortener) CheckBatchSize(size int) error {
+	if maxSize := s.config.GetMaxBatchSize(); size > maxSize {
+		return fmt.Errorf("%w: %d items, at most %d allowed", ErrBatchTooLarge, size, maxSize)
+	}
+	return nil
+}
+
+// ShortURL builds the shortened URL of a token, using the configured base URL if any
+// and otherwise the host the shortener is reached at, over HTTPS if the connection is secure
+// or the server serves HTTPS
+func (s *Shortener) ShortURL(token, host string, secure bool) string {
+	if baseURL := s.config.GetBaseURL(); baseURL != "" {
+		return baseURL + "/" + token
+	}
+	if secure || s.config.GetEnableHTTPS() {
+		return "https://" + host + "/" + token
+	}
+	return "http://" + host + "/" + token
+}
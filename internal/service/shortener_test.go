@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pcristin/urlshortener/internal/config"
+	"github.com/pcristin/urlshortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortenBatch(t *testing.T) {
+	s := NewShortener(storage.NewMemoryStorage(), config.NewOptions())
+	ctx := context.Background()
+
+	existing, err := s.Shorten(ctx, "user1", "https://google.com")
+	require.NoError(t, err)
+
+	results, err := s.ShortenBatch(ctx, "user1", []string{"https://yandex.ru", "https://google.com", "", "not a url"})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, BatchCreated, results[0].Status)
+	assert.NotEmpty(t, results[0].Token)
+	assert.Equal(t, BatchExists, results[1].Status)
+	assert.Equal(t, existing, results[1].Token, "already shortened URLs keep their token")
+	for _, result := range results[2:] {
+		assert.Equal(t, BatchInvalid, result.Status)
+		assert.ErrorIs(t, result.Err, ErrInvalidURL)
+		assert.False(t, result.Succeeded())
+	}
+
+	token, err := s.Shorten(ctx, "user2", "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, results[0].Token, token, "conflicts return the existing token")
+
+	// A failed write shortens nothing, so the batch fails as a whole
+	failing := NewShortener(failingBatchStorage{storage.NewMemoryStorage()}, config.NewOptions())
+	results, err = failing.ShortenBatch(ctx, "user1", []string{"https://github.com", "not a url"})
+	assert.ErrorIs(t, err, errStorageDown)
+	assert.Nil(t, results)
+}
+
+// errStorageDown is returned by failingBatchStorage
+var errStorageDown = errors.New("storage down")
+
+// failingBatchStorage is a storage whose batch writes fail
+type failingBatchStorage struct {
+	storage.URLStorager
+}
+
+// AddURLBatch implements storage.URLStorager
+func (failingBatchStorage) AddURLBatch(context.Context, string, map[string]string) (map[string]string, error) {
+	return nil, errStorageDown
+}
+
+func TestDomainErrors(t *testing.T) {
+	urlStorage := storage.NewMemoryStorage()
+	s := NewShortener(urlStorage, config.NewOptions())
+	ctx := context.Background()
+
+	deleted, err := s.Shorten(ctx, "user1", "https://deleted.example")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{deleted}))
+
+	disabled, err := s.Shorten(ctx, "user1", "https://disabled.example")
+	require.NoError(t, err)
+	require.NoError(t, urlStorage.SetURLDisabled(context.Background(), disabled, true))
+
+	tests := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{
+			name: "shorten empty URL",
+			call: func() error {
+				_, err := s.Shorten(ctx, "user1", "")
+				return err
+			},
+			wantErr: ErrInvalidURL,
+		},
+		{
+			name: "shorten empty batch",
+			call: func() error {
+				_, err := s.ShortenBatch(ctx, "user1", nil)
+				return err
+			},
+			wantErr: ErrEmptyBatch,
+		},
+		{
+			name: "shorten too long URL",
+			call: func() error {
+				_, err := s.Shorten(ctx, "user1", "https://example.com/"+strings.Repeat("a", 8192))
+				return err
+			},
+			wantErr: ErrURLTooLong,
+		},
+		{
+			name: "shorten too large batch",
+			call: func() error {
+				_, err := s.ShortenBatch(ctx, "user1", make([]string, 1001))
+				return err
+			},
+			wantErr: ErrBatchTooLarge,
+		},
+		{
+			name: "delete too large batch",
+			call: func() error {
+				return s.DeleteURLs(ctx, "user1", make([]string, 1001))
+			},
+			wantErr: ErrBatchTooLarge,
+		},
+		{
+			name: "expand empty token",
+			call: func() error {
+				_, err := s.Expand(ctx, "")
+				return err
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expand unknown token",
+			call: func() error {
+				_, err := s.Expand(ctx, "missing")
+				return err
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "expand deleted URL",
+			call: func() error {
+				_, err := s.Expand(ctx, deleted)
+				return err
+			},
+			wantErr: ErrDeleted,
+		},
+		{
+			name: "expand disabled URL",
+			call: func() error {
+				_, err := s.Expand(ctx, disabled)
+				return err
+			},
+			wantErr: ErrDisabled,
+		},
+		{
+			name: "anonymous user URLs",
+			call: func() error {
+				_, err := s.UserURLs(ctx, "")
+				return err
+			},
+			wantErr: ErrUnauthenticated,
+		},
+		{
+			name: "anonymous delete",
+			call: func() error {
+				return s.DeleteURLs(ctx, "", []string{deleted})
+			},
+			wantErr: ErrUnauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.call(), tt.wantErr)
+		})
+	}
+}
+
+func TestShortURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		secure  bool
+		wantURL string
+	}{
+		{
+			name:    "plain host",
+			wantURL: "http://localhost:8080/abc123",
+		},
+		{
+			name:    "secure host",
+			secure:  true,
+			wantURL: "https://localhost:8080/abc123",
+		},
+		{
+			name:    "base URL",
+			baseURL: "http://short.example",
+			secure:  true,
+			wantURL: "http://short.example/abc123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.baseURL != "" {
+				t.Setenv("BASE_URL", tt.baseURL)
+			} else {
+				os.Unsetenv("BASE_URL")
+			}
+			cfg := config.NewOptions()
+			cfg.LoadEnvVariables()
+
+			s := NewShortener(storage.NewMemoryStorage(), cfg)
+			assert.Equal(t, tt.wantURL, s.ShortURL("abc123", "localhost:8080", tt.secure))
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/pcristin/urlshortener/internal/models"
 )
 
@@ -8,6 +10,19 @@ import (
 type BaseStorage struct {
 	cache    map[string]models.URLStorageNode
 	urlIndex map[string]string // Maps original URLs to tokens for faster lookups
+	users    map[string]models.User
+	logins   map[string]string // Maps logins to user IDs
+	counters counters
+}
+
+// counters holds statistics maintained on every cache change so they can be read without a full scan
+type counters struct {
+	urls         int
+	deleted      int
+	owners       map[string]int // Maps user IDs to the number of URLs they own
+	idleAccounts int            // Number of registered users owning no URL
+	createdDay   string         // Day the createdToday counter refers to, as YYYY-MM-DD
+	createdToday int
 }
 
 // NewBaseStorage initializes the base storage
@@ -15,6 +30,9 @@ func NewBaseStorage() BaseStorage {
 	return BaseStorage{
 		cache:    make(map[string]models.URLStorageNode),
 		urlIndex: make(map[string]string),
+		users:    make(map[string]models.User),
+		logins:   make(map[string]string),
+		counters: counters{owners: make(map[string]int)},
 	}
 }
 
@@ -26,12 +44,112 @@ func (bs *BaseStorage) Get(token string) (models.URLStorageNode, bool) {
 
 // Set caches a node
 func (bs *BaseStorage) Set(token string, node models.URLStorageNode) {
+	if old, ok := bs.cache[token]; ok {
+		bs.counters.remove(old, bs.isRegistered(old.UserID))
+	}
+	bs.counters.add(node, bs.isRegistered(node.UserID))
 	bs.cache[token] = node
 	bs.urlIndex[node.OriginalURL] = token
 }
 
+// Delete removes a cached node
+func (bs *BaseStorage) Delete(token string) {
+	if node, ok := bs.cache[token]; ok {
+		bs.counters.remove(node, bs.isRegistered(node.UserID))
+		if bs.urlIndex[node.OriginalURL] == token {
+			delete(bs.urlIndex, node.OriginalURL)
+		}
+		delete(bs.cache, token)
+	}
+}
+
+// CountCreated records newly shortened URLs in the statistics of the current day
+func (bs *BaseStorage) CountCreated(n int) {
+	today := time.Now().Format(time.DateOnly)
+	if bs.counters.createdDay != today {
+		bs.counters.createdDay = today
+		bs.counters.createdToday = 0
+	}
+	bs.counters.createdToday += n
+}
+
+// CountLoaded records the loaded nodes shortened during the current day in its statistics
+func (bs *BaseStorage) CountLoaded(node models.URLStorageNode) {
+	if !node.CreatedAt.IsZero() && node.CreatedAt.Local().Format(time.DateOnly) == time.Now().Format(time.DateOnly) {
+		bs.CountCreated(1)
+	}
+}
+
+// Stats returns the maintained statistics of the cached nodes and users
+func (bs *BaseStorage) Stats() models.Stats {
+	stats := models.Stats{
+		URLs:        bs.counters.urls,
+		Users:       len(bs.counters.owners) + bs.counters.idleAccounts,
+		DeletedURLs: bs.counters.deleted,
+	}
+	if bs.counters.createdDay == time.Now().Format(time.DateOnly) {
+		stats.CreatedToday = bs.counters.createdToday
+	}
+	return stats
+}
+
+// add accounts for a node entering the cache, registered tells whether its owner is a registered user
+func (c *counters) add(node models.URLStorageNode, registered bool) {
+	c.urls++
+	if node.IsDeleted {
+		c.deleted++
+	}
+	if c.owners[node.UserID]++; c.owners[node.UserID] == 1 && registered {
+		c.idleAccounts--
+	}
+}
+
+// remove accounts for a node leaving the cache, registered tells whether its owner is a registered user
+func (c *counters) remove(node models.URLStorageNode, registered bool) {
+	c.urls--
+	if node.IsDeleted {
+		c.deleted--
+	}
+	if c.owners[node.UserID]--; c.owners[node.UserID] <= 0 {
+		delete(c.owners, node.UserID)
+		if registered {
+			c.idleAccounts++
+		}
+	}
+}
+
 // GetTokenByURL returns a token for a given URL using the index
 func (bs *BaseStorage) GetTokenByURL(url string) (string, bool) {
 	token, ok := bs.urlIndex[url]
 	return token, ok
 }
+
+// GetUser retrieves a cached user by ID
+func (bs *BaseStorage) GetUser(userID string) (models.User, bool) {
+	user, ok := bs.users[userID]
+	return user, ok
+}
+
+// GetUserByLogin retrieves a cached user by login
+func (bs *BaseStorage) GetUserByLogin(login string) (models.User, bool) {
+	userID, ok := bs.logins[login]
+	if !ok {
+		return models.User{}, false
+	}
+	return bs.GetUser(userID)
+}
+
+// SetUser caches a user
+func (bs *BaseStorage) SetUser(user models.User) {
+	if !bs.isRegistered(user.ID) && bs.counters.owners[user.ID] == 0 {
+		bs.counters.idleAccounts++
+	}
+	bs.users[user.ID] = user
+	bs.logins[user.Login] = user.ID
+}
+
+// isRegistered reports whether the user ID belongs to a registered user
+func (bs *BaseStorage) isRegistered(userID string) bool {
+	_, ok := bs.users[userID]
+	return ok
+}
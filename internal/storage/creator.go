@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pcristin/urlshortener/internal/models"
+)
+
+// Creator describes how the URLs added by a storage operation were shortened.
+// It travels in the context of the operation, so frontends record it without every storage method taking it.
+type Creator struct {
+	Source       string // API the URLs are shortened through, one of the models.Source constants
+	ClientIPHash string // Keyed hash of the IP address of the client, empty if unknown
+}
+
+// creatorContextKey is the context key of the creator of the URLs added with a context
+type creatorContextKey struct{}
+
+// WithCreator returns a context recording the creator in the URLs added with it
+func WithCreator(ctx context.Context, creator Creator) context.Context {
+	return context.WithValue(ctx, creatorContextKey{}, creator)
+}
+
+// CreatorFromContext returns the creator recorded in the context, zero if none
+func CreatorFromContext(ctx context.Context) Creator {
+	creator, _ := ctx.Value(creatorContextKey{}).(Creator)
+	return creator
+}
+
+// newURLNode returns the node of a URL shortened at the time, with the creator of the context
+func newURLNode(ctx context.Context, token, longURL, userID string, now time.Time) models.URLStorageNode {
+	creator := CreatorFromContext(ctx)
+	return models.URLStorageNode{
+		UUID:         uuid.New(),
+		ShortURL:     token,
+		OriginalURL:  longURL,
+		UserID:       userID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Source:       creator.Source,
+		ClientIPHash: creator.ClientIPHash,
+	}
+}
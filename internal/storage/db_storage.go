@@ -3,6 +3,11 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,13 +15,21 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pcristin/urlshortener/internal/database"
+	"github.com/pcristin/urlshortener/internal/logger"
 	"github.com/pcristin/urlshortener/internal/models"
-	"go.uber.org/zap"
 )
 
+func init() {
+	Register("postgres", openDatabaseStorage)
+	Register("postgresql", openDatabaseStorage)
+}
+
 // Type for database storage
 type DatabaseStorage struct {
 	dbPool *pgxpool.Pool
+	// manager owns the pool if the storage was opened by its driver, nil otherwise
+	manager database.DatabaseManagerInterface
 }
 
 // Return a new object of URLStorage (database type)
@@ -26,8 +39,18 @@ func NewDatabaseStorage(pool *pgxpool.Pool) *DatabaseStorage {
 	}
 }
 
+// openDatabaseStorage is the driver of postgres:// URIs: it connects to the database, whose schema
+// is created by Migrate, and closes the connection pool on Close
+func openDatabaseStorage(ctx context.Context, uri *url.URL) (URLStorager, error) {
+	manager, err := database.NewDatabaseManager(uri.String())
+	if err != nil {
+		return nil, err
+	}
+	return &DatabaseStorage{dbPool: manager.GetPool(), manager: manager}, nil
+}
+
 // Writes a new link of token --> long URL in DB
-func (ds *DatabaseStorage) AddURL(token, longURL string, userID string) error {
+func (ds *DatabaseStorage) AddURL(ctx context.Context, token, longURL string, userID string) error {
 	if ds.dbPool == nil {
 		return errors.New("database not initialized")
 	}
@@ -35,12 +58,14 @@ func (ds *DatabaseStorage) AddURL(token, longURL string, userID string) error {
 		return errors.New("token and URL cannot be empty")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := ds.dbPool.Exec(ctx,
-		"INSERT INTO urls (token, original_url, user_id) VALUES ($1, $2, $3)",
-		token, longURL, userID)
+	creator := CreatorFromContext(ctx)
+	_, err := ds.dbPool.Exec(ctx, `
+		INSERT INTO urls (token, original_url, user_id, updated_at, source, client_ip_hash)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, $4, $5)`,
+		token, longURL, userID, creator.Source, creator.ClientIPHash)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
@@ -55,22 +80,22 @@ func (ds *DatabaseStorage) AddURL(token, longURL string, userID string) error {
 }
 
 // Gets a long URL by token from DB
-func (ds *DatabaseStorage) GetURL(token string) (string, error) {
+func (ds *DatabaseStorage) GetURL(ctx context.Context, token string) (string, error) {
 	if ds.dbPool == nil {
 		return "", errors.New("database not initialized")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	var longURL string
-	var isDeleted bool
+	var isDeleted, isDisabled bool
 	err := ds.dbPool.QueryRow(ctx,
-		"SELECT original_url, is_deleted FROM urls WHERE token = $1",
-		token).Scan(&longURL, &isDeleted)
+		"SELECT original_url, is_deleted, is_disabled FROM urls WHERE token = $1",
+		token).Scan(&longURL, &isDeleted, &isDisabled)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return "", errors.New("URL not found")
+			return "", ErrURLNotFound
 		}
 		return "", err
 	}
@@ -78,17 +103,20 @@ func (ds *DatabaseStorage) GetURL(token string) (string, error) {
 	if isDeleted {
 		return "", ErrURLDeleted
 	}
+	if isDisabled {
+		return "", ErrURLDisabled
+	}
 
 	return longURL, nil
 }
 
 // Gets a token by original URL from DB
-func (ds *DatabaseStorage) GetTokenByURL(longURL string) (string, error) {
+func (ds *DatabaseStorage) GetTokenByURL(ctx context.Context, longURL string) (string, error) {
 	if ds.dbPool == nil {
 		return "", errors.New("database not initialized")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	var token string
@@ -97,7 +125,7 @@ func (ds *DatabaseStorage) GetTokenByURL(longURL string) (string, error) {
 		longURL).Scan(&token)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return "", errors.New("url not found")
+			return "", ErrURLNotFound
 		}
 		return "", err
 	}
@@ -105,16 +133,16 @@ func (ds *DatabaseStorage) GetTokenByURL(longURL string) (string, error) {
 }
 
 // GetUserURLs returns all URLs shortened by a specific user
-func (ds *DatabaseStorage) GetUserURLs(userID string) ([]models.URLStorageNode, error) {
+func (ds *DatabaseStorage) GetUserURLs(ctx context.Context, userID string) ([]models.URLStorageNode, error) {
 	if ds.dbPool == nil {
 		return nil, errors.New("database not initialized")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	rows, err := ds.dbPool.Query(ctx,
-		"SELECT id, token, original_url FROM urls WHERE user_id = $1",
+		"SELECT "+urlColumns+" FROM urls WHERE user_id = $1",
 		userID)
 	if err != nil {
 		return nil, err
@@ -123,17 +151,10 @@ func (ds *DatabaseStorage) GetUserURLs(userID string) ([]models.URLStorageNode,
 
 	var urls []models.URLStorageNode
 	for rows.Next() {
-		var node models.URLStorageNode
-		var id string
-		err := rows.Scan(&id, &node.ShortURL, &node.OriginalURL)
+		node, err := scanURL(rows)
 		if err != nil {
 			return nil, err
 		}
-		node.UUID, err = uuid.Parse(id)
-		if err != nil {
-			return nil, err
-		}
-		node.UserID = userID
 		urls = append(urls, node)
 	}
 
@@ -144,6 +165,141 @@ func (ds *DatabaseStorage) GetUserURLs(userID string) ([]models.URLStorageNode,
 	return urls, nil
 }
 
+// urlColumns are the columns of the urls table read into a URLStorageNode by scanURL
+const urlColumns = "id, token, original_url, user_id, is_deleted, is_disabled, created_at, updated_at, deleted_at, source, client_ip_hash"
+
+// scanURL reads a row starting with the urlColumns, the following columns are scanned into extra
+func scanURL(row pgx.Row, extra ...any) (models.URLStorageNode, error) {
+	var node models.URLStorageNode
+	var id string
+	// Timestamps are NULL for URLs stored before they were recorded
+	var createdAt, updatedAt, deletedAt *time.Time
+	dest := append([]any{&id, &node.ShortURL, &node.OriginalURL, &node.UserID, &node.IsDeleted, &node.IsDisabled,
+		&createdAt, &updatedAt, &deletedAt, &node.Source, &node.ClientIPHash}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return models.URLStorageNode{}, err
+	}
+
+	var err error
+	if node.UUID, err = uuid.Parse(id); err != nil {
+		return models.URLStorageNode{}, err
+	}
+	node.CreatedAt, node.UpdatedAt, node.DeletedAt = timeOrZero(createdAt), timeOrZero(updatedAt), timeOrZero(deletedAt)
+	return node, nil
+}
+
+// timeOrZero returns the time of a nullable column, zero for NULL
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// hostSQL extracts the lower cased host of the original URL, matching hostOf
+const hostSQL = `lower(substring(original_url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)'))`
+
+// QueryUserURLs returns a page of the URLs of a user matching the query, in the order of the query.
+// Pages are read with a keyset condition on the sort key and the token rather than an offset, so the
+// (user_id, created_at, token) and (user_id, original_url, token) indexes locate the start of every page
+// and following pages stay consistent while URLs are added. Strings are compared bytewise (COLLATE "C"),
+// as in memory storage and as the indexes are built.
+func (ds *DatabaseStorage) QueryUserURLs(ctx context.Context, query UserURLQuery) ([]models.URLStorageNode, error) {
+	if ds.dbPool == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	args := []any{query.UserID}
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions := []string{"user_id = $1"}
+	if !query.IncludeDeleted {
+		conditions = append(conditions, "NOT is_deleted")
+	}
+	if query.Domain != "" {
+		domain := arg(strings.ToLower(query.Domain)) + "::text"
+		conditions = append(conditions, fmt.Sprintf("(%[1]s = %[2]s OR right(%[1]s, length(%[2]s) + 1) = '.' || %[2]s)", hostSQL, domain))
+	}
+	if !query.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at > "+arg(query.CreatedAfter))
+	}
+	if !query.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(query.CreatedBefore))
+	}
+	if query.Search != "" {
+		search := arg(strings.ToLower(query.Search)) + "::text"
+		conditions = append(conditions, fmt.Sprintf("(strpos(lower(token), %[1]s) > 0 OR strpos(lower(original_url), %[1]s) > 0)", search))
+	}
+
+	// Rows without creation time sort first in ascending order, as the zero time in memory storage
+	direction, comparison, nulls := "ASC", ">", "NULLS FIRST"
+	if query.Descending {
+		direction, comparison, nulls = "DESC", "<", "NULLS LAST"
+	}
+	var orderBy string
+	if query.SortBy == SortByOriginalURL {
+		orderBy = fmt.Sprintf(`original_url COLLATE "C" %[1]s, token COLLATE "C" %[1]s`, direction)
+		if after := query.After; after != nil {
+			conditions = append(conditions, fmt.Sprintf(`(original_url COLLATE "C", token COLLATE "C") %s (%s::text, %s::text)`,
+				comparison, arg(after.OriginalURL), arg(after.Token)))
+		}
+	} else {
+		orderBy = fmt.Sprintf(`created_at %[1]s %[2]s, token COLLATE "C" %[1]s`, direction, nulls)
+		if after := query.After; after != nil {
+			token := arg(after.Token)
+			switch {
+			case after.CreatedAt.IsZero() && query.Descending:
+				conditions = append(conditions, fmt.Sprintf(`(created_at IS NULL AND token COLLATE "C" < %s::text)`, token))
+			case after.CreatedAt.IsZero():
+				conditions = append(conditions, fmt.Sprintf(`(created_at IS NOT NULL OR token COLLATE "C" > %s::text)`, token))
+			case query.Descending:
+				conditions = append(conditions, fmt.Sprintf(`(created_at IS NULL OR (created_at, token COLLATE "C") < (%s::timestamptz, %s::text))`,
+					arg(after.CreatedAt), token))
+			default:
+				conditions = append(conditions, fmt.Sprintf(`(created_at, token COLLATE "C") > (%s::timestamptz, %s::text)`,
+					arg(after.CreatedAt), token))
+			}
+		}
+	}
+
+	// A NULL limit returns all rows
+	var limit *int
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+
+	rows, err := ds.dbPool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM urls
+		WHERE %s
+		ORDER BY %s
+		LIMIT %s`,
+		urlColumns, strings.Join(conditions, " AND "), orderBy, arg(limit)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make([]models.URLStorageNode, 0)
+	for rows.Next() {
+		node, err := scanURL(rows)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
 // Empty method from URLStorage interface
 func (ds *DatabaseStorage) SaveToFile() error {
 	return nil
@@ -154,86 +310,175 @@ func (ds *DatabaseStorage) LoadFromFile(filepath string) error {
 	return nil
 }
 
-// Set pool for making queries to DB
-func (ds *DatabaseStorage) SetDBPool(pool *pgxpool.Pool) {
-	ds.dbPool = pool
+// Backend returns the name of the database backend
+func (ds *DatabaseStorage) Backend() string {
+	return "database"
 }
 
-// Get URLStorage type (inherited method)
-func (ds *DatabaseStorage) GetStorageType() StorageType {
-	return DatabaseStorageType
-}
-
-// Get pool for making queries to DB
+// Get pool for making queries to DB, implements PoolProvider
 func (ds *DatabaseStorage) GetDBPool() *pgxpool.Pool {
 	return ds.dbPool
 }
 
-// AddURLBatch adds multiple URLs to the database in a single transaction
-func (ds *DatabaseStorage) AddURLBatch(urls map[string]string) error {
+// Migrate creates the tables and indexes of the storage, implements Migrator
+func (ds *DatabaseStorage) Migrate(ctx context.Context) error {
+	if ds.manager == nil {
+		return errors.New("database not initialized")
+	}
+	return ds.manager.Migrate(ctx)
+}
+
+// Close closes the connection pool if the storage was opened by its driver,
+// a pool given to NewDatabaseStorage is left to its owner
+func (ds *DatabaseStorage) Close() error {
+	if ds.manager != nil {
+		ds.manager.Close()
+	}
+	return nil
+}
+
+// CheckHealth pings the database
+func (ds *DatabaseStorage) CheckHealth(ctx context.Context) error {
 	if ds.dbPool == nil {
 		return errors.New("database not initialized")
 	}
+	return ds.dbPool.Ping(ctx)
+}
+
+// AddURLBatch adds URLs of a user to the database in a single transaction.
+// URLs that were already shortened are not added and are returned mapped to their existing token.
+func (ds *DatabaseStorage) AddURLBatch(ctx context.Context, userID string, urls map[string]string) (map[string]string, error) {
+	if ds.dbPool == nil {
+		return nil, errors.New("database not initialized")
+	}
 	if len(urls) == 0 {
-		return errors.New("batch cannot be empty")
+		return nil, errors.New("batch cannot be empty")
 	}
 
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
 	tx, err := ds.dbPool.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		if rErr := tx.Rollback(ctx); rErr != nil && rErr != pgx.ErrTxClosed {
-			zap.L().Sugar().Errorw("Rollback failed", "error", rErr)
+			logger.FromContext(ctx).Sugar().Errorw("Rollback failed", "error", rErr)
 		}
 	}()
 
 	batch := &pgx.Batch{}
+	longURLs := make([]string, 0, len(urls))
+	creator := CreatorFromContext(ctx)
 	for token, originalURL := range urls {
-		zap.L().Sugar().Infof("Queueing INSERT for token=%s, original_url=%s", token, originalURL)
 		batch.Queue(`
-			INSERT INTO urls (token, original_url, user_id) 
-			VALUES ($1, $2, $3) 
-			ON CONFLICT (original_url) DO NOTHING`,
-			token, originalURL, uuid.New().String()) // Generate a new UUID for each URL in batch
+			INSERT INTO urls (token, original_url, user_id, updated_at, source, client_ip_hash)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP, $4, $5)
+			ON CONFLICT (original_url) DO NOTHING
+			RETURNING token`,
+			token, originalURL, userID, creator.Source, creator.ClientIPHash)
+		longURLs = append(longURLs, originalURL)
 	}
 
+	// A URL conflicting with an existing one inserts no row and returns no token
+	var conflicts []string
 	br := tx.SendBatch(ctx, batch)
 	for i := 0; i < batch.Len(); i++ {
-		if _, err := br.Exec(); err != nil {
-			var pgErr *pgconn.PgError
-			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-				// If it's a unique violation on the token, we should return an error
-				if pgErr.ConstraintName == "urls_token_key" {
-					zap.L().Sugar().Errorf("batch execution error at item %d: %v", i, err)
-					_ = br.Close()
-					return err
-				}
-				// If it's a unique violation on the original_url, we can ignore it
-				continue
-			}
-			zap.L().Sugar().Errorf("batch execution error at item %d: %v", i, err)
+		var token string
+		err := br.QueryRow().Scan(&token)
+		if errors.Is(err, pgx.ErrNoRows) {
+			conflicts = append(conflicts, longURLs[i])
+			continue
+		}
+		if err != nil {
+			logger.FromContext(ctx).Sugar().Errorf("batch execution error at item %d: %v", i, err)
 			_ = br.Close()
-			return err
+			return nil, err
 		}
 	}
 
 	if err := br.Close(); err != nil {
-		zap.L().Sugar().Errorw("Failed to close batch", "error", err)
-		return err
+		logger.FromContext(ctx).Sugar().Errorw("Failed to close batch", "error", err)
+		return nil, err
+	}
+
+	existing := make(map[string]string, len(conflicts))
+	if len(conflicts) > 0 {
+		rows, err := tx.Query(ctx, "SELECT original_url, token FROM urls WHERE original_url = ANY($1)", conflicts)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var originalURL, token string
+			if err := rows.Scan(&originalURL, &token); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			existing[originalURL] = token
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		zap.L().Sugar().Errorf("commit failed: %v", err)
-		return err
+		logger.FromContext(ctx).Sugar().Errorf("commit failed: %v", err)
+		return nil, err
 	}
 
-	return nil
+	return existing, nil
+}
+
+// IterUserURLs iterates over the URLs of a user with a database cursor, so rows are read as they are consumed.
+// Every wait on the database is bounded by 5s, the time the consumer spends on a row is not.
+func (ds *DatabaseStorage) IterUserURLs(ctx context.Context, userID string) iter.Seq2[models.URLStorageNode, error] {
+	return func(yield func(models.URLStorageNode, error) bool) {
+		if ds.dbPool == nil {
+			yield(models.URLStorageNode{}, errors.New("database not initialized"))
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		timeout := time.AfterFunc(5*time.Second, cancel)
+		defer timeout.Stop()
+
+		rows, err := ds.dbPool.Query(ctx,
+			"SELECT "+urlColumns+" FROM urls WHERE user_id = $1 ORDER BY created_at, token",
+			userID)
+		if err != nil {
+			yield(models.URLStorageNode{}, err)
+			return
+		}
+		// Closing the rows early, when the consumer stops, releases the connection
+		defer rows.Close()
+
+		for {
+			timeout.Reset(5 * time.Second)
+			next := rows.Next()
+			timeout.Stop()
+			if !next {
+				break
+			}
+
+			node, err := scanURL(rows)
+			if err != nil {
+				yield(models.URLStorageNode{}, err)
+				return
+			}
+			if !yield(node, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(models.URLStorageNode{}, err)
+		}
+	}
 }
 
 // DeleteURLs marks multiple URLs as deleted for a specific user
-func (ds *DatabaseStorage) DeleteURLs(userID string, tokens []string) error {
+func (ds *DatabaseStorage) DeleteURLs(ctx context.Context, userID string, tokens []string) error {
 	if ds.dbPool == nil {
 		return errors.New("database not initialized")
 	}
@@ -241,7 +486,7 @@ func (ds *DatabaseStorage) DeleteURLs(userID string, tokens []string) error {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	// Start a transaction
@@ -251,7 +496,7 @@ func (ds *DatabaseStorage) DeleteURLs(userID string, tokens []string) error {
 	}
 	defer func() {
 		if rErr := tx.Rollback(ctx); rErr != nil && rErr != pgx.ErrTxClosed {
-			zap.L().Sugar().Errorw("Rollback failed", "error", rErr)
+			logger.FromContext(ctx).Sugar().Errorw("Rollback failed", "error", rErr)
 		}
 	}()
 
@@ -259,9 +504,9 @@ func (ds *DatabaseStorage) DeleteURLs(userID string, tokens []string) error {
 	batch := &pgx.Batch{}
 	for _, token := range tokens {
 		batch.Queue(`
-			UPDATE urls 
-			SET is_deleted = TRUE 
-			WHERE token = $1 AND user_id = $2`,
+			UPDATE urls
+			SET is_deleted = TRUE, deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE token = $1 AND user_id = $2 AND NOT is_deleted`,
 			token, userID)
 	}
 
@@ -269,21 +514,240 @@ func (ds *DatabaseStorage) DeleteURLs(userID string, tokens []string) error {
 	br := tx.SendBatch(ctx, batch)
 	for i := 0; i < batch.Len(); i++ {
 		if _, err := br.Exec(); err != nil {
-			zap.L().Sugar().Errorf("batch execution error at item %d: %v", i, err)
+			logger.FromContext(ctx).Sugar().Errorf("batch execution error at item %d: %v", i, err)
 			_ = br.Close()
 			return err
 		}
 	}
 
 	if err := br.Close(); err != nil {
-		zap.L().Sugar().Errorw("Failed to close batch", "error", err)
+		logger.FromContext(ctx).Sugar().Errorw("Failed to close batch", "error", err)
 		return err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		zap.L().Sugar().Errorf("commit failed: %v", err)
+		logger.FromContext(ctx).Sugar().Errorf("commit failed: %v", err)
 		return err
 	}
 
 	return nil
 }
+
+// DeleteURLsByUser marks the URLs of several users as deleted with a single UPDATE,
+// matching every token with its owner
+func (ds *DatabaseStorage) DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error {
+	if ds.dbPool == nil {
+		return errors.New("database not initialized")
+	}
+
+	var tokens, userIDs []string
+	for userID, userTokens := range tokensByUser {
+		for _, token := range userTokens {
+			tokens = append(tokens, token)
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := ds.dbPool.Exec(ctx, `
+		UPDATE urls
+		SET is_deleted = TRUE, deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		FROM unnest($1::text[], $2::text[]) AS deleted(token, user_id)
+		WHERE urls.token = deleted.token AND urls.user_id = deleted.user_id AND NOT urls.is_deleted`,
+		tokens, userIDs)
+	return err
+}
+
+// AddUser registers a new user in DB
+func (ds *DatabaseStorage) AddUser(ctx context.Context, user models.User) error {
+	if ds.dbPool == nil {
+		return errors.New("database not initialized")
+	}
+	if user.ID == "" || user.Login == "" {
+		return errors.New("user ID and login cannot be empty")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := ds.dbPool.Exec(ctx,
+		"INSERT INTO users (id, login, password_hash, created_at) VALUES ($1, $2, $3, $4)",
+		user.ID, user.Login, user.PasswordHash, user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return ErrUserExists
+		}
+		return err
+	}
+	return nil
+}
+
+// GetUserByLogin retrieves a registered user by login from DB
+func (ds *DatabaseStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	return ds.getUser(ctx, "SELECT id, login, password_hash, created_at FROM users WHERE login = $1", login)
+}
+
+// GetUserByID retrieves a registered user by user ID from DB
+func (ds *DatabaseStorage) GetUserByID(ctx context.Context, userID string) (models.User, error) {
+	return ds.getUser(ctx, "SELECT id, login, password_hash, created_at FROM users WHERE id = $1", userID)
+}
+
+// getUser runs a query returning a single user row
+func (ds *DatabaseStorage) getUser(ctx context.Context, query string, arg string) (models.User, error) {
+	if ds.dbPool == nil {
+		return models.User{}, errors.New("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := ds.dbPool.QueryRow(ctx, query, arg).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// ReassignUserURLs transfers ownership of all URLs from one user to another in DB
+func (ds *DatabaseStorage) ReassignUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	if ds.dbPool == nil {
+		return errors.New("database not initialized")
+	}
+	if fromUserID == "" || toUserID == "" {
+		return errors.New("user IDs cannot be empty")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := ds.dbPool.Exec(ctx,
+		"UPDATE urls SET user_id = $2, updated_at = CURRENT_TIMESTAMP WHERE user_id = $1",
+		fromUserID, toUserID)
+	return err
+}
+
+// ListURLs returns the URLs of all users matching the filter, ordered by token
+func (ds *DatabaseStorage) ListURLs(ctx context.Context, filter ListFilter) ([]models.URLStorageNode, int, error) {
+	if ds.dbPool == nil {
+		return nil, 0, errors.New("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// A NULL limit returns all rows
+	var limit *int
+	if filter.Limit > 0 {
+		limit = &filter.Limit
+	}
+
+	rows, err := ds.dbPool.Query(ctx, `
+		SELECT `+urlColumns+`, count(*) OVER ()
+		FROM urls
+		WHERE ($1 = '' OR user_id = $1)
+			AND ($2 = '' OR strpos(token, $2) > 0 OR strpos(original_url, $2) > 0)
+		ORDER BY token
+		LIMIT $3 OFFSET $4`,
+		filter.UserID, filter.Search, limit, max(filter.Offset, 0))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	urls := make([]models.URLStorageNode, 0)
+	total := 0
+	for rows.Next() {
+		node, err := scanURL(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		urls = append(urls, node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// The window count is not available when the offset is past the last row
+	if len(urls) == 0 && filter.Offset > 0 {
+		err = ds.dbPool.QueryRow(ctx, `
+			SELECT count(*) FROM urls
+			WHERE ($1 = '' OR user_id = $1)
+				AND ($2 = '' OR strpos(token, $2) > 0 OR strpos(original_url, $2) > 0)`,
+			filter.UserID, filter.Search).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return urls, total, nil
+}
+
+// SetURLDisabled disables or enables the URL with the given token in DB
+func (ds *DatabaseStorage) SetURLDisabled(ctx context.Context, token string, disabled bool) error {
+	return ds.updateURL(ctx, "UPDATE urls SET is_disabled = $2, updated_at = CURRENT_TIMESTAMP WHERE token = $1", token, disabled)
+}
+
+// SetURLOwner transfers ownership of the URL with the given token to a user in DB
+func (ds *DatabaseStorage) SetURLOwner(ctx context.Context, token, userID string) error {
+	if userID == "" {
+		return errors.New("user ID cannot be empty")
+	}
+	return ds.updateURL(ctx, "UPDATE urls SET user_id = $2, updated_at = CURRENT_TIMESTAMP WHERE token = $1", token, userID)
+}
+
+// RemoveURL permanently removes the URL with the given token from DB
+func (ds *DatabaseStorage) RemoveURL(ctx context.Context, token string) error {
+	return ds.updateURL(ctx, "DELETE FROM urls WHERE token = $1", token)
+}
+
+// updateURL runs a statement modifying a single URL, returning ErrURLNotFound if no row was affected
+func (ds *DatabaseStorage) updateURL(ctx context.Context, query string, args ...any) error {
+	if ds.dbPool == nil {
+		return errors.New("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := ds.dbPool.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// GetStats returns service-wide statistics of the URLs and users stored in DB
+func (ds *DatabaseStorage) GetStats(ctx context.Context) (models.Stats, error) {
+	if ds.dbPool == nil {
+		return models.Stats{}, errors.New("database not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var stats models.Stats
+	err := ds.dbPool.QueryRow(ctx, `
+		SELECT
+			count(*),
+			(SELECT count(*) FROM (SELECT id FROM users UNION SELECT user_id FROM urls) AS ids),
+			count(*) FILTER (WHERE is_deleted),
+			count(*) FILTER (WHERE created_at >= date_trunc('day', now()))
+		FROM urls`).Scan(&stats.URLs, &stats.Users, &stats.DeletedURLs, &stats.CreatedToday)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	return stats, nil
+}
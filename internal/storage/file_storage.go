@@ -2,19 +2,52 @@ package storage
 
 import (
 	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mailru/easyjson"
 	"github.com/pcristin/urlshortener/internal/models"
 )
 
-// FileStorage implements URLStorager interface with file storage
+func init() {
+	Register("file", openFileStorage)
+}
+
+// openFileStorage is the driver of file URIs, absolute as file:///var/lib/shortener/data.json
+// or relative to the working directory as file:data.json
+func openFileStorage(ctx context.Context, uri *url.URL) (URLStorager, error) {
+	if uri.Host != "" && uri.Host != "localhost" {
+		return nil, fmt.Errorf("file storage URI: unexpected host %q, use file:///absolute/path or file:relative/path", uri.Host)
+	}
+	path := uri.Path
+	if uri.Opaque != "" {
+		var err error
+		if path, err = url.PathUnescape(uri.Opaque); err != nil {
+			return nil, fmt.Errorf("file storage URI: %w", err)
+		}
+	}
+	if path == "" {
+		return nil, errors.New("file storage URI: missing path")
+	}
+	return NewFileStorage(path), nil
+}
+
+// FileStorage implements URLStorager interface with file storage.
+// The file holds a URLStorageNode in JSON per line. Fields are only ever added to the format: lines written
+// before a field existed load with its zero value, such as zero timestamps and no creator metadata,
+// and unknown fields are ignored, so files can be shared with older and newer versions.
 type FileStorage struct {
 	*MemoryStorage
 	filePath string
+
+	// fileMu serializes writes to the data files
+	fileMu sync.Mutex
 }
 
 // NewFileStorage creates a new file storage instance
@@ -30,12 +63,14 @@ func NewFileStorage(filePath string) *FileStorage {
 }
 
 // AddURL adds a new URL to the file storage
-func (fs *FileStorage) AddURL(token, longURL string, userID string) error {
-	err := fs.MemoryStorage.AddURL(token, longURL, userID)
+func (fs *FileStorage) AddURL(ctx context.Context, token, longURL string, userID string) error {
+	err := fs.MemoryStorage.AddURL(ctx, token, longURL, userID)
 	if err != nil {
 		return err
 	}
+	fs.mu.RLock()
 	node, _ := fs.Get(token)
+	fs.mu.RUnlock()
 	// Ignore file operation errors
 	_ = fs.appendToFile(node)
 	return nil
@@ -47,6 +82,9 @@ func (fs *FileStorage) appendToFile(node models.URLStorageNode) error {
 		return nil
 	}
 
+	fs.fileMu.Lock()
+	defer fs.fileMu.Unlock()
+
 	dir := filepath.Dir(fs.filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		// If we can't create directory, just log and continue
@@ -74,14 +112,20 @@ func (fs *FileStorage) appendToFile(node models.URLStorageNode) error {
 }
 
 // GetURL retrieves a URL from the file storage
-func (fs *FileStorage) GetURL(token string) (string, error) {
+func (fs *FileStorage) GetURL(ctx context.Context, token string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
 	if node, ok := fs.Get(token); ok {
 		if node.IsDeleted {
 			return "", ErrURLDeleted
 		}
+		if node.IsDisabled {
+			return "", ErrURLDisabled
+		}
 		return node.OriginalURL, nil
 	}
-	return "", errors.New("URL not found")
+	return "", ErrURLNotFound
 }
 
 // SaveToFile saves the file storage to a file
@@ -90,6 +134,9 @@ func (fs *FileStorage) SaveToFile() error {
 		return nil
 	}
 
+	fs.fileMu.Lock()
+	defer fs.fileMu.Unlock()
+
 	dir := filepath.Dir(fs.filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		// If we can't create directory, just log and continue
@@ -103,6 +150,9 @@ func (fs *FileStorage) SaveToFile() error {
 	}
 	defer file.Close()
 
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
 	writer := bufio.NewWriter(file)
 	for _, node := range fs.cache {
 		data, err := easyjson.Marshal(&node)
@@ -132,6 +182,9 @@ func (fs *FileStorage) LoadFromFile(filepath string) error {
 	}
 	defer file.Close()
 
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		var node models.URLStorageNode
@@ -139,56 +192,189 @@ func (fs *FileStorage) LoadFromFile(filepath string) error {
 			// If we can't unmarshal a line, skip it and continue
 			continue
 		}
+		if _, ok := fs.Get(node.ShortURL); !ok {
+			fs.CountLoaded(node)
+		}
 		fs.Set(node.ShortURL, node)
 	}
 	// Ignore scanner errors
+	fs.loadUsersFromFile()
 	return nil
 }
 
-// SetDBPool sets the database pool for the file storage
-func (fs *FileStorage) SetDBPool(*pgxpool.Pool) {}
+// usersFilePath returns the path of the file holding registered users,
+// which is stored next to the URL data file (saved_data.json -> saved_data.users.json)
+func (fs *FileStorage) usersFilePath() string {
+	ext := filepath.Ext(fs.filePath)
+	return strings.TrimSuffix(fs.filePath, ext) + ".users" + ext
+}
+
+// loadUsersFromFile loads registered users from the users file
+func (fs *FileStorage) loadUsersFromFile() {
+	file, err := os.Open(fs.usersFilePath())
+	if err != nil {
+		// No users have been registered yet
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var user models.User
+		if err := easyjson.Unmarshal(scanner.Bytes(), &user); err != nil {
+			// If we can't unmarshal a line, skip it and continue
+			continue
+		}
+		fs.SetUser(user)
+	}
+}
+
+// appendUserToFile appends a registered user to the users file
+func (fs *FileStorage) appendUserToFile(user models.User) error {
+	if fs.filePath == "" {
+		return nil
+	}
+
+	fs.fileMu.Lock()
+	defer fs.fileMu.Unlock()
+
+	usersPath := fs.usersFilePath()
+	if err := os.MkdirAll(filepath.Dir(usersPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(usersPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := easyjson.Marshal(&user)
+	if err != nil {
+		return err
+	}
 
-// GetStorageType returns the storage type for the file storage
-func (fs *FileStorage) GetStorageType() StorageType {
-	return FileStorageType
+	_, err = file.Write(append(data, '\n'))
+	return err
 }
 
-// GetDBPool returns nil for file storage
-func (fs *FileStorage) GetDBPool() *pgxpool.Pool {
-	return nil
+// Backend returns the name of the file backend
+func (fs *FileStorage) Backend() string {
+	return "file"
 }
 
-// AddURLBatch adds multiple URLs to the file storage
-func (fs *FileStorage) AddURLBatch(urls map[string]string) error {
+// Close flushes the whole cache to the file so nothing is lost on shutdown
+func (fs *FileStorage) Close() error {
+	return fs.SaveToFile()
+}
+
+// CheckHealth returns an error if the data file can't be opened for writing.
+// Writes to the file are otherwise ignored on failure, so this is how a full or read-only disk is noticed.
+func (fs *FileStorage) CheckHealth(ctx context.Context) error {
+	if fs.filePath == "" {
+		return nil
+	}
+
+	fs.fileMu.Lock()
+	defer fs.fileMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(fs.filePath), 0755); err != nil {
+		return fmt.Errorf("create data directory: %w", err)
+	}
+	file, err := os.OpenFile(fs.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open data file: %w", err)
+	}
+	return file.Close()
+}
+
+// AddURLBatch adds URLs of a user to the file storage, see MemoryStorage.AddURLBatch
+func (fs *FileStorage) AddURLBatch(ctx context.Context, userID string, urls map[string]string) (map[string]string, error) {
 	// First add to memory
-	err := fs.MemoryStorage.AddURLBatch(urls)
+	existing, err := fs.MemoryStorage.AddURLBatch(ctx, userID, urls)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Then append each URL to file, ignoring file operation errors
-	for token := range urls {
+	// Then append each added URL to file, ignoring file operation errors
+	for token, longURL := range urls {
+		if _, found := existing[longURL]; found {
+			continue
+		}
+		fs.mu.RLock()
 		node, _ := fs.Get(token)
+		fs.mu.RUnlock()
 		_ = fs.appendToFile(node)
 	}
-	return nil
+	return existing, nil
 }
 
 // Gets a token by original URL from file storage
-func (fs *FileStorage) GetTokenByURL(longURL string) (string, error) {
+func (fs *FileStorage) GetTokenByURL(ctx context.Context, longURL string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
 	for _, node := range fs.cache {
 		if node.OriginalURL == longURL {
 			return node.ShortURL, nil
 		}
 	}
-	return "", errors.New("url not found")
+	return "", ErrURLNotFound
 }
 
 // DeleteURLs marks multiple URLs as deleted for a specific user
-func (fs *FileStorage) DeleteURLs(userID string, tokens []string) error {
-	err := fs.MemoryStorage.DeleteURLs(userID, tokens)
+func (fs *FileStorage) DeleteURLs(ctx context.Context, userID string, tokens []string) error {
+	err := fs.MemoryStorage.DeleteURLs(ctx, userID, tokens)
 	if err != nil {
 		return err
 	}
 	return fs.SaveToFile()
 }
+
+// DeleteURLsByUser marks the URLs of several users as deleted and saves the file once
+func (fs *FileStorage) DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error {
+	if err := fs.MemoryStorage.DeleteURLsByUser(ctx, tokensByUser); err != nil {
+		return err
+	}
+	return fs.SaveToFile()
+}
+
+// AddUser registers a new user and persists it to the users file
+func (fs *FileStorage) AddUser(ctx context.Context, user models.User) error {
+	if err := fs.MemoryStorage.AddUser(ctx, user); err != nil {
+		return err
+	}
+	return fs.appendUserToFile(user)
+}
+
+// ReassignUserURLs transfers ownership of all URLs from one user to another
+func (fs *FileStorage) ReassignUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	if err := fs.MemoryStorage.ReassignUserURLs(ctx, fromUserID, toUserID); err != nil {
+		return err
+	}
+	return fs.SaveToFile()
+}
+
+// SetURLDisabled disables or enables the URL with the given token
+func (fs *FileStorage) SetURLDisabled(ctx context.Context, token string, disabled bool) error {
+	if err := fs.MemoryStorage.SetURLDisabled(ctx, token, disabled); err != nil {
+		return err
+	}
+	return fs.SaveToFile()
+}
+
+// SetURLOwner transfers ownership of the URL with the given token to a user
+func (fs *FileStorage) SetURLOwner(ctx context.Context, token, userID string) error {
+	if err := fs.MemoryStorage.SetURLOwner(ctx, token, userID); err != nil {
+		return err
+	}
+	return fs.SaveToFile()
+}
+
+// RemoveURL permanently removes the URL with the given token
+func (fs *FileStorage) RemoveURL(ctx context.Context, token string) error {
+	if err := fs.MemoryStorage.RemoveURL(ctx, token); err != nil {
+		return err
+	}
+	return fs.SaveToFile()
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/metrics"
+	"github.com/pcristin/urlshortener/internal/models"
+)
+
+var (
+	operationDuration = metrics.NewHistogramVec(
+		"shortener_storage_operation_duration_seconds",
+		"Latency of storage operations by backend and method.",
+		metrics.DefBuckets,
+		"backend", "method",
+	)
+	operationErrors = metrics.NewCounterVec(
+		"shortener_storage_operation_errors_total",
+		"Number of failed storage operations by backend and method, expected outcomes such as a missing URL excluded.",
+		"backend", "method",
+	)
+)
+
+// InstrumentedStorage measures the latency and errors of the operations of a storage.
+// Accessors such as Backend are passed through without being measured.
+type InstrumentedStorage struct {
+	URLStorager
+	backend string
+}
+
+// NewInstrumentedStorage wraps a storage so its operations are reported in the metrics
+func NewInstrumentedStorage(s URLStorager) *InstrumentedStorage {
+	return &InstrumentedStorage{URLStorager: s, backend: s.Backend()}
+}
+
+// observe records an operation that started at start and returned err
+func (s *InstrumentedStorage) observe(method string, start time.Time, err error) {
+	operationDuration.Observe(time.Since(start).Seconds(), s.backend, method)
+	if err != nil && !isExpected(err) {
+		operationErrors.Inc(s.backend, method)
+	}
+}
+
+// isExpected reports whether an error is a normal outcome of an operation rather than a failure
+func isExpected(err error) bool {
+	for _, expected := range []error{ErrURLExists, ErrURLDeleted, ErrURLDisabled, ErrURLNotFound, ErrUserExists, ErrUserNotFound} {
+		if errors.Is(err, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddURL implements URLStorager
+func (s *InstrumentedStorage) AddURL(ctx context.Context, token, longURL string, userID string) error {
+	start := time.Now()
+	err := s.URLStorager.AddURL(ctx, token, longURL, userID)
+	s.observe("AddURL", start, err)
+	return err
+}
+
+// GetURL implements URLStorager
+func (s *InstrumentedStorage) GetURL(ctx context.Context, token string) (string, error) {
+	start := time.Now()
+	longURL, err := s.URLStorager.GetURL(ctx, token)
+	s.observe("GetURL", start, err)
+	return longURL, err
+}
+
+// SaveToFile implements URLStorager
+func (s *InstrumentedStorage) SaveToFile() error {
+	start := time.Now()
+	err := s.URLStorager.SaveToFile()
+	s.observe("SaveToFile", start, err)
+	return err
+}
+
+// LoadFromFile implements URLStorager
+func (s *InstrumentedStorage) LoadFromFile(filepath string) error {
+	start := time.Now()
+	err := s.URLStorager.LoadFromFile(filepath)
+	s.observe("LoadFromFile", start, err)
+	return err
+}
+
+// Close implements URLStorager
+func (s *InstrumentedStorage) Close() error {
+	start := time.Now()
+	err := s.URLStorager.Close()
+	s.observe("Close", start, err)
+	return err
+}
+
+// AddURLBatch implements URLStorager
+func (s *InstrumentedStorage) AddURLBatch(ctx context.Context, userID string, urls map[string]string) (map[string]string, error) {
+	start := time.Now()
+	existing, err := s.URLStorager.AddURLBatch(ctx, userID, urls)
+	s.observe("AddURLBatch", start, err)
+	return existing, err
+}
+
+// GetTokenByURL implements URLStorager
+func (s *InstrumentedStorage) GetTokenByURL(ctx context.Context, longURL string) (string, error) {
+	start := time.Now()
+	token, err := s.URLStorager.GetTokenByURL(ctx, longURL)
+	s.observe("GetTokenByURL", start, err)
+	return token, err
+}
+
+// GetUserURLs implements URLStorager
+func (s *InstrumentedStorage) GetUserURLs(ctx context.Context, userID string) ([]models.URLStorageNode, error) {
+	start := time.Now()
+	urls, err := s.URLStorager.GetUserURLs(ctx, userID)
+	s.observe("GetUserURLs", start, err)
+	return urls, err
+}
+
+// QueryUserURLs implements URLStorager
+func (s *InstrumentedStorage) QueryUserURLs(ctx context.Context, query UserURLQuery) ([]models.URLStorageNode, error) {
+	start := time.Now()
+	urls, err := s.URLStorager.QueryUserURLs(ctx, query)
+	s.observe("QueryUserURLs", start, err)
+	return urls, err
+}
+
+// IterUserURLs implements URLStorager, measuring the iteration until it ends
+func (s *InstrumentedStorage) IterUserURLs(ctx context.Context, userID string) iter.Seq2[models.URLStorageNode, error] {
+	return func(yield func(models.URLStorageNode, error) bool) {
+		start := time.Now()
+		var iterErr error
+		defer func() { s.observe("IterUserURLs", start, iterErr) }()
+
+		for node, err := range s.URLStorager.IterUserURLs(ctx, userID) {
+			if err != nil {
+				iterErr = err
+			}
+			if !yield(node, err) {
+				return
+			}
+		}
+	}
+}
+
+// DeleteURLs implements URLStorager
+func (s *InstrumentedStorage) DeleteURLs(ctx context.Context, userID string, tokens []string) error {
+	start := time.Now()
+	err := s.URLStorager.DeleteURLs(ctx, userID, tokens)
+	s.observe("DeleteURLs", start, err)
+	return err
+}
+
+// DeleteURLsByUser implements URLStorager
+func (s *InstrumentedStorage) DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error {
+	start := time.Now()
+	err := s.URLStorager.DeleteURLsByUser(ctx, tokensByUser)
+	s.observe("DeleteURLsByUser", start, err)
+	return err
+}
+
+// AddUser implements URLStorager
+func (s *InstrumentedStorage) AddUser(ctx context.Context, user models.User) error {
+	start := time.Now()
+	err := s.URLStorager.AddUser(ctx, user)
+	s.observe("AddUser", start, err)
+	return err
+}
+
+// GetUserByLogin implements URLStorager
+func (s *InstrumentedStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	start := time.Now()
+	user, err := s.URLStorager.GetUserByLogin(ctx, login)
+	s.observe("GetUserByLogin", start, err)
+	return user, err
+}
+
+// GetUserByID implements URLStorager
+func (s *InstrumentedStorage) GetUserByID(ctx context.Context, userID string) (models.User, error) {
+	start := time.Now()
+	user, err := s.URLStorager.GetUserByID(ctx, userID)
+	s.observe("GetUserByID", start, err)
+	return user, err
+}
+
+// ReassignUserURLs implements URLStorager
+func (s *InstrumentedStorage) ReassignUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	start := time.Now()
+	err := s.URLStorager.ReassignUserURLs(ctx, fromUserID, toUserID)
+	s.observe("ReassignUserURLs", start, err)
+	return err
+}
+
+// ListURLs implements URLStorager
+func (s *InstrumentedStorage) ListURLs(ctx context.Context, filter ListFilter) ([]models.URLStorageNode, int, error) {
+	start := time.Now()
+	urls, total, err := s.URLStorager.ListURLs(ctx, filter)
+	s.observe("ListURLs", start, err)
+	return urls, total, err
+}
+
+// SetURLDisabled implements URLStorager
+func (s *InstrumentedStorage) SetURLDisabled(ctx context.Context, token string, disabled bool) error {
+	start := time.Now()
+	err := s.URLStorager.SetURLDisabled(ctx, token, disabled)
+	s.observe("SetURLDisabled", start, err)
+	return err
+}
+
+// SetURLOwner implements URLStorager
+func (s *InstrumentedStorage) SetURLOwner(ctx context.Context, token, userID string) error {
+	start := time.Now()
+	err := s.URLStorager.SetURLOwner(ctx, token, userID)
+	s.observe("SetURLOwner", start, err)
+	return err
+}
+
+// RemoveURL implements URLStorager
+func (s *InstrumentedStorage) RemoveURL(ctx context.Context, token string) error {
+	start := time.Now()
+	err := s.URLStorager.RemoveURL(ctx, token)
+	s.observe("RemoveURL", start, err)
+	return err
+}
+
+// GetStats implements URLStorager
+func (s *InstrumentedStorage) GetStats(ctx context.Context) (models.Stats, error) {
+	start := time.Now()
+	stats, err := s.URLStorager.GetStats(ctx)
+	s.observe("GetStats", start, err)
+	return stats, err
+}
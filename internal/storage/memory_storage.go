@@ -1,16 +1,31 @@
 package storage
 
 import (
+	"cmp"
+	"context"
 	"errors"
+	"iter"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pcristin/urlshortener/internal/models"
 )
 
-// MemoryStorage implements URLStorager interface with in-memory storage
+func init() {
+	Register("memory", func(ctx context.Context, uri *url.URL) (URLStorager, error) {
+		return NewMemoryStorage(), nil
+	})
+}
+
+// MemoryStorage implements URLStorager interface with in-memory storage.
+// It is safe for concurrent use: handlers and background deletions access it simultaneously.
 type MemoryStorage struct {
 	BaseStorage
+	mu sync.RWMutex
 }
 
 // NewMemoryStorage creates a new in-memory storage instance
@@ -19,7 +34,10 @@ func NewMemoryStorage() *MemoryStorage {
 }
 
 // AddURL adds a new URL to the in-memory storage
-func (ms *MemoryStorage) AddURL(token, longURL string, userID string) error {
+func (ms *MemoryStorage) AddURL(ctx context.Context, token, longURL string, userID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	if token == "" || longURL == "" {
 		return errors.New("token and URL cannot be empty")
 	}
@@ -39,25 +57,26 @@ func (ms *MemoryStorage) AddURL(token, longURL string, userID string) error {
 		}
 	}
 
-	node := models.URLStorageNode{
-		UUID:        uuid.New(),
-		ShortURL:    token,
-		OriginalURL: longURL,
-		UserID:      userID,
-	}
-	ms.Set(token, node)
+	ms.Set(token, newURLNode(ctx, token, longURL, userID, time.Now()))
+	ms.CountCreated(1)
 	return nil
 }
 
 // GetURL retrieves a URL by its token from in-memory storage
-func (ms *MemoryStorage) GetURL(token string) (string, error) {
+func (ms *MemoryStorage) GetURL(ctx context.Context, token string) (string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	if node, ok := ms.Get(token); ok {
 		if node.IsDeleted {
 			return "", ErrURLDeleted
 		}
+		if node.IsDisabled {
+			return "", ErrURLDisabled
+		}
 		return node.OriginalURL, nil
 	}
-	return "", errors.New("URL not found")
+	return "", ErrURLNotFound
 }
 
 // SaveToFile is a no-op for memory storage
@@ -70,42 +89,71 @@ func (ms *MemoryStorage) LoadFromFile(filepath string) error {
 	return nil
 }
 
-// SetDBPool is a no-op for memory storage
-func (ms *MemoryStorage) SetDBPool(*pgxpool.Pool) {}
+// Backend returns the name of the memory backend
+func (ms *MemoryStorage) Backend() string {
+	return "memory"
+}
 
-// GetStorageType returns the type of storage (memory)
-func (ms *MemoryStorage) GetStorageType() StorageType {
-	return MemoryStorageType
+// Close is a no-op for memory storage
+func (ms *MemoryStorage) Close() error {
+	return nil
 }
 
-// GetDBPool returns nil for memory storage
-func (ms *MemoryStorage) GetDBPool() *pgxpool.Pool {
+// CheckHealth always succeeds: memory storage has no backend that can fail
+func (ms *MemoryStorage) CheckHealth(ctx context.Context) error {
 	return nil
 }
 
-// AddURLBatch adds multiple URLs to storage in a single operation
-func (ms *MemoryStorage) AddURLBatch(urls map[string]string) error {
+// AddURLBatch adds URLs of a user, mapped from token to original URL, in a single operation.
+// URLs that were already shortened are not added and are returned mapped to their existing token.
+func (ms *MemoryStorage) AddURLBatch(ctx context.Context, userID string, urls map[string]string) (map[string]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	// Check the whole batch first so that it is added entirely or not at all
 	for token, longURL := range urls {
-		node := models.URLStorageNode{
-			UUID:        uuid.New(),
-			ShortURL:    token,
-			OriginalURL: longURL,
+		if token == "" || longURL == "" {
+			return nil, errors.New("token and URL cannot be empty")
+		}
+		if _, exists := ms.cache[token]; exists {
+			return nil, errors.New("token already exists")
 		}
-		ms.Set(token, node)
 	}
-	return nil
+
+	existing := make(map[string]string)
+	added := 0
+	now := time.Now()
+	for token, longURL := range urls {
+		if existingToken, ok := ms.BaseStorage.GetTokenByURL(longURL); ok {
+			if node, _ := ms.Get(existingToken); !node.IsDeleted {
+				existing[longURL] = existingToken
+				continue
+			}
+		}
+
+		ms.Set(token, newURLNode(ctx, token, longURL, userID, now))
+		added++
+	}
+	ms.CountCreated(added)
+	return existing, nil
 }
 
 // GetTokenByURL retrieves a token associated with a long URL
-func (ms *MemoryStorage) GetTokenByURL(longURL string) (string, error) {
+func (ms *MemoryStorage) GetTokenByURL(ctx context.Context, longURL string) (string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	if token, ok := ms.BaseStorage.GetTokenByURL(longURL); ok {
 		return token, nil
 	}
-	return "", errors.New("url not found")
+	return "", ErrURLNotFound
 }
 
 // GetUserURLs returns all URLs shortened by a specific user
-func (ms *MemoryStorage) GetUserURLs(userID string) ([]models.URLStorageNode, error) {
+func (ms *MemoryStorage) GetUserURLs(ctx context.Context, userID string) ([]models.URLStorageNode, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	// Preallocate with a reasonable capacity to reduce allocations
 	userURLs := make([]models.URLStorageNode, 0, len(ms.cache)/4)
 
@@ -117,17 +165,222 @@ func (ms *MemoryStorage) GetUserURLs(userID string) ([]models.URLStorageNode, er
 	return userURLs, nil
 }
 
+// QueryUserURLs returns a page of the URLs of a user matching the query, in the order of the query
+func (ms *MemoryStorage) QueryUserURLs(ctx context.Context, query UserURLQuery) ([]models.URLStorageNode, error) {
+	ms.mu.RLock()
+	matches := make([]models.URLStorageNode, 0)
+	for _, node := range ms.cache {
+		if query.Matches(node) {
+			matches = append(matches, node)
+		}
+	}
+	ms.mu.RUnlock()
+
+	slices.SortFunc(matches, func(a, b models.URLStorageNode) int {
+		return query.Compare(PositionOf(a), PositionOf(b))
+	})
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+	}
+	return matches, nil
+}
+
+// IterUserURLs iterates over the URLs of a user, deleted ones included, in the order they were shortened.
+// The URLs are copied before iterating so that a slow consumer doesn't hold the lock.
+func (ms *MemoryStorage) IterUserURLs(ctx context.Context, userID string) iter.Seq2[models.URLStorageNode, error] {
+	return func(yield func(models.URLStorageNode, error) bool) {
+		userURLs, _ := ms.GetUserURLs(ctx, userID)
+		slices.SortFunc(userURLs, func(a, b models.URLStorageNode) int {
+			return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ShortURL, b.ShortURL))
+		})
+
+		for _, node := range userURLs {
+			if !yield(node, nil) {
+				return
+			}
+		}
+	}
+}
+
 // DeleteURLs marks multiple URLs as deleted for a specific user
-func (ms *MemoryStorage) DeleteURLs(userID string, tokens []string) error {
+func (ms *MemoryStorage) DeleteURLs(ctx context.Context, userID string, tokens []string) error {
 	if len(tokens) == 0 {
 		return nil
 	}
 
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
 	for _, token := range tokens {
-		if node, ok := ms.Get(token); ok && node.UserID == userID {
-			node.IsDeleted = true
+		ms.markDeleted(token, userID, now)
+	}
+	return nil
+}
+
+// DeleteURLsByUser marks the URLs of several users as deleted at once
+func (ms *MemoryStorage) DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	for userID, tokens := range tokensByUser {
+		for _, token := range tokens {
+			ms.markDeleted(token, userID, now)
+		}
+	}
+	return nil
+}
+
+// markDeleted marks the URL as deleted at the time if the user owns it.
+// A URL deleted again keeps the time it was first deleted at. The caller must hold the write lock.
+func (ms *MemoryStorage) markDeleted(token, userID string, now time.Time) {
+	if node, ok := ms.Get(token); ok && node.UserID == userID && !node.IsDeleted {
+		node.IsDeleted = true
+		node.DeletedAt = now
+		node.UpdatedAt = now
+		ms.Set(token, node)
+	}
+}
+
+// AddUser registers a new user in the in-memory storage
+func (ms *MemoryStorage) AddUser(ctx context.Context, user models.User) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if user.ID == "" || user.Login == "" {
+		return errors.New("user ID and login cannot be empty")
+	}
+	if _, exists := ms.BaseStorage.GetUserByLogin(user.Login); exists {
+		return ErrUserExists
+	}
+	if _, exists := ms.GetUser(user.ID); exists {
+		return ErrUserExists
+	}
+	ms.SetUser(user)
+	return nil
+}
+
+// GetUserByLogin retrieves a registered user by login
+func (ms *MemoryStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if user, ok := ms.BaseStorage.GetUserByLogin(login); ok {
+		return user, nil
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+// GetUserByID retrieves a registered user by user ID
+func (ms *MemoryStorage) GetUserByID(ctx context.Context, userID string) (models.User, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if user, ok := ms.GetUser(userID); ok {
+		return user, nil
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+// ReassignUserURLs transfers ownership of all URLs from one user to another
+func (ms *MemoryStorage) ReassignUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if fromUserID == "" || toUserID == "" {
+		return errors.New("user IDs cannot be empty")
+	}
+
+	now := time.Now()
+	for token, node := range ms.cache {
+		if node.UserID == fromUserID {
+			node.UserID = toUserID
+			node.UpdatedAt = now
 			ms.Set(token, node)
 		}
 	}
 	return nil
 }
+
+// ListURLs returns the URLs of all users matching the filter, ordered by token
+func (ms *MemoryStorage) ListURLs(ctx context.Context, filter ListFilter) ([]models.URLStorageNode, int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	matches := make([]models.URLStorageNode, 0)
+	for _, node := range ms.cache {
+		if filter.UserID != "" && node.UserID != filter.UserID {
+			continue
+		}
+		if filter.Search != "" && !strings.Contains(node.ShortURL, filter.Search) && !strings.Contains(node.OriginalURL, filter.Search) {
+			continue
+		}
+		matches = append(matches, node)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ShortURL < matches[j].ShortURL
+	})
+
+	total := len(matches)
+	start := min(max(filter.Offset, 0), total)
+	end := total
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, total)
+	}
+	return matches[start:end], total, nil
+}
+
+// SetURLDisabled disables or enables the URL with the given token
+func (ms *MemoryStorage) SetURLDisabled(ctx context.Context, token string, disabled bool) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	node, ok := ms.Get(token)
+	if !ok {
+		return ErrURLNotFound
+	}
+	node.IsDisabled = disabled
+	node.UpdatedAt = time.Now()
+	ms.Set(token, node)
+	return nil
+}
+
+// SetURLOwner transfers ownership of the URL with the given token to a user
+func (ms *MemoryStorage) SetURLOwner(ctx context.Context, token, userID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if userID == "" {
+		return errors.New("user ID cannot be empty")
+	}
+	node, ok := ms.Get(token)
+	if !ok {
+		return ErrURLNotFound
+	}
+	node.UserID = userID
+	node.UpdatedAt = time.Now()
+	ms.Set(token, node)
+	return nil
+}
+
+// RemoveURL permanently removes the URL with the given token
+func (ms *MemoryStorage) RemoveURL(ctx context.Context, token string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.Get(token); !ok {
+		return ErrURLNotFound
+	}
+	ms.Delete(token)
+	return nil
+}
+
+// GetStats returns the service-wide statistics maintained by the in-memory storage
+func (ms *MemoryStorage) GetStats(ctx context.Context) (models.Stats, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.Stats(), nil
+}
@@ -0,0 +1,383 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pcristin/urlshortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorageStats(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	require.NoError(t, storage.AddURL(context.Background(), "abc123", "https://google.com", "user1"))
+	require.NoError(t, storage.AddURL(context.Background(), "def456", "https://yandex.ru", "user1"))
+	require.NoError(t, storage.AddURL(context.Background(), "ghi789", "https://github.com", "user2"))
+
+	stats, err := storage.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, models.Stats{URLs: 3, Users: 2, CreatedToday: 3}, stats)
+
+	// Counters follow deletions, ownership changes and removals
+	require.NoError(t, storage.DeleteURLs(context.Background(), "user1", []string{"abc123"}))
+	require.NoError(t, storage.SetURLOwner(context.Background(), "ghi789", "user1"))
+	require.NoError(t, storage.RemoveURL(context.Background(), "def456"))
+
+	stats, err = storage.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, models.Stats{URLs: 2, Users: 1, DeletedURLs: 1, CreatedToday: 3}, stats)
+
+	// Registered users are counted whether they own URLs or not
+	require.NoError(t, storage.AddUser(context.Background(), models.User{ID: "user1", Login: "alice"}))
+	require.NoError(t, storage.AddUser(context.Background(), models.User{ID: "user3", Login: "bob"}))
+	stats, err = storage.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Users)
+
+	require.NoError(t, storage.SetURLOwner(context.Background(), "ghi789", "user3"))
+	stats, err = storage.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Users)
+}
+
+func TestFileStorageStatsAfterReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved_data.json")
+
+	storage := NewFileStorage(path)
+	require.NoError(t, storage.AddURL(context.Background(), "abc123", "https://google.com", "user1"))
+	require.NoError(t, storage.AddURL(context.Background(), "def456", "https://yandex.ru", "user2"))
+	require.NoError(t, storage.DeleteURLs(context.Background(), "user2", []string{"def456"}))
+	require.NoError(t, storage.AddUser(context.Background(), models.User{ID: "user3", Login: "alice"}))
+
+	// A URL shortened yesterday is loaded, but not counted as created today
+	yesterday := models.URLStorageNode{
+		UUID:        uuid.New(),
+		ShortURL:    "old123",
+		OriginalURL: "https://example.com",
+		UserID:      "user1",
+		CreatedAt:   time.Now().AddDate(0, 0, -1),
+	}
+	data, err := yesterday.MarshalJSON()
+	require.NoError(t, err)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.Write(append(data, '\n'))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reloaded := NewFileStorage(path)
+	stats, err := reloaded.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, models.Stats{URLs: 3, Users: 3, DeletedURLs: 1, CreatedToday: 2}, stats)
+}
+
+func TestFileStorageConcurrentAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved_data.json")
+	storage := NewFileStorage(path)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token := fmt.Sprintf("token%d", i)
+			assert.NoError(t, storage.AddURL(context.Background(), token, fmt.Sprintf("https://example.com/%d", i), "user1"))
+			_, err := storage.GetURL(context.Background(), token)
+			assert.NoError(t, err)
+			_, err = storage.GetUserURLs(context.Background(), "user1")
+			assert.NoError(t, err)
+			assert.NoError(t, storage.DeleteURLs(context.Background(), "user1", []string{token}))
+		}()
+	}
+	wg.Wait()
+
+	stats, err := storage.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, models.Stats{URLs: 20, Users: 1, DeletedURLs: 20, CreatedToday: 20}, stats)
+}
+
+func TestFileStorageClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved_data.json")
+
+	storage := NewFileStorage(path)
+	require.NoError(t, storage.MemoryStorage.AddURL(context.Background(), "abc123", "https://google.com", "user1"))
+
+	// Changes made only in memory are flushed on close
+	require.NoError(t, storage.Close())
+
+	reloaded := NewFileStorage(path)
+	url, err := reloaded.GetURL(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+}
+
+func TestCheckHealth(t *testing.T) {
+	dir := t.TempDir()
+	// A data file under a regular file can't be created
+	blocked := filepath.Join(dir, "blocked")
+	require.NoError(t, os.WriteFile(blocked, nil, 0644))
+
+	tests := []struct {
+		name    string
+		storage URLStorager
+		wantErr bool
+	}{
+		{name: "memory", storage: NewMemoryStorage()},
+		{name: "file", storage: NewFileStorage(filepath.Join(dir, "data", "saved_data.json"))},
+		{name: "file without path", storage: NewFileStorage("")},
+		{name: "file not writable", storage: NewFileStorage(filepath.Join(blocked, "saved_data.json")), wantErr: true},
+		{name: "database without pool", storage: NewDatabaseStorage(nil), wantErr: true},
+		{name: "instrumented", storage: NewInstrumentedStorage(NewDatabaseStorage(nil)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.storage.CheckHealth(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFileStorageAddURLBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved_data.json")
+
+	storage := NewFileStorage(path)
+	require.NoError(t, storage.AddURL(context.Background(), "abc123", "https://google.com", "user1"))
+
+	existing, err := storage.AddURLBatch(context.Background(), "user2", map[string]string{
+		"def456": "https://yandex.ru",
+		"ghi789": "https://google.com",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"https://google.com": "abc123"}, existing)
+
+	// Existing URLs are not added, added URLs belong to the user and are persisted
+	reloaded := NewFileStorage(path)
+	_, err = reloaded.GetURL(context.Background(), "ghi789")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	urls, err := reloaded.GetUserURLs(context.Background(), "user2")
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "def456", urls[0].ShortURL)
+
+	_, err = storage.AddURLBatch(context.Background(), "user2", map[string]string{"def456": "https://github.com"})
+	assert.Error(t, err, "tokens must be unique")
+}
+
+func TestMemoryStorageIterUserURLs(t *testing.T) {
+	storage := NewMemoryStorage()
+	for i := range 3 {
+		require.NoError(t, storage.AddURL(context.Background(), fmt.Sprintf("token%d", i), fmt.Sprintf("https://url%d.example", i), "user1"))
+	}
+	require.NoError(t, storage.AddURL(context.Background(), "other", "https://other.example", "user2"))
+	require.NoError(t, storage.DeleteURLs(context.Background(), "user1", []string{"token1"}))
+
+	var tokens []string
+	for node, err := range storage.IterUserURLs(context.Background(), "user1") {
+		require.NoError(t, err)
+		assert.False(t, node.CreatedAt.IsZero())
+		assert.Equal(t, node.ShortURL == "token1", node.IsDeleted)
+		tokens = append(tokens, node.ShortURL)
+	}
+	assert.Equal(t, []string{"token0", "token1", "token2"}, tokens, "deleted URLs are included, in creation order")
+
+	// Iteration stops when the consumer does
+	count := 0
+	for range storage.IterUserURLs(context.Background(), "user1") {
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestMemoryStorageQueryUserURLs(t *testing.T) {
+	storage := NewMemoryStorage()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, node := range []models.URLStorageNode{
+		{ShortURL: "aaa", OriginalURL: "https://www.google.com/search", CreatedAt: base.Add(2 * time.Hour)},
+		{ShortURL: "bbb", OriginalURL: "https://yandex.ru", CreatedAt: base.Add(time.Hour)},
+		{ShortURL: "ccc", OriginalURL: "https://google.com", CreatedAt: base.Add(time.Hour)},
+		{ShortURL: "ddd", OriginalURL: "https://notgoogle.com", CreatedAt: base.Add(3 * time.Hour), IsDeleted: true},
+		{ShortURL: "eee", OriginalURL: "https://GitHub.com/Google"},
+		{ShortURL: "fff", OriginalURL: "https://google.com/other", CreatedAt: base, UserID: "user2"},
+	} {
+		if node.UserID == "" {
+			node.UserID = "user1"
+		}
+		storage.Set(node.ShortURL, node)
+	}
+
+	tokens := func(query UserURLQuery) []string {
+		t.Helper()
+		query.UserID = "user1"
+		nodes, err := storage.QueryUserURLs(context.Background(), query)
+		require.NoError(t, err)
+		result := make([]string, len(nodes))
+		for i, node := range nodes {
+			result[i] = node.ShortURL
+		}
+		return result
+	}
+
+	tests := []struct {
+		name  string
+		query UserURLQuery
+		want  []string
+	}{
+		{name: "by creation time", query: UserURLQuery{}, want: []string{"eee", "bbb", "ccc", "aaa"}},
+		{name: "newest first", query: UserURLQuery{Descending: true}, want: []string{"aaa", "ccc", "bbb", "eee"}},
+		{name: "by original URL", query: UserURLQuery{SortBy: SortByOriginalURL}, want: []string{"eee", "ccc", "aaa", "bbb"}},
+		{name: "deleted included", query: UserURLQuery{IncludeDeleted: true, Descending: true}, want: []string{"ddd", "aaa", "ccc", "bbb", "eee"}},
+		{name: "domain and subdomains", query: UserURLQuery{Domain: "Google.com", IncludeDeleted: true}, want: []string{"ccc", "aaa"}},
+		{name: "created range", query: UserURLQuery{CreatedAfter: base, CreatedBefore: base.Add(2 * time.Hour)}, want: []string{"bbb", "ccc"}},
+		{name: "search", query: UserURLQuery{Search: "GOOGLE"}, want: []string{"eee", "ccc", "aaa"}},
+		{name: "limit", query: UserURLQuery{Limit: 2}, want: []string{"eee", "bbb"}},
+		{name: "after position", query: UserURLQuery{After: &URLPosition{CreatedAt: base.Add(time.Hour), Token: "bbb"}}, want: []string{"ccc", "aaa"}},
+		{name: "after position without time", query: UserURLQuery{After: &URLPosition{Token: "eee"}}, want: []string{"bbb", "ccc", "aaa"}},
+		{name: "after position descending", query: UserURLQuery{Descending: true, After: &URLPosition{CreatedAt: base.Add(time.Hour), Token: "ccc"}}, want: []string{"bbb", "eee"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tokens(tt.query))
+		})
+	}
+}
+
+func TestURLMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved_data.json")
+	storage := NewFileStorage(path)
+	ctx := WithCreator(context.Background(), Creator{Source: models.SourceJSON, ClientIPHash: "5f1e"})
+
+	require.NoError(t, storage.AddURL(ctx, "abc123", "https://google.com", "user1"))
+	_, err := storage.AddURLBatch(ctx, "user1", map[string]string{"def456": "https://yandex.ru"})
+	require.NoError(t, err)
+	for _, token := range []string{"abc123", "def456"} {
+		node, _ := storage.Get(token)
+		assert.Equal(t, models.SourceJSON, node.Source, token)
+		assert.Equal(t, "5f1e", node.ClientIPHash, token)
+		assert.False(t, node.CreatedAt.IsZero(), token)
+		assert.Equal(t, node.CreatedAt, node.UpdatedAt, token)
+		assert.True(t, node.DeletedAt.IsZero(), token)
+	}
+
+	require.NoError(t, storage.DeleteURLs(context.Background(), "user1", []string{"abc123"}))
+	deleted, _ := storage.Get("abc123")
+	assert.False(t, deleted.DeletedAt.IsZero())
+	assert.Equal(t, deleted.DeletedAt, deleted.UpdatedAt)
+
+	// Deleting again keeps the time of the first deletion
+	require.NoError(t, storage.DeleteURLsByUser(context.Background(), map[string][]string{"user1": {"abc123"}}))
+	again, _ := storage.Get("abc123")
+	assert.Equal(t, deleted.DeletedAt, again.DeletedAt)
+
+	require.NoError(t, storage.SetURLDisabled(context.Background(), "def456", true))
+	disabled, _ := storage.Get("def456")
+	assert.False(t, disabled.UpdatedAt.Before(disabled.CreatedAt))
+	assert.True(t, disabled.DeletedAt.IsZero())
+
+	// The metadata survives a reload
+	reloaded := NewFileStorage(path)
+	for _, token := range []string{"abc123", "def456"} {
+		want, _ := storage.Get(token)
+		got, ok := reloaded.Get(token)
+		require.True(t, ok, token)
+		assert.True(t, want.CreatedAt.Equal(got.CreatedAt), token)
+		assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt), token)
+		assert.True(t, want.DeletedAt.Equal(got.DeletedAt), token)
+		assert.Equal(t, want.Source, got.Source, token)
+		assert.Equal(t, want.ClientIPHash, got.ClientIPHash, token)
+	}
+}
+
+func TestFileStorageLegacyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved_data.json")
+	legacy := `{"uuid":"4e3c4d2a-8f2b-4a57-9b1c-0d5c1d3c2b1a","short_url":"abc123","original_url":"https://google.com","user_id":"user1","is_deleted":true}` + "\n" +
+		`{"uuid":"9a1b2c3d-4e5f-4a6b-8c7d-0e1f2a3b4c5d","short_url":"def456","original_url":"https://yandex.ru","user_id":"user1","is_deleted":false,"is_disabled":false,"created_at":"2025-03-01T12:00:00Z"}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0644))
+
+	storage := NewFileStorage(path)
+	urls, err := storage.GetUserURLs(context.Background(), "user1")
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+
+	old, _ := storage.Get("abc123")
+	assert.True(t, old.IsDeleted)
+	assert.True(t, old.CreatedAt.IsZero())
+	assert.True(t, old.DeletedAt.IsZero())
+	assert.Empty(t, old.Source)
+
+	recent, _ := storage.Get("def456")
+	assert.Equal(t, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), recent.CreatedAt.UTC())
+	assert.True(t, recent.UpdatedAt.IsZero())
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	// Relative file URIs are opened in the working directory, kept out of the source tree
+	t.Chdir(dir)
+
+	tests := []struct {
+		name        string
+		uri         string
+		wantBackend string
+		wantErr     error
+	}{
+		{name: "memory", uri: "memory://", wantBackend: "memory"},
+		{name: "absolute file", uri: "file://" + filepath.ToSlash(filepath.Join(dir, "data.json")), wantBackend: "file"},
+		{name: "relative file", uri: "file:relative.json", wantBackend: "file"},
+		{name: "file with host", uri: "file://data.json"},
+		{name: "file without path", uri: "file://"},
+		{name: "unknown driver", uri: "redis://localhost:6379", wantErr: ErrUnknownDriver},
+		{name: "no scheme", uri: "saved_data.json", wantErr: ErrUnknownDriver},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := Open(context.Background(), tt.uri)
+			if tt.wantBackend == "" {
+				assert.Error(t, err)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBackend, storage.Backend())
+		})
+	}
+
+	assert.FileExists(t, filepath.Join(dir, "relative.json"))
+	assert.Equal(t, []string{"file", "memory", "postgres", "postgresql"}, Drivers())
+	assert.Panics(t, func() { Register("memory", openFileStorage) })
+}
+
+func TestInstrumentedStorage(t *testing.T) {
+	storage := NewInstrumentedStorage(NewMemoryStorage())
+	assert.Equal(t, "memory", storage.Backend())
+
+	calls := operationDuration.Count("memory", "GetURL")
+	failures := operationErrors.Value("memory", "GetURL")
+	userFailures := operationErrors.Value("memory", "AddUser")
+
+	// A missing URL is an expected outcome, not a failure
+	_, err := storage.GetURL(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	assert.Equal(t, calls+1, operationDuration.Count("memory", "GetURL"))
+	assert.Equal(t, failures, operationErrors.Value("memory", "GetURL"))
+
+	assert.Error(t, storage.AddUser(context.Background(), models.User{}))
+	assert.Equal(t, userFailures+1, operationErrors.Value("memory", "AddUser"))
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"cmp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pcristin/urlshortener/internal/models"
+)
+
+// URLSortField is the field a page of URLs is sorted by, the token breaking ties
+type URLSortField string
+
+// URLSortField values
+const (
+	// SortByCreatedAt sorts URLs by the time they were shortened, those stored before it was recorded first
+	SortByCreatedAt URLSortField = "created_at"
+	// SortByOriginalURL sorts URLs by original URL, bytewise
+	SortByOriginalURL URLSortField = "original_url"
+)
+
+// URLPosition is the position of a URL in a sort order, the keyset a page starts after
+type URLPosition struct {
+	CreatedAt   time.Time // Sort key of SortByCreatedAt, zero if unknown
+	OriginalURL string    // Sort key of SortByOriginalURL
+	Token       string    // Tie breaker of both orders
+}
+
+// PositionOf returns the position of a URL
+func PositionOf(node models.URLStorageNode) URLPosition {
+	return URLPosition{CreatedAt: node.CreatedAt, OriginalURL: node.OriginalURL, Token: node.ShortURL}
+}
+
+// UserURLQuery selects a page of the URLs of a user
+type UserURLQuery struct {
+	UserID         string
+	SortBy         URLSortField // SortByCreatedAt if empty
+	Descending     bool
+	IncludeDeleted bool         // Whether URLs deleted by the user are listed
+	Domain         string       // Only URLs whose host is this domain or one of its subdomains, if set
+	CreatedAfter   time.Time    // Only URLs shortened after this time, if set
+	CreatedBefore  time.Time    // Only URLs shortened before this time, if set
+	Search         string       // Only URLs whose token or original URL contains this text, case-insensitively, if set
+	After          *URLPosition // Only URLs sorted after this position, to read the following pages
+	Limit          int          // Maximum number of URLs to return, all if zero
+}
+
+// Matches reports whether the URL passes the filters of the query and is sorted after its After position
+func (q UserURLQuery) Matches(node models.URLStorageNode) bool {
+	if node.UserID != q.UserID || (node.IsDeleted && !q.IncludeDeleted) {
+		return false
+	}
+	if q.Domain != "" && !inDomain(hostOf(node.OriginalURL), strings.ToLower(q.Domain)) {
+		return false
+	}
+	// URLs without creation time are outside every range
+	if !q.CreatedAfter.IsZero() && (node.CreatedAt.IsZero() || !node.CreatedAt.After(q.CreatedAfter)) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && (node.CreatedAt.IsZero() || !node.CreatedAt.Before(q.CreatedBefore)) {
+		return false
+	}
+	if q.Search != "" {
+		search := strings.ToLower(q.Search)
+		if !strings.Contains(strings.ToLower(node.ShortURL), search) && !strings.Contains(strings.ToLower(node.OriginalURL), search) {
+			return false
+		}
+	}
+	return q.After == nil || q.Compare(PositionOf(node), *q.After) > 0
+}
+
+// Compare compares two positions in the order of the query
+func (q UserURLQuery) Compare(a, b URLPosition) int {
+	var c int
+	if q.SortBy == SortByOriginalURL {
+		c = cmp.Or(strings.Compare(a.OriginalURL, b.OriginalURL), strings.Compare(a.Token, b.Token))
+	} else {
+		c = cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Token, b.Token))
+	}
+	if q.Descending {
+		return -c
+	}
+	return c
+}
+
+// hostOf returns the lower cased host of a URL, empty if it has none
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// inDomain reports whether the host is the domain or one of its subdomains
+func inDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
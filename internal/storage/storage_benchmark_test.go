@@ -1,23 +1,24 @@
 package storage
 
 import (
+	"context"
 	"testing"
 )
 
 func BenchmarkMemoryStorage_AddURL(b *testing.B) {
-	storage := NewURLStorage(MemoryStorageType, "", nil)
+	storage := NewMemoryStorage()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		token := "token" + string(rune(i))
 		longURL := "https://example.com/" + string(rune(i))
 		userID := "user1"
-		_ = storage.AddURL(token, longURL, userID)
+		_ = storage.AddURL(context.Background(), token, longURL, userID)
 	}
 }
 
 func BenchmarkMemoryStorage_GetURL(b *testing.B) {
-	storage := NewURLStorage(MemoryStorageType, "", nil)
+	storage := NewMemoryStorage()
 
 	// Prepare by saving URLs
 	var tokens []string
@@ -25,36 +26,36 @@ func BenchmarkMemoryStorage_GetURL(b *testing.B) {
 		token := "token" + string(rune(i))
 		longURL := "https://example.com/" + string(rune(i))
 		userID := "user1"
-		_ = storage.AddURL(token, longURL, userID)
+		_ = storage.AddURL(context.Background(), token, longURL, userID)
 		tokens = append(tokens, token)
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		idx := i % len(tokens)
-		_, _ = storage.GetURL(tokens[idx])
+		_, _ = storage.GetURL(context.Background(), tokens[idx])
 	}
 }
 
 func BenchmarkMemoryStorage_GetUserURLs(b *testing.B) {
-	storage := NewURLStorage(MemoryStorageType, "", nil)
+	storage := NewMemoryStorage()
 
 	// Prepare by saving URLs
 	for i := 0; i < 1000; i++ {
 		token := "token" + string(rune(i))
 		longURL := "https://example.com/" + string(rune(i))
 		userID := "user1"
-		_ = storage.AddURL(token, longURL, userID)
+		_ = storage.AddURL(context.Background(), token, longURL, userID)
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = storage.GetUserURLs("user1")
+		_, _ = storage.GetUserURLs(context.Background(), "user1")
 	}
 }
 
 func BenchmarkMemoryStorage_GetTokenByURL(b *testing.B) {
-	storage := NewURLStorage(MemoryStorageType, "", nil)
+	storage := NewMemoryStorage()
 
 	// Prepare by saving URLs
 	var urls []string
@@ -62,18 +63,18 @@ func BenchmarkMemoryStorage_GetTokenByURL(b *testing.B) {
 		token := "token" + string(rune(i))
 		longURL := "https://example.com/" + string(rune(i))
 		urls = append(urls, longURL)
-		_ = storage.AddURL(token, longURL, "user1")
+		_ = storage.AddURL(context.Background(), token, longURL, "user1")
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		idx := i % len(urls)
-		_, _ = storage.GetTokenByURL(urls[idx])
+		_, _ = storage.GetTokenByURL(context.Background(), urls[idx])
 	}
 }
 
 func BenchmarkMemoryStorage_DeleteURLs(b *testing.B) {
-	storage := NewURLStorage(MemoryStorageType, "", nil)
+	storage := NewMemoryStorage()
 
 	// Prepare by saving URLs
 	var tokens []string
@@ -81,7 +82,7 @@ func BenchmarkMemoryStorage_DeleteURLs(b *testing.B) {
 		token := "token" + string(rune(i))
 		longURL := "https://example.com/" + string(rune(i))
 		tokens = append(tokens, token)
-		_ = storage.AddURL(token, longURL, "user1")
+		_ = storage.AddURL(context.Background(), token, longURL, "user1")
 	}
 
 	b.ResetTimer()
@@ -89,7 +90,7 @@ func BenchmarkMemoryStorage_DeleteURLs(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			start := (i * 10) % (len(tokens) - 10)
 			batchTokens := tokens[start : start+10]
-			_ = storage.DeleteURLs("user1", batchTokens)
+			_ = storage.DeleteURLs(context.Background(), "user1", batchTokens)
 		}
 	})
 }
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"iter"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pcristin/urlshortener/internal/models"
@@ -13,28 +15,39 @@ var (
 	ErrURLExists = errors.New("url already exists")
 	// ErrURLDeleted is returned when attempting to access a URL that has been marked as deleted
 	ErrURLDeleted = errors.New("url was deleted")
+	// ErrURLDisabled is returned when attempting to access a URL that has been disabled by an administrator
+	ErrURLDisabled = errors.New("url was disabled")
+	// ErrURLNotFound is returned when a URL can not be found by token
+	ErrURLNotFound = errors.New("url not found")
+	// ErrUserExists is returned when attempting to register a login that is already taken
+	ErrUserExists = errors.New("user already exists")
+	// ErrUserNotFound is returned when a registered user can not be found
+	ErrUserNotFound = errors.New("user not found")
 )
 
-// StorageType defines the type of storage mechanism used for URL data
-type StorageType int
-
-// StorageType constants
-const (
-	// MemoryStorageType represents in-memory storage (volatile)
-	MemoryStorageType StorageType = iota
-	// FileStorageType represents file-based persistent storage
-	FileStorageType
-	// DatabaseStorageType represents database-backed persistent storage
-	DatabaseStorageType
-)
+// ListFilter defines the criteria for listing URLs across all users
+type ListFilter struct {
+	UserID string // Only URLs owned by this user, if set
+	Search string // Only URLs whose token or original URL contains this text, if set
+	Offset int    // Number of matching URLs to skip
+	Limit  int    // Maximum number of URLs to return
+}
 
-// URLStorager defines the interface for URL storage operations
+// HealthChecker is implemented by the components the service depends on to report whether they work
+type HealthChecker interface {
+	// CheckHealth returns an error if the component can't serve requests, such as an unreachable
+	// database or a file that can't be written
+	CheckHealth(ctx context.Context) error
+}
+
+// URLStorager defines the interface for URL storage operations.
+// The context of an operation bounds it and carries the request-scoped logger, see logger.FromContext.
 type URLStorager interface {
 	// AddURL adds a new URL to storage with an associated token and user ID
-	AddURL(token, longURL string, userID string) error
+	AddURL(ctx context.Context, token, longURL string, userID string) error
 
 	// GetURL retrieves the original URL associated with a token
-	GetURL(token string) (string, error)
+	GetURL(ctx context.Context, token string) (string, error)
 
 	// SaveToFile persists the current state to a file (for file-based storage)
 	SaveToFile() error
@@ -42,40 +55,76 @@ type URLStorager interface {
 	// LoadFromFile loads the state from a file (for file-based storage)
 	LoadFromFile(filepath string) error
 
-	// SetDBPool sets the database connection pool (for database storage)
-	SetDBPool(*pgxpool.Pool)
+	// Backend returns the name of the storage backend, such as memory, file or database, as used in metrics labels
+	Backend() string
 
-	// GetStorageType returns the type of storage being used
-	GetStorageType() StorageType
+	// Close flushes pending state and releases the resources owned by the storage
+	Close() error
 
-	// GetDBPool returns the database connection pool (for database storage)
-	GetDBPool() *pgxpool.Pool
+	// HealthChecker reports whether the backend of the storage is usable
+	HealthChecker
 
-	// AddURLBatch adds multiple URLs to storage in a single operation
-	AddURLBatch(urls map[string]string) error
+	// AddURLBatch adds URLs of a user, mapped from token to original URL, in a single operation.
+	// URLs that were already shortened are not added and are returned mapped to their existing token.
+	AddURLBatch(ctx context.Context, userID string, urls map[string]string) (map[string]string, error)
 
 	// GetTokenByURL retrieves the token associated with a long URL
-	GetTokenByURL(longURL string) (string, error)
+	GetTokenByURL(ctx context.Context, longURL string) (string, error)
 
 	// GetUserURLs retrieves all URLs associated with a specific user
-	GetUserURLs(userID string) ([]models.URLStorageNode, error)
+	GetUserURLs(ctx context.Context, userID string) ([]models.URLStorageNode, error)
+
+	// QueryUserURLs returns a page of the URLs of a user matching the query, in the order of the query
+	QueryUserURLs(ctx context.Context, query UserURLQuery) ([]models.URLStorageNode, error)
+
+	// IterUserURLs iterates over the URLs of a user, deleted ones included, in the order they were shortened.
+	// Unlike GetUserURLs it doesn't load all the URLs at once; an error ends the iteration.
+	IterUserURLs(ctx context.Context, userID string) iter.Seq2[models.URLStorageNode, error]
 
 	// DeleteURLs marks the specified URLs as deleted for a given user
-	DeleteURLs(userID string, tokens []string) error
+	DeleteURLs(ctx context.Context, userID string, tokens []string) error
+
+	// DeleteURLsByUser marks the URLs of several users, given as tokens by user ID, as deleted
+	// in a single operation. Tokens a user doesn't own are ignored.
+	DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error
+
+	// AddUser registers a new user account, returning ErrUserExists if the login is taken
+	AddUser(ctx context.Context, user models.User) error
+
+	// GetUserByLogin retrieves a registered user by login
+	GetUserByLogin(ctx context.Context, login string) (models.User, error)
+
+	// GetUserByID retrieves a registered user by user ID
+	GetUserByID(ctx context.Context, userID string) (models.User, error)
+
+	// ReassignUserURLs transfers ownership of all URLs from one user to another
+	ReassignUserURLs(ctx context.Context, fromUserID, toUserID string) error
+
+	// ListURLs returns the URLs of all users matching the filter, ordered by token,
+	// together with the total number of matching URLs
+	ListURLs(ctx context.Context, filter ListFilter) ([]models.URLStorageNode, int, error)
+
+	// SetURLDisabled disables or enables the URL with the given token
+	SetURLDisabled(ctx context.Context, token string, disabled bool) error
+
+	// SetURLOwner transfers ownership of the URL with the given token to a user
+	SetURLOwner(ctx context.Context, token, userID string) error
+
+	// RemoveURL permanently removes the URL with the given token
+	RemoveURL(ctx context.Context, token string) error
+
+	// GetStats returns service-wide statistics of the stored URLs
+	GetStats(ctx context.Context) (models.Stats, error)
 }
 
-// NewURLStorage creates a new storage instance based on type
-// It returns a specific implementation of URLStorager depending on the provided type:
-// - DatabaseStorageType: A database-backed storage using the provided connection pool
-// - FileStorageType: A file-based storage using the provided file path
-// - MemoryStorageType (default): An in-memory storage
-func NewURLStorage(storageType StorageType, filePath string, dbPool *pgxpool.Pool) URLStorager {
-	switch storageType {
-	case DatabaseStorageType:
-		return NewDatabaseStorage(dbPool)
-	case FileStorageType:
-		return NewFileStorage(filePath)
-	default:
-		return NewMemoryStorage()
-	}
+// Migrator is implemented by storages whose schema must be created or upgraded before they are used
+type Migrator interface {
+	// Migrate brings the schema up to date, it is safe to run on an up-to-date schema
+	Migrate(ctx context.Context) error
+}
+
+// PoolProvider is implemented by storages backed by a PostgreSQL connection pool
+type PoolProvider interface {
+	// GetDBPool returns the database connection pool
+	GetDBPool() *pgxpool.Pool
 }
@@ -1,6 +1,7 @@
 package urlutils
 
 import (
+	"context"
 	randMath "math/rand/v2"
 	"regexp"
 	"sync"
@@ -16,8 +17,8 @@ var (
 )
 
 // DecodeURL retrieves the original URL from storage using the provided token
-func DecodeURL(token string, storage storage.URLStorager) (string, error) {
-	return storage.GetURL(token)
+func DecodeURL(ctx context.Context, token string, storage storage.URLStorager) (string, error) {
+	return storage.GetURL(ctx, token)
 }
 
 func generateRandomNumber(a int, b int) int {
@@ -37,9 +38,9 @@ func generateToken(length int) string {
 // EncodeURL shortens a URL to a token with 6-9 random characters
 // It first checks if the URL already exists in storage and returns the existing token if found.
 // Otherwise, it generates a new token and adds the URL to storage.
-func EncodeURL(url string, s storage.URLStorager, userID string) (string, error) {
+func EncodeURL(ctx context.Context, url string, s storage.URLStorager, userID string) (string, error) {
 	// First, check if the URL already exists
-	if token, err := s.GetTokenByURL(url); err == nil {
+	if token, err := s.GetTokenByURL(ctx, url); err == nil {
 		return token, storage.ErrURLExists
 	}
 
@@ -47,7 +48,7 @@ func EncodeURL(url string, s storage.URLStorager, userID string) (string, error)
 	length := generateRandomNumber(6, 10)
 	token := generateToken(length)
 
-	err := s.AddURL(token, url, userID)
+	err := s.AddURL(ctx, token, url, userID)
 	if err != nil {
 		// Handle any other errors
 		return "", err
@@ -1,36 +1,37 @@
 package urlutils
 
 import (
+	"context"
 	"testing"
 
 	"github.com/pcristin/urlshortener/internal/storage"
 )
 
 func BenchmarkEncodeURL(b *testing.B) {
-	memStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	memStorage := storage.NewMemoryStorage()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		url := "https://example.com/" + string(rune(i))
-		_, _ = EncodeURL(url, memStorage, "user1")
+		_, _ = EncodeURL(context.Background(), url, memStorage, "user1")
 	}
 }
 
 func BenchmarkDecodeURL(b *testing.B) {
-	memStorage := storage.NewURLStorage(storage.MemoryStorageType, "", nil)
+	memStorage := storage.NewMemoryStorage()
 
 	// Prepare by adding URLs
 	var tokens []string
 	for i := 0; i < 1000; i++ {
 		url := "https://example.com/" + string(rune(i))
-		token, _ := EncodeURL(url, memStorage, "user1")
+		token, _ := EncodeURL(context.Background(), url, memStorage, "user1")
 		tokens = append(tokens, token)
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		idx := i % len(tokens)
-		_, _ = DecodeURL(tokens[idx], memStorage)
+		_, _ = DecodeURL(context.Background(), tokens[idx], memStorage)
 	}
 }
 
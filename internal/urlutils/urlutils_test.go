@@ -1,10 +1,11 @@
 package urlutils
 
 import (
+	"context"
 	"errors"
+	"iter"
 	"testing"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pcristin/urlshortener/internal/models"
 	"github.com/pcristin/urlshortener/internal/storage"
 	"github.com/stretchr/testify/assert"
@@ -16,12 +17,12 @@ type MockStorager struct {
 	mock.Mock
 }
 
-func (m *MockStorager) AddURL(token, longURL string, userID string) error {
+func (m *MockStorager) AddURL(ctx context.Context, token, longURL string, userID string) error {
 	args := m.Called(token, longURL, userID)
 	return args.Error(0)
 }
 
-func (m *MockStorager) GetURL(token string) (string, error) {
+func (m *MockStorager) GetURL(ctx context.Context, token string) (string, error) {
 	args := m.Called(token)
 	return args.String(0), args.Error(1)
 }
@@ -36,40 +37,101 @@ func (m *MockStorager) LoadFromFile(filepath string) error {
 	return args.Error(0)
 }
 
-func (m *MockStorager) SetDBPool(pool *pgxpool.Pool) {
-	m.Called(pool)
-}
-
-func (m *MockStorager) GetStorageType() storage.StorageType {
+func (m *MockStorager) Backend() string {
 	args := m.Called()
-	return args.Get(0).(storage.StorageType)
+	return args.String(0)
 }
 
-func (m *MockStorager) GetDBPool() *pgxpool.Pool {
+func (m *MockStorager) Close() error {
 	args := m.Called()
-	return args.Get(0).(*pgxpool.Pool)
+	return args.Error(0)
 }
 
-func (m *MockStorager) AddURLBatch(urls map[string]string) error {
-	args := m.Called(urls)
+func (m *MockStorager) CheckHealth(ctx context.Context) error {
+	args := m.Called(ctx)
 	return args.Error(0)
 }
 
-func (m *MockStorager) GetTokenByURL(longURL string) (string, error) {
+func (m *MockStorager) AddURLBatch(ctx context.Context, userID string, urls map[string]string) (map[string]string, error) {
+	args := m.Called(userID, urls)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (m *MockStorager) GetTokenByURL(ctx context.Context, longURL string) (string, error) {
 	args := m.Called(longURL)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockStorager) GetUserURLs(userID string) ([]models.URLStorageNode, error) {
+func (m *MockStorager) IterUserURLs(ctx context.Context, userID string) iter.Seq2[models.URLStorageNode, error] {
 	args := m.Called(userID)
+	return args.Get(0).(iter.Seq2[models.URLStorageNode, error])
+}
+
+func (m *MockStorager) GetUserURLs(ctx context.Context, userID string) ([]models.URLStorageNode, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.URLStorageNode), args.Error(1)
+}
+
+func (m *MockStorager) QueryUserURLs(ctx context.Context, query storage.UserURLQuery) ([]models.URLStorageNode, error) {
+	args := m.Called(query)
 	return args.Get(0).([]models.URLStorageNode), args.Error(1)
 }
 
-func (m *MockStorager) DeleteURLs(userID string, tokens []string) error {
+func (m *MockStorager) DeleteURLs(ctx context.Context, userID string, tokens []string) error {
 	args := m.Called(userID, tokens)
 	return args.Error(0)
 }
 
+func (m *MockStorager) DeleteURLsByUser(ctx context.Context, tokensByUser map[string][]string) error {
+	args := m.Called(tokensByUser)
+	return args.Error(0)
+}
+
+func (m *MockStorager) AddUser(ctx context.Context, user models.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockStorager) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	args := m.Called(login)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockStorager) GetUserByID(ctx context.Context, userID string) (models.User, error) {
+	args := m.Called(userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockStorager) ReassignUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	args := m.Called(fromUserID, toUserID)
+	return args.Error(0)
+}
+
+func (m *MockStorager) ListURLs(ctx context.Context, filter storage.ListFilter) ([]models.URLStorageNode, int, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.URLStorageNode), args.Int(1), args.Error(2)
+}
+
+func (m *MockStorager) SetURLDisabled(ctx context.Context, token string, disabled bool) error {
+	args := m.Called(token, disabled)
+	return args.Error(0)
+}
+
+func (m *MockStorager) SetURLOwner(ctx context.Context, token, userID string) error {
+	args := m.Called(token, userID)
+	return args.Error(0)
+}
+
+func (m *MockStorager) RemoveURL(ctx context.Context, token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockStorager) GetStats(ctx context.Context) (models.Stats, error) {
+	args := m.Called()
+	return args.Get(0).(models.Stats), args.Error(1)
+}
+
 func TestEncodeURL(t *testing.T) {
 	// Create a mock storage
 	mockStorage := new(MockStorager)
@@ -80,7 +142,7 @@ func TestEncodeURL(t *testing.T) {
 	mockStorage.On("GetTokenByURL", longURL).Return("", errors.New("url not found"))
 	mockStorage.On("AddURL", mock.Anything, longURL, userID).Return(nil)
 
-	token, err := EncodeURL(longURL, mockStorage, userID)
+	token, err := EncodeURL(context.Background(), longURL, mockStorage, userID)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 	mockStorage.AssertExpectations(t)
@@ -95,7 +157,7 @@ func TestDecodeURL(t *testing.T) {
 	// Test when token exists
 	mockStorage.On("GetURL", token).Return(longURL, nil)
 
-	result, err := DecodeURL(token, mockStorage)
+	result, err := DecodeURL(context.Background(), token, mockStorage)
 	assert.NoError(t, err)
 	assert.Equal(t, longURL, result)
 	mockStorage.AssertExpectations(t)
@@ -104,7 +166,7 @@ func TestDecodeURL(t *testing.T) {
 	mockStorage = new(MockStorager)
 	mockStorage.On("GetURL", "nonexistent").Return("", errors.New("url not found"))
 
-	result, err = DecodeURL("nonexistent", mockStorage)
+	result, err = DecodeURL(context.Background(), "nonexistent", mockStorage)
 	assert.Error(t, err)
 	assert.Empty(t, result)
 	mockStorage.AssertExpectations(t)